@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"go-art-api/audit"
+	"go-art-api/config"
+)
+
+const adminUsage = `usage: go-art-api admin <command>
+
+commands:
+  list            list site administrators
+  grant <email>   make a user an administrator
+  revoke <email>  remove a user's administrator rights`
+
+// runAdmin handles the `admin` subcommand. Granting from the command line is
+// how the first administrator is created.
+func runAdmin(args []string) {
+	if len(args) == 0 || (args[0] != "list" && len(args) != 2) {
+		fmt.Println(adminUsage)
+		os.Exit(2)
+	}
+
+	config.InitDB()
+	defer config.CloseDB()
+
+	switch args[0] {
+	case "list":
+		rows, err := config.DB.Query("SELECT id, email FROM users WHERE is_admin = ? ORDER BY id", true)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int
+			var email string
+			if err := rows.Scan(&id, &email); err != nil {
+				log.Fatal("❌ ", err)
+			}
+			fmt.Printf("%6d  %s\n", id, email)
+		}
+
+	case "grant", "revoke":
+		var userID int
+		if err := config.DB.QueryRow("SELECT id FROM users WHERE email = ?", args[1]).Scan(&userID); err != nil {
+			log.Fatalf("❌ No user with email %s: %v", args[1], err)
+		}
+		isAdmin := args[0] == "grant"
+		tx, err := config.DB.Begin()
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		_, err = tx.Exec("UPDATE users SET is_admin = ? WHERE id = ?", isAdmin, userID)
+		if err == nil {
+			err = audit.Record(tx, audit.Entry{
+				Action: audit.Update, Entity: audit.User, EntityID: userID, After: map[string]bool{"is_admin": isAdmin},
+			})
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			tx.Rollback()
+			log.Fatal("❌ ", err)
+		}
+		log.Printf("✅ %s is %s an administrator", args[1], map[bool]string{true: "now", false: "no longer"}[isAdmin])
+
+	default:
+		fmt.Println(adminUsage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go-art-api/archive"
+	"go-art-api/config"
+	"go-art-api/migrations"
+)
+
+// runExport handles `go-art-api export [-user id] [-o file.zip]`
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	userID := fs.Int("user", 0, "only export this user's family (default: everything)")
+	output := fs.String("o", fmt.Sprintf("go-art-export-%s.zip", time.Now().Format("20060102-150405")), "output file")
+	fs.Parse(args)
+
+	config.OpenDB()
+	defer config.CloseDB()
+
+	if err := migrations.CheckCompatible(config.DB.DB, config.DB.Dialect); err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		log.Fatal("❌ Failed to create archive: ", err)
+	}
+	defer f.Close()
+
+	m, err := archive.Export(config.DB, f, archive.ExportOptions{UserID: *userID})
+	if err != nil {
+		os.Remove(*output)
+		log.Fatal("❌ Export failed: ", err)
+	}
+
+	log.Printf("✅ Exported %d users, %d artists, %d artworks, %d images to %s",
+		len(m.Users), len(m.Artists), len(m.Artworks), len(m.Images), *output)
+}
+
+// runImport handles `go-art-api import [-on-conflict skip|fail] file.zip`
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	onConflict := fs.String("on-conflict", archive.OnConflictSkip,
+		"when a user's email already exists: skip (reuse the existing user) or fail")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("usage: go-art-api import [-on-conflict skip|fail] <archive.zip>")
+		os.Exit(2)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatal("❌ Failed to open archive: ", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	// Bring the target schema up to date before loading data into it
+	config.InitDB()
+	defer config.CloseDB()
+
+	result, err := archive.Import(config.DB, f, info.Size(), archive.ImportOptions{OnConflict: *onConflict})
+	if err != nil {
+		log.Fatal("❌ Import failed: ", err)
+	}
+
+	log.Printf("✅ Imported %d users (%d existing reused), %d artists, %d artworks, %d mediums (%d reused), %d images",
+		result.Users, result.UsersReused, result.Artists, result.Artworks, result.Mediums, result.MediumsReused, result.Images)
+}
@@ -0,0 +1,190 @@
+package archive
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-art-api/config"
+	"go-art-api/dialect"
+	"go-art-api/migrations"
+)
+
+// openTestDB returns a migrated SQLite database in a temporary file
+func openTestDB(t *testing.T, name string) *config.Database {
+	t.Helper()
+	// Migrations log every step
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	d, dsn, err := dialect.FromURL("sqlite://" + filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(d.Driver(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrations.Up(db, d); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return &config.Database{DB: db, Dialect: d}
+}
+
+// testJPEG is a small image for the BLOB columns
+func testJPEG(t *testing.T, shade uint8) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 32, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.RGBA{R: shade, G: uint8(x * 8), B: uint8(y * 10), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func mustInsert(t *testing.T, db *config.Database, query string, args ...interface{}) int64 {
+	t.Helper()
+	id, err := db.Insert(query, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return id
+}
+
+func count(t *testing.T, db *config.Database, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+// seedSource makes two families. Ada's has an artwork with a medium and an
+// image, plus one in the trash; Bob's has an artwork of its own.
+func seedSource(t *testing.T, db *config.Database) (adaID int64, image []byte) {
+	image = testJPEG(t, 200)
+	adaID = mustInsert(t, db, "INSERT INTO users (fname, lname, email, pwd) VALUES (?, ?, ?, ?)", "Ada", "Parent", "ada@example.com", "hash-a")
+	bobID := mustInsert(t, db, "INSERT INTO users (fname, lname, email, pwd) VALUES (?, ?, ?, ?)", "Bob", "Other", "bob@example.com", "hash-b")
+	kid := mustInsert(t, db, "INSERT INTO artists (name) VALUES (?)", "Kid")
+	other := mustInsert(t, db, "INSERT INTO artists (name) VALUES (?)", "Other Kid")
+	mustInsert(t, db, "INSERT INTO user_artists (user_id, artist_id, role) VALUES (?, ?, ?)", adaID, kid, "owner")
+	mustInsert(t, db, "INSERT INTO user_artists (user_id, artist_id, role) VALUES (?, ?, ?)", bobID, other, "owner")
+
+	crayon := mustInsert(t, db, "INSERT INTO mediums (name) VALUES (?)", "Crayon")
+	birch := mustInsert(t, db, "INSERT INTO artworks (artist_id, title, grade) VALUES (?, ?, ?)", kid, "Birch", "2")
+	mustInsert(t, db, "INSERT INTO artworks_mediums (artwork_id, medium_id) VALUES (?, ?)", birch, crayon)
+	mustInsert(t, db, `
+		INSERT INTO images (artwork_id, original_mime, thumb, image, thumb_bytes, image_bytes, original, original_bytes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, birch, "image/jpeg", image, image, len(image), len(image), image, len(image))
+
+	trashed := mustInsert(t, db, "INSERT INTO artworks (artist_id, title, deleted_at) VALUES (?, ?, ?)", kid, "Trashed", time.Now().UTC())
+	mustInsert(t, db, "INSERT INTO artworks_mediums (artwork_id, medium_id) VALUES (?, ?)", trashed, crayon)
+	mustInsert(t, db, "INSERT INTO images (artwork_id, original_mime, thumb, image) VALUES (?, ?, ?, ?)", trashed, "image/jpeg", image, image)
+
+	bobs := mustInsert(t, db, "INSERT INTO artworks (artist_id, title) VALUES (?, ?)", other, "Bob's")
+	mustInsert(t, db, "INSERT INTO images (artwork_id, original_mime, thumb, image) VALUES (?, ?, ?, ?)", bobs, "image/jpeg", image, image)
+	return adaID, image
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	source := openTestDB(t, "source.db")
+	adaID, image := seedSource(t, source)
+
+	var archive bytes.Buffer
+	m, err := Export(source, &archive, ExportOptions{UserID: int(adaID)})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	// Only Ada's family, and nothing from the trash
+	if len(m.Users) != 1 || len(m.Artists) != 1 || len(m.Artworks) != 1 || len(m.UserArtists) != 1 ||
+		len(m.ArtworkMediums) != 1 || len(m.Images) != 1 {
+		t.Fatalf("exported %d users, %d artists, %d artworks, %d links, %d medium links, %d images; want one of each",
+			len(m.Users), len(m.Artists), len(m.Artworks), len(m.UserArtists), len(m.ArtworkMediums), len(m.Images))
+	}
+
+	// Existing rows in the target push every imported ID along
+	target := openTestDB(t, "target.db")
+	for i := 0; i < 3; i++ {
+		artist := mustInsert(t, target, "INSERT INTO artists (name) VALUES (?)", "Existing")
+		mustInsert(t, target, "INSERT INTO artworks (artist_id, title) VALUES (?, ?)", artist, "Existing")
+	}
+	mustInsert(t, target, "INSERT INTO users (fname, lname, email, pwd) VALUES (?, ?, ?, ?)", "Zed", "Existing", "zed@example.com", "hash-z")
+
+	reader := bytes.NewReader(archive.Bytes())
+	result, err := Import(target, reader, reader.Size(), ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if result.Users != 1 || result.Artists != 1 || result.Artworks != 1 || result.Mediums != 1 || result.Images != 1 {
+		t.Errorf("imported %+v", result)
+	}
+
+	// Every reference points at the new rows
+	var userID, artistID, artworkID int
+	var role, title string
+	var stored []byte
+	err = target.QueryRow(`
+		SELECT u.id, ar.id, a.id, ua.role, a.title, i.original
+		FROM users u
+		JOIN user_artists ua ON ua.user_id = u.id
+		JOIN artists ar ON ar.id = ua.artist_id
+		JOIN artworks a ON a.artist_id = ar.id
+		JOIN artworks_mediums am ON am.artwork_id = a.id
+		JOIN mediums md ON md.id = am.medium_id AND md.name = ?
+		JOIN images i ON i.artwork_id = a.id
+		WHERE u.email = ?
+	`, "Crayon", "ada@example.com").Scan(&userID, &artistID, &artworkID, &role, &title, &stored)
+	if err != nil {
+		t.Fatalf("finding the imported artwork: %v", err)
+	}
+	if userID == m.Users[0].ID || artistID == m.Artists[0].ID || artworkID == m.Artworks[0].ID {
+		t.Errorf("IDs weren't remapped: user %d, artist %d, artwork %d", userID, artistID, artworkID)
+	}
+	if role != "owner" || title != "Birch" || !bytes.Equal(stored, image) {
+		t.Errorf("imported %s artwork %q (original intact: %v)", role, title, bytes.Equal(stored, image))
+	}
+
+	t.Run("skip reuses existing users", func(t *testing.T) {
+		result, err := Import(target, reader, reader.Size(), ImportOptions{OnConflict: OnConflictSkip})
+		if err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		if result.Users != 0 || result.UsersReused != 1 || result.MediumsReused != 1 || result.Artworks != 1 {
+			t.Errorf("imported %+v", result)
+		}
+		if n := count(t, target, "SELECT COUNT(*) FROM users WHERE email = ?", "ada@example.com"); n != 1 {
+			t.Errorf("%d users with Ada's email", n)
+		}
+		if n := count(t, target, "SELECT COUNT(*) FROM user_artists WHERE user_id = ?", userID); n != 2 {
+			t.Errorf("Ada is linked to %d artists, want both imports", n)
+		}
+	})
+
+	t.Run("fail imports nothing", func(t *testing.T) {
+		artworks := count(t, target, "SELECT COUNT(*) FROM artworks")
+		_, err := Import(target, reader, reader.Size(), ImportOptions{OnConflict: OnConflictFail})
+		if !errors.Is(err, ErrConflict) {
+			t.Fatalf("Import = %v, want ErrConflict", err)
+		}
+		if n := count(t, target, "SELECT COUNT(*) FROM artworks"); n != artworks {
+			t.Errorf("%d artworks after a failed import, want %d", n, artworks)
+		}
+	})
+}
@@ -0,0 +1,280 @@
+package archive
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go-art-api/config"
+	"go-art-api/migrations"
+	"go-art-api/models"
+)
+
+// ExportOptions narrows what gets exported
+type ExportOptions struct {
+	// UserID limits the export to that user's family: the artists linked to
+	// the user and every user linked to those artists. 0 exports everything.
+	UserID int
+}
+
+// Export writes a zip archive of the database to w and returns its manifest
+func Export(db *config.Database, w io.Writer, opts ExportOptions) (*Manifest, error) {
+	schemaVersion, err := migrations.CurrentVersion(db.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		FormatVersion: FormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		SourceDialect: db.Dialect.Name(),
+		SchemaVersion: schemaVersion,
+	}
+
+	// Anything in the trash is left out. Scope every query to the family when
+	// a user is given.
+	artistFilter := " WHERE deleted_at IS NULL"
+	artworkFilter := " WHERE deleted_at IS NULL AND artist_id IN (SELECT id FROM artists WHERE deleted_at IS NULL)"
+	userFilter := ""
+	var artistArgs []interface{}
+	if opts.UserID != 0 {
+		artistFilter += " AND id IN (SELECT artist_id FROM user_artists WHERE user_id = ?)"
+		userFilter = " WHERE id IN (SELECT ua2.user_id FROM user_artists ua1 JOIN user_artists ua2 ON ua1.artist_id = ua2.artist_id WHERE ua1.user_id = ?) OR id = ?"
+		artworkFilter += " AND artist_id IN (SELECT artist_id FROM user_artists WHERE user_id = ?)"
+		artistArgs = []interface{}{opts.UserID}
+	}
+
+	if m.Users, err = exportUsers(db, userFilter, opts.UserID); err != nil {
+		return nil, fmt.Errorf("users: %w", err)
+	}
+	if m.Artists, err = exportArtists(db, artistFilter, artistArgs...); err != nil {
+		return nil, fmt.Errorf("artists: %w", err)
+	}
+	if m.Artworks, err = exportArtworks(db, artworkFilter, artistArgs...); err != nil {
+		return nil, fmt.Errorf("artworks: %w", err)
+	}
+	if m.Mediums, err = exportMediums(db); err != nil {
+		return nil, fmt.Errorf("mediums: %w", err)
+	}
+
+	// Links and images follow the artists and artworks exported above
+	artistsOf := " WHERE artist_id IN (SELECT id FROM artists" + artistFilter + ")"
+	artworksOf := " WHERE artwork_id IN (SELECT id FROM artworks" + artworkFilter + ")"
+	if m.UserArtists, err = exportUserArtists(db, artistsOf, artistArgs...); err != nil {
+		return nil, fmt.Errorf("user_artists: %w", err)
+	}
+	if m.ArtworkMediums, err = exportArtworkMediums(db, artworksOf, artistArgs...); err != nil {
+		return nil, fmt.Errorf("artworks_mediums: %w", err)
+	}
+
+	zw := zip.NewWriter(w)
+	if m.Images, err = exportImages(db, zw, artworksOf+" AND deleted_at IS NULL", artistArgs...); err != nil {
+		return nil, fmt.Errorf("images: %w", err)
+	}
+
+	// The manifest goes last so it can list the image files that were written
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: manifestName, Method: zip.Deflate, Modified: m.ExportedAt})
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+
+	return m, zw.Close()
+}
+
+func exportUsers(db *config.Database, filter string, userID int) ([]User, error) {
+	var args []interface{}
+	if filter != "" {
+		args = []interface{}{userID, userID}
+	}
+	rows, err := db.Query("SELECT id, fname, lname, email, pwd, email_verified_at, created_at FROM users"+filter+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		var verifiedAt sql.NullTime
+		if err := rows.Scan(&u.ID, &u.FName, &u.LName, &u.Email, &u.Pwd, &verifiedAt, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		if verifiedAt.Valid {
+			u.EmailVerifiedAt = &verifiedAt.Time
+		}
+		u.Pwd = strings.TrimSpace(u.Pwd) // CHAR columns may come back padded
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func exportArtists(db *config.Database, filter string, args ...interface{}) ([]models.Artist, error) {
+	rows, err := db.Query("SELECT id, name, codename, created_at FROM artists"+filter+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artists := []models.Artist{}
+	for rows.Next() {
+		var a models.Artist
+		var codename sql.NullString
+		if err := rows.Scan(&a.ID, &a.Name, &codename, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.Codename = codename.String
+		artists = append(artists, a)
+	}
+	return artists, rows.Err()
+}
+
+func exportArtworks(db *config.Database, filter string, args ...interface{}) ([]models.Artwork, error) {
+	rows, err := db.Query(
+		"SELECT id, artist_id, grade, school, title, description, auto_enhance, created_at FROM artworks"+filter+" ORDER BY id", args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artworks := []models.Artwork{}
+	for rows.Next() {
+		var a models.Artwork
+		var grade, school, title, description sql.NullString
+		if err := rows.Scan(&a.ID, &a.ArtistID, &grade, &school, &title, &description, &a.AutoEnhance, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.Grade, a.School, a.Title, a.Description = grade.String, school.String, title.String, description.String
+		artworks = append(artworks, a)
+	}
+	return artworks, rows.Err()
+}
+
+func exportMediums(db *config.Database) ([]models.Medium, error) {
+	rows, err := db.Query("SELECT id, name FROM mediums ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mediums := []models.Medium{}
+	for rows.Next() {
+		var m models.Medium
+		if err := rows.Scan(&m.ID, &m.Name); err != nil {
+			return nil, err
+		}
+		mediums = append(mediums, m)
+	}
+	return mediums, rows.Err()
+}
+
+func exportUserArtists(db *config.Database, filter string, args ...interface{}) ([]models.UserArtist, error) {
+	rows, err := db.Query("SELECT user_id, artist_id, role FROM user_artists"+filter+" ORDER BY user_id, artist_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []models.UserArtist{}
+	for rows.Next() {
+		var ua models.UserArtist
+		if err := rows.Scan(&ua.UserID, &ua.ArtistID, &ua.Role); err != nil {
+			return nil, err
+		}
+		links = append(links, ua)
+	}
+	return links, rows.Err()
+}
+
+func exportArtworkMediums(db *config.Database, filter string, args ...interface{}) ([]models.ArtworkMedium, error) {
+	rows, err := db.Query("SELECT artwork_id, medium_id FROM artworks_mediums"+filter+" ORDER BY artwork_id, medium_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []models.ArtworkMedium{}
+	for rows.Next() {
+		var am models.ArtworkMedium
+		if err := rows.Scan(&am.ArtworkID, &am.MediumID); err != nil {
+			return nil, err
+		}
+		links = append(links, am)
+	}
+	return links, rows.Err()
+}
+
+// exportImages streams image BLOBs into the zip one row at a time
+func exportImages(db *config.Database, zw *zip.Writer, filter string, args ...interface{}) ([]Image, error) {
+	rows, err := db.Query(`
+		SELECT id, artwork_id, url, original_mime, thumb, image, original, edits, enhanced_thumb, enhanced_image, created_at
+		FROM images`+filter+` ORDER BY id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := []Image{}
+	for rows.Next() {
+		var img Image
+		var url, edits sql.NullString
+		var thumb, data, original, enhancedThumb, enhancedData []byte
+		if err := rows.Scan(
+			&img.ID, &img.ArtworkID, &url, &img.OriginalMime, &thumb, &data, &original, &edits, &enhancedThumb, &enhancedData, &img.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		img.URL, img.Edits = url.String, edits.String
+
+		if len(thumb) > 0 {
+			img.ThumbFile = fmt.Sprintf("images/%d/thumb.jpg", img.ID)
+			if err := writeStored(zw, img.ThumbFile, thumb); err != nil {
+				return nil, err
+			}
+		}
+		img.ImageFile = fmt.Sprintf("images/%d/image.jpg", img.ID)
+		if err := writeStored(zw, img.ImageFile, data); err != nil {
+			return nil, err
+		}
+		if len(original) > 0 {
+			img.OriginalFile = fmt.Sprintf("images/%d/original", img.ID)
+			if err := writeStored(zw, img.OriginalFile, original); err != nil {
+				return nil, err
+			}
+		}
+		if len(enhancedThumb) > 0 {
+			img.EnhancedThumbFile = fmt.Sprintf("images/%d/enhanced_thumb.jpg", img.ID)
+			if err := writeStored(zw, img.EnhancedThumbFile, enhancedThumb); err != nil {
+				return nil, err
+			}
+		}
+		if len(enhancedData) > 0 {
+			img.EnhancedImageFile = fmt.Sprintf("images/%d/enhanced.jpg", img.ID)
+			if err := writeStored(zw, img.EnhancedImageFile, enhancedData); err != nil {
+				return nil, err
+			}
+		}
+		images = append(images, img)
+	}
+	return images, rows.Err()
+}
+
+// writeStored adds a file without compression (JPEGs don't shrink any further)
+func writeStored(zw *zip.Writer, name string, data []byte) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
@@ -0,0 +1,271 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"go-art-api/config"
+	"go-art-api/utils"
+)
+
+// Conflict policies for users whose email already exists in the target DB
+const (
+	OnConflictSkip = "skip" // reuse the existing user and link them to the imported artists
+	OnConflictFail = "fail" // abort the whole import
+)
+
+// ErrConflict is returned when a conflicting user is found with OnConflictFail
+var ErrConflict = errors.New("import conflict")
+
+// ImportOptions controls conflict handling
+type ImportOptions struct {
+	OnConflict string
+}
+
+// ImportResult counts what was created (or matched) in the target database
+type ImportResult struct {
+	Users         int `json:"users"`
+	UsersReused   int `json:"users_reused"`
+	Artists       int `json:"artists"`
+	Artworks      int `json:"artworks"`
+	Mediums       int `json:"mediums"`
+	MediumsReused int `json:"mediums_reused"`
+	Images        int `json:"images"`
+}
+
+// Import loads an archive written by Export into db. Every row gets a fresh ID
+// in the target database and references are remapped, so an archive can be
+// imported next to existing data or into a different backend. It runs in a
+// single transaction; the storage quota is deliberately not enforced here.
+func Import(db *config.Database, r io.ReaderAt, size int64, opts ImportOptions) (*ImportResult, error) {
+	if opts.OnConflict == "" {
+		opts.OnConflict = OnConflictSkip
+	}
+	if opts.OnConflict != OnConflictSkip && opts.OnConflict != OnConflictFail {
+		return nil, fmt.Errorf("unknown conflict policy %q", opts.OnConflict)
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	m, err := readManifest(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &ImportResult{}
+	userIDs := map[int]int64{}
+	artistIDs := map[int]int64{}
+	artworkIDs := map[int]int64{}
+	mediumIDs := map[int]int64{}
+
+	for _, u := range m.Users {
+		var existingID int64
+		err := tx.QueryRow("SELECT id FROM users WHERE email = ?", u.Email).Scan(&existingID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err == nil {
+			if opts.OnConflict == OnConflictFail {
+				return nil, fmt.Errorf("%w: user %s already exists", ErrConflict, u.Email)
+			}
+			userIDs[u.ID] = existingID
+			result.UsersReused++
+			continue
+		}
+
+		id, err := tx.Insert(
+			"INSERT INTO users (fname, lname, email, pwd, email_verified_at, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			u.FName, u.LName, u.Email, u.Pwd, u.EmailVerifiedAt, u.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %w", u.Email, err)
+		}
+		userIDs[u.ID] = id
+		result.Users++
+	}
+
+	for _, a := range m.Artists {
+		id, err := tx.Insert(
+			"INSERT INTO artists (name, codename, created_at) VALUES (?, ?, ?)",
+			a.Name, nullIfEmpty(a.Codename), a.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("artist %d: %w", a.ID, err)
+		}
+		artistIDs[a.ID] = id
+		result.Artists++
+	}
+
+	for _, md := range m.Mediums {
+		var existingID int64
+		err := tx.QueryRow("SELECT id FROM mediums WHERE name = ?", md.Name).Scan(&existingID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err == nil {
+			mediumIDs[md.ID] = existingID
+			result.MediumsReused++
+			continue
+		}
+		id, err := tx.Insert("INSERT INTO mediums (name) VALUES (?)", md.Name)
+		if err != nil {
+			return nil, fmt.Errorf("medium %s: %w", md.Name, err)
+		}
+		mediumIDs[md.ID] = id
+		result.Mediums++
+	}
+
+	for _, ua := range m.UserArtists {
+		userID, okUser := userIDs[ua.UserID]
+		artistID, okArtist := artistIDs[ua.ArtistID]
+		if !okUser || !okArtist {
+			continue
+		}
+		role := ua.Role
+		if role == "" {
+			role = "owner" // archives from before roles existed
+		}
+		if _, err := tx.Exec("INSERT INTO user_artists (user_id, artist_id, role) VALUES (?, ?, ?)", userID, artistID, role); err != nil {
+			return nil, fmt.Errorf("user_artist %d/%d: %w", ua.UserID, ua.ArtistID, err)
+		}
+	}
+
+	for _, a := range m.Artworks {
+		artistID, ok := artistIDs[a.ArtistID]
+		if !ok {
+			return nil, fmt.Errorf("artwork %d references unknown artist %d", a.ID, a.ArtistID)
+		}
+		id, err := tx.Insert(
+			"INSERT INTO artworks (artist_id, grade, school, title, description, auto_enhance, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			artistID, nullIfEmpty(a.Grade), nullIfEmpty(a.School), nullIfEmpty(a.Title), nullIfEmpty(a.Description), a.AutoEnhance, a.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("artwork %d: %w", a.ID, err)
+		}
+		artworkIDs[a.ID] = id
+		result.Artworks++
+	}
+
+	for _, am := range m.ArtworkMediums {
+		artworkID, okArtwork := artworkIDs[am.ArtworkID]
+		mediumID, okMedium := mediumIDs[am.MediumID]
+		if !okArtwork || !okMedium {
+			continue
+		}
+		if _, err := tx.Exec("INSERT INTO artworks_mediums (artwork_id, medium_id) VALUES (?, ?)", artworkID, mediumID); err != nil {
+			return nil, fmt.Errorf("artwork_medium %d/%d: %w", am.ArtworkID, am.MediumID, err)
+		}
+	}
+
+	for _, img := range m.Images {
+		artworkID, ok := artworkIDs[img.ArtworkID]
+		if !ok {
+			return nil, fmt.Errorf("image %d references unknown artwork %d", img.ID, img.ArtworkID)
+		}
+
+		var thumb []byte
+		if img.ThumbFile != "" {
+			if thumb, err = readFile(zr, img.ThumbFile); err != nil {
+				return nil, err
+			}
+		}
+		data, err := readFile(zr, img.ImageFile)
+		if err != nil {
+			return nil, err
+		}
+		var original []byte
+		if img.OriginalFile != "" {
+			if original, err = readFile(zr, img.OriginalFile); err != nil {
+				return nil, err
+			}
+		}
+		var enhancedThumb, enhancedData []byte
+		if img.EnhancedThumbFile != "" {
+			if enhancedThumb, err = readFile(zr, img.EnhancedThumbFile); err != nil {
+				return nil, err
+			}
+		}
+		if img.EnhancedImageFile != "" {
+			if enhancedData, err = readFile(zr, img.EnhancedImageFile); err != nil {
+				return nil, err
+			}
+		}
+
+		// Hashes, palettes and BlurHashes aren't in the archive; they're quick to make again
+		var hash, palette, blurhash interface{}
+		if h, err := utils.HashImage(bytes.NewReader(data)); err == nil {
+			hash = int64(h)
+		}
+		if colors, err := utils.ImagePalette(bytes.NewReader(thumb)); err == nil {
+			if stored, err := json.Marshal(colors); err == nil {
+				palette = string(stored)
+			}
+		}
+		if b, err := utils.ImageBlurHash(bytes.NewReader(thumb)); err == nil {
+			blurhash = b
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO images (artwork_id, url, original_mime, thumb, image, thumb_bytes, image_bytes, original, original_bytes, edits,
+				enhanced_thumb, enhanced_image, enhanced_thumb_bytes, enhanced_image_bytes, phash, palette, blurhash, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, artworkID, nullIfEmpty(img.URL), img.OriginalMime, thumb, data, len(thumb), len(data),
+			original, len(original), nullIfEmpty(img.Edits),
+			enhancedThumb, enhancedData, len(enhancedThumb), len(enhancedData), hash, palette, blurhash, img.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", img.ID, err)
+		}
+		result.Images++
+	}
+
+	return result, tx.Commit()
+}
+
+// readManifest decodes and sanity-checks the archive manifest
+func readManifest(zr *zip.Reader) (*Manifest, error) {
+	f, err := zr.Open(manifestName)
+	if err != nil {
+		return nil, fmt.Errorf("not a go-art archive: %w", err)
+	}
+	defer f.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if m.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("unsupported archive format version %d (expected %d)", m.FormatVersion, FormatVersion)
+	}
+	return &m, nil
+}
+
+// readFile reads a whole file out of the archive
+func readFile(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("archive file %s: %w", name, err)
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// nullIfEmpty stores empty optional strings as NULL, as the handlers do
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
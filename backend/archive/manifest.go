@@ -0,0 +1,54 @@
+package archive
+
+import (
+	"time"
+
+	"go-art-api/models"
+)
+
+// FormatVersion is bumped whenever the manifest layout changes incompatibly
+const FormatVersion = 1
+
+// manifestName is the JSON manifest's path inside the zip
+const manifestName = "manifest.json"
+
+// Manifest describes everything in an archive except the image bytes, which
+// are stored alongside it as images/<id>/thumb.jpg and images/<id>/image.jpg
+// (plus images/<id>/original, the upload as it came, where one was kept, and
+// images/<id>/enhanced_thumb.jpg and enhanced.jpg for artworks with
+// auto-enhance on).
+// IDs are the source database's IDs and are remapped on import.
+type Manifest struct {
+	FormatVersion  int                    `json:"format_version"`
+	ExportedAt     time.Time              `json:"exported_at"`
+	SourceDialect  string                 `json:"source_dialect"`
+	SchemaVersion  int                    `json:"schema_version"`
+	Users          []User                 `json:"users"`
+	Artists        []models.Artist        `json:"artists"`
+	UserArtists    []models.UserArtist    `json:"user_artists"`
+	Artworks       []models.Artwork       `json:"artworks"`
+	Mediums        []models.Medium        `json:"mediums"`
+	ArtworkMediums []models.ArtworkMedium `json:"artworks_mediums"`
+	Images         []Image                `json:"images"`
+}
+
+// User carries the password hash so accounts keep working after an import
+type User struct {
+	models.User
+	Pwd string `json:"pwd"`
+}
+
+// Image is an images row with its BLOBs replaced by paths inside the archive
+type Image struct {
+	ID                int       `json:"id"`
+	ArtworkID         int       `json:"artwork_id"`
+	URL               string    `json:"url,omitempty"`
+	OriginalMime      string    `json:"mime"`
+	ThumbFile         string    `json:"thumb_file,omitempty"`
+	ImageFile         string    `json:"image_file"`
+	OriginalFile      string    `json:"original_file,omitempty"`
+	Edits             string    `json:"edits,omitempty"` // JSON, how image_file was made from original_file
+	EnhancedThumbFile string    `json:"enhanced_thumb_file,omitempty"`
+	EnhancedImageFile string    `json:"enhanced_image_file,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
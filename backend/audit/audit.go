@@ -0,0 +1,134 @@
+// Package audit records who created, changed or deleted what, with a
+// field-level before/after diff, in the audit_log table.
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"reflect"
+	"time"
+)
+
+// Actions
+const (
+	Create = "create"
+	Update = "update"
+	Delete = "delete"
+)
+
+// Entities
+const (
+	User          = "user"
+	Artist        = "artist"
+	Artwork       = "artwork"
+	Image         = "image"
+	Medium        = "medium"
+	Member        = "member" // a user_artists link; EntityID is the user
+	Invitation    = "invitation"
+	ArtworkMedium = "artwork_medium"
+)
+
+// Execer is satisfied by both *config.Database and *config.Tx, so an entry
+// can be written in the same transaction as the change it describes
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Entry describes one change
+type Entry struct {
+	ActorID  int // 0 for system and anonymous actions
+	Action   string
+	Entity   string
+	EntityID int
+	ArtistID int         // 0 when the change isn't tied to an artist
+	Before   interface{} // nil on create
+	After    interface{} // nil on delete
+}
+
+// Change is one field's old and new value
+type Change struct {
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// Record writes an entry. Errors are logged here; callers in a transaction
+// should also roll back on them so no change goes unrecorded.
+func Record(q Execer, e Entry) error {
+	changes, err := Diff(e.Before, e.After)
+	if err != nil {
+		log.Printf("❌ Audit: can't diff %s %s %d: %v", e.Action, e.Entity, e.EntityID, err)
+		return err
+	}
+
+	var changesJSON interface{}
+	if len(changes) > 0 {
+		data, err := json.Marshal(changes)
+		if err != nil {
+			log.Printf("❌ Audit: can't encode changes to %s %d: %v", e.Entity, e.EntityID, err)
+			return err
+		}
+		changesJSON = string(data)
+	}
+
+	_, err = q.Exec(
+		"INSERT INTO audit_log (created_at, actor_id, action, entity, entity_id, artist_id, changes) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		time.Now().UTC(), nullIfZero(e.ActorID), e.Action, e.Entity, e.EntityID, nullIfZero(e.ArtistID), changesJSON,
+	)
+	if err != nil {
+		log.Printf("❌ Audit: can't record %s %s %d: %v", e.Action, e.Entity, e.EntityID, err)
+	}
+	return err
+}
+
+// Diff compares the JSON forms of before and after and returns the fields
+// that differ. Either side may be nil. id and created_at are left out since
+// they never change and the entry already carries them.
+func Diff(before, after interface{}) (map[string]Change, error) {
+	from, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]Change{}
+	for k, v := range from {
+		if w, ok := to[k]; !ok || !reflect.DeepEqual(v, w) {
+			changes[k] = Change{From: v, To: to[k]}
+		}
+	}
+	for k, w := range to {
+		if _, ok := from[k]; !ok {
+			changes[k] = Change{To: w}
+		}
+	}
+	return changes, nil
+}
+
+// fields flattens a value to its top-level JSON fields
+func fields(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	delete(m, "id")
+	delete(m, "created_at")
+	return m, nil
+}
+
+func nullIfZero(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
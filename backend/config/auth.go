@@ -0,0 +1,142 @@
+package config
+
+import (
+	"crypto/rand"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Token lifetimes. Access tokens are short-lived; refresh tokens rotate on use.
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	defaultImageURLTTL     = time.Hour
+)
+
+var (
+	jwtSecret     []byte
+	jwtSecretOnce sync.Once
+)
+
+// JWTSecret returns the HMAC key for access tokens (JWT_SECRET). Without one,
+// a random key is generated, which logs everyone out on every restart.
+func JWTSecret() []byte {
+	jwtSecretOnce.Do(func() {
+		if secret := os.Getenv("JWT_SECRET"); secret != "" {
+			jwtSecret = []byte(secret)
+			return
+		}
+		log.Println("⚠️  JWT_SECRET is not set, using a random key (sessions won't survive a restart)")
+		jwtSecret = make([]byte, 32)
+		if _, err := rand.Read(jwtSecret); err != nil {
+			log.Fatal("❌ Failed to generate JWT secret:", err)
+		}
+	})
+	return jwtSecret
+}
+
+// ImageURLSecret returns the HMAC key for signed image URLs
+// (IMAGE_URL_SECRET), or the JWT secret without one
+func ImageURLSecret() []byte {
+	if secret := os.Getenv("IMAGE_URL_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return JWTSecret()
+}
+
+// ImageURLTTL is roughly how long signed image URLs work (IMAGE_URL_TTL,
+// default 1h). Each one lasts between one and two TTLs, so the same URL is
+// handed out, and cached by browsers, for a TTL at a time.
+func ImageURLTTL() time.Duration {
+	return envDuration("IMAGE_URL_TTL", defaultImageURLTTL)
+}
+
+// AccessTokenTTL returns how long access tokens are valid (ACCESS_TOKEN_TTL)
+func AccessTokenTTL() time.Duration {
+	return envDuration("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
+}
+
+// RefreshTokenTTL returns how long an unused session stays valid (REFRESH_TOKEN_TTL)
+func RefreshTokenTTL() time.Duration {
+	return envDuration("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+}
+
+// envDuration reads a Go duration (e.g. "15m", "720h") from the environment
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("⚠️  Invalid %s=%q, using default of %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// TrustedProxies is how many reverse proxies in front of the server append to
+// X-Forwarded-For (TRUST_PROXY: true for one, or a number). 0, the default,
+// ignores the header. Only count proxies that really are there: anything to
+// the left of their entries is whatever the client sent.
+func TrustedProxies() int {
+	value := os.Getenv("TRUST_PROXY")
+	switch value {
+	case "", "false":
+		return 0
+	case "true":
+		return 1
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("⚠️  Invalid TRUST_PROXY=%q, ignoring X-Forwarded-For", value)
+		return 0
+	}
+	return n
+}
+
+// HashConcurrency is how many Argon2 hashes may run at once
+// (ARGON2_MAX_CONCURRENT, default the number of CPUs)
+func HashConcurrency() int {
+	if n, err := strconv.Atoi(os.Getenv("ARGON2_MAX_CONCURRENT")); err == nil && n > 0 {
+		return n
+	}
+	return runtime.NumCPU()
+}
+
+// Argon2 defaults (RFC 9106's second recommended option, as before)
+const (
+	defaultArgon2MemoryKB   = 64 * 1024
+	defaultArgon2Iterations = 1
+	defaultArgon2Threads    = 4
+)
+
+// Argon2Params returns the hashing policy for new and upgraded passwords:
+// ARGON2_MEMORY_KB, ARGON2_ITERATIONS and ARGON2_PARALLELISM. Existing hashes
+// are re-hashed with these on the user's next successful login.
+func Argon2Params() (memoryKB, iterations uint32, threads uint8) {
+	memoryKB = uint32(envUint("ARGON2_MEMORY_KB", defaultArgon2MemoryKB, 8*1024, 4*1024*1024))
+	iterations = uint32(envUint("ARGON2_ITERATIONS", defaultArgon2Iterations, 1, 100))
+	threads = uint8(envUint("ARGON2_PARALLELISM", defaultArgon2Threads, 1, 255))
+	return memoryKB, iterations, threads
+}
+
+// envUint reads a bounded positive integer from the environment
+func envUint(key string, fallback, min, max uint64) uint64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || n < min || n > max {
+		log.Printf("⚠️  Invalid %s=%q (must be %d-%d), using default of %d", key, value, min, max, fallback)
+		return fallback
+	}
+	return n
+}
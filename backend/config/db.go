@@ -0,0 +1,97 @@
+package config
+
+import (
+	"database/sql"
+	"strings"
+
+	"go-art-api/dialect"
+)
+
+// Database wraps *sql.DB so handlers can keep writing `?` queries while the
+// active dialect takes care of placeholders, inserted IDs and error codes.
+type Database struct {
+	*sql.DB
+	Dialect dialect.Dialect
+}
+
+// Exec runs a statement after rebinding its placeholders
+func (d *Database) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.DB.Exec(d.Dialect.Rebind(query), args...)
+}
+
+// Query runs a query after rebinding its placeholders
+func (d *Database) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.DB.Query(d.Dialect.Rebind(query), args...)
+}
+
+// QueryRow runs a single-row query after rebinding its placeholders
+func (d *Database) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.DB.QueryRow(d.Dialect.Rebind(query), args...)
+}
+
+// Insert runs an INSERT into a table with an `id` column and returns the new ID
+func (d *Database) Insert(query string, args ...interface{}) (int64, error) {
+	return insert(d.DB, d.Dialect, query, args...)
+}
+
+// IsUniqueViolation reports whether err is a unique constraint violation
+func (d *Database) IsUniqueViolation(err error) bool {
+	return err != nil && d.Dialect.IsUniqueViolation(err)
+}
+
+// Begin starts a dialect-aware transaction
+func (d *Database) Begin() (*Tx, error) {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, Dialect: d.Dialect}, nil
+}
+
+// Tx is the transaction counterpart of Database
+type Tx struct {
+	*sql.Tx
+	Dialect dialect.Dialect
+}
+
+// Exec runs a statement after rebinding its placeholders
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(t.Dialect.Rebind(query), args...)
+}
+
+// Query runs a query after rebinding its placeholders
+func (t *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(t.Dialect.Rebind(query), args...)
+}
+
+// QueryRow runs a single-row query after rebinding its placeholders
+func (t *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(t.Dialect.Rebind(query), args...)
+}
+
+// Insert runs an INSERT into a table with an `id` column and returns the new ID
+func (t *Tx) Insert(query string, args ...interface{}) (int64, error) {
+	return insert(t.Tx, t.Dialect, query, args...)
+}
+
+// execQuerier is satisfied by both *sql.DB and *sql.Tx
+type execQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insert uses LastInsertId where supported and RETURNING id everywhere else
+func insert(q execQuerier, d dialect.Dialect, query string, args ...interface{}) (int64, error) {
+	if d.SupportsLastInsertID() {
+		result, err := q.Exec(d.Rebind(query), args...)
+		if err != nil {
+			return 0, err
+		}
+		return result.LastInsertId()
+	}
+
+	query = strings.TrimRight(strings.TrimSpace(query), ";") + " RETURNING id"
+	var id int64
+	err := q.QueryRow(d.Rebind(query), args...).Scan(&id)
+	return id, err
+}
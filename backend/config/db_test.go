@@ -0,0 +1,86 @@
+package config
+
+import (
+	"database/sql"
+	"testing"
+
+	"go-art-api/dialect"
+)
+
+// numberedSQLite is SQLite with PostgreSQL's $1 placeholders, which SQLite
+// also understands, so the wrappers can be seen to rebind without a server
+type numberedSQLite struct{ dialect.SQLite }
+
+func (numberedSQLite) Rebind(query string) string { return dialect.Postgres{}.Rebind(query) }
+
+func openTestDB(t *testing.T, d dialect.Dialect) *Database {
+	t.Helper()
+	db, err := sql.Open(d.Driver(), "file::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) // every connection would get its own in-memory database
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec("CREATE TABLE artists (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE, grade TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	return &Database{DB: db, Dialect: d}
+}
+
+func TestDatabaseRebinds(t *testing.T) {
+	for _, d := range []dialect.Dialect{dialect.SQLite{}, numberedSQLite{}} {
+		t.Run(d.Name()+"/"+d.Rebind("?"), func(t *testing.T) {
+			db := openTestDB(t, d)
+
+			id, err := db.Insert("INSERT INTO artists (name, grade) VALUES (?, ?)", "Ada", "3")
+			if err != nil {
+				t.Fatalf("Insert: %v", err)
+			}
+			if _, err := db.Exec("UPDATE artists SET grade = ? WHERE id = ?", "4", id); err != nil {
+				t.Fatalf("Exec: %v", err)
+			}
+
+			var name, grade string
+			if err := db.QueryRow("SELECT name, grade FROM artists WHERE id = ? AND name = ?", id, "Ada").Scan(&name, &grade); err != nil {
+				t.Fatalf("QueryRow: %v", err)
+			}
+			if name != "Ada" || grade != "4" {
+				t.Errorf("got %s in grade %s, want Ada in grade 4", name, grade)
+			}
+
+			// A ? inside a string literal is left alone
+			var literal string
+			if err := db.QueryRow("SELECT '?' || name FROM artists WHERE id = ?", id).Scan(&literal); err != nil {
+				t.Fatalf("QueryRow with literal: %v", err)
+			}
+			if literal != "?Ada" {
+				t.Errorf("got %q, want %q", literal, "?Ada")
+			}
+
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			if _, err := tx.Insert("INSERT INTO artists (name) VALUES (?)", "Grace"); err != nil {
+				t.Fatalf("Tx.Insert: %v", err)
+			}
+			_, err = tx.Exec("INSERT INTO artists (name) VALUES (?)", "Ada")
+			if !db.IsUniqueViolation(err) {
+				t.Errorf("duplicate name in a transaction: got %v, want a unique violation", err)
+			}
+			var count int
+			if err := tx.QueryRow("SELECT COUNT(*) FROM artists WHERE name <> ?", "").Scan(&count); err != nil || count != 2 {
+				t.Errorf("Tx.QueryRow = %d, %v; want 2 artists", count, err)
+			}
+		})
+	}
+}
+
+func TestDatabaseIsUniqueViolationNil(t *testing.T) {
+	db := &Database{Dialect: dialect.SQLite{}}
+	if db.IsUniqueViolation(nil) {
+		t.Error("IsUniqueViolation(nil) = true")
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go-art-api/mailer"
+)
+
+var (
+	mailerInstance mailer.Mailer
+	mailerOnce     sync.Once
+)
+
+// Mailer returns the configured mailer. MAILER picks the transport:
+//   - smtp: SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME, SMTP_PASSWORD
+//   - file: writes .eml files to MAIL_DIR (default DATA_DIR/mail or ./mail)
+//   - log (default): prints messages to the server log, tokens redacted
+//
+// main calls it at startup, so an unknown MAILER stops the server there
+// rather than in the middle of sending someone's mail.
+func Mailer() mailer.Mailer {
+	mailerOnce.Do(func() {
+		from := os.Getenv("MAIL_FROM")
+		if from == "" {
+			from = "Go Art <noreply@localhost>"
+		}
+
+		switch os.Getenv("MAILER") {
+		case "smtp":
+			port := os.Getenv("SMTP_PORT")
+			if port == "" {
+				port = "587"
+			}
+			mailerInstance = mailer.SMTPMailer{
+				Host:     os.Getenv("SMTP_HOST"),
+				Port:     port,
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+				From:     from,
+			}
+			log.Printf("📧 Sending mail via SMTP (%s)", os.Getenv("SMTP_HOST"))
+		case "file":
+			dir := os.Getenv("MAIL_DIR")
+			if dir == "" {
+				dir = "mail"
+				if DataDir() != "" {
+					dir = filepath.Join(DataDir(), "mail")
+				}
+			}
+			mailerInstance = mailer.FileMailer{Dir: dir, From: from}
+			log.Printf("📧 Writing mail to %s", dir)
+		case "", "log":
+			mailerInstance = mailer.LogMailer{}
+			log.Printf("📧 Logging mail with link tokens redacted; set MAILER=smtp to deliver it")
+		default:
+			log.Fatalf("❌ Unknown MAILER %q (expected smtp, file or log)", os.Getenv("MAILER"))
+		}
+	})
+	return mailerInstance
+}
+
+// AppURL is the public base URL used for links in emails (APP_URL)
+func AppURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "http://localhost:8080"
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Storage defaults (in MB). The MySQL host has a hard 3GB limit, so the family
+// quota defaults to a slice of that rather than the whole thing.
+const (
+	defaultStorageLimitMB = 3 * 1024
+	defaultFamilyQuotaMB  = 500
+	defaultImageCacheMB   = 64
+
+	defaultTrashRetention = 30 * 24 * time.Hour
+)
+
+// StorageLimitBytes returns the overall DB/storage limit (STORAGE_LIMIT_MB)
+func StorageLimitBytes() int64 {
+	return envMegabytes("STORAGE_LIMIT_MB", defaultStorageLimitMB)
+}
+
+// FamilyQuotaBytes returns the per-family storage quota (FAMILY_QUOTA_MB).
+// A value of 0 disables quota enforcement.
+func FamilyQuotaBytes() int64 {
+	return envMegabytes("FAMILY_QUOTA_MB", defaultFamilyQuotaMB)
+}
+
+// ImageCacheBytes returns how much image data is kept in memory
+// (IMAGE_CACHE_MB). A value of 0 disables the cache.
+func ImageCacheBytes() int64 {
+	return envMegabytes("IMAGE_CACHE_MB", defaultImageCacheMB)
+}
+
+// TrashRetention returns how long deleted artists, artworks and images stay
+// restorable before they are purged for good (TRASH_RETENTION)
+func TrashRetention() time.Duration {
+	return envDuration("TRASH_RETENTION", defaultTrashRetention)
+}
+
+// envMegabytes reads a size in MB from the environment and returns it in bytes
+func envMegabytes(key string, fallback int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback << 20
+	}
+
+	mb, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || mb < 0 {
+		log.Printf("⚠️  Invalid %s=%q, using default of %dMB", key, value, fallback)
+		return fallback << 20
+	}
+	return mb << 20
+}
@@ -0,0 +1,78 @@
+package dialect
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect hides the SQL differences between the supported databases.
+// Queries in the handlers are written MySQL-style with `?` placeholders and
+// rebound for the active dialect.
+type Dialect interface {
+	// Name is the dialect name, also used as the migrations directory
+	Name() string
+	// Driver is the database/sql driver name
+	Driver() string
+	// Rebind converts `?` placeholders to the dialect's placeholder style
+	Rebind(query string) string
+	// SupportsLastInsertID reports whether sql.Result.LastInsertId works
+	SupportsLastInsertID() bool
+	// IsUniqueViolation reports whether err is a unique constraint violation
+	IsUniqueViolation(err error) bool
+	// TransactionalDDL reports whether schema changes can be rolled back
+	TransactionalDDL() bool
+	// DatabaseSizeQuery returns a query yielding the database's size in bytes
+	DatabaseSizeQuery() string
+}
+
+// FromURL picks a dialect from the DATABASE_URL scheme and returns the DSN the
+// driver expects. URLs without a scheme are treated as MySQL DSNs for
+// backwards compatibility with existing .env files. SQLite URLs take a file
+// path, e.g. sqlite://data/go-art.db or sqlite:///var/lib/go-art/go-art.db.
+func FromURL(databaseURL string) (Dialect, string, error) {
+	scheme, rest, found := strings.Cut(databaseURL, "://")
+	if !found {
+		return MySQL{}, databaseURL, nil
+	}
+
+	switch scheme {
+	case "mysql":
+		return MySQL{}, rest, nil
+	case "postgres", "postgresql":
+		return Postgres{}, databaseURL, nil
+	case "sqlite", "sqlite3":
+		if rest == "" {
+			return nil, "", fmt.Errorf("sqlite URL needs a file path")
+		}
+		return SQLite{}, sqliteDSN(rest), nil
+	default:
+		return nil, "", fmt.Errorf("unsupported database scheme %q", scheme)
+	}
+}
+
+// rebindNumbered replaces `?` with $1, $2, ... skipping quoted strings
+func rebindNumbered(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	var quote rune
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,32 @@
+package dialect
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlDuplicateEntry is ER_DUP_ENTRY ("Duplicate entry ... for key ...")
+const mysqlDuplicateEntry = 1062
+
+// MySQL is the original (and default) dialect
+type MySQL struct{}
+
+func (MySQL) Name() string               { return "mysql" }
+func (MySQL) Driver() string             { return "mysql" }
+func (MySQL) Rebind(query string) string { return query }
+func (MySQL) SupportsLastInsertID() bool { return true }
+func (MySQL) TransactionalDDL() bool     { return false }
+
+func (MySQL) IsUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry
+}
+
+func (MySQL) DatabaseSizeQuery() string {
+	return `
+		SELECT COALESCE(SUM(data_length + index_length), 0)
+		FROM information_schema.tables
+		WHERE table_schema = DATABASE()
+	`
+}
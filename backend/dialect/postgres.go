@@ -0,0 +1,29 @@
+package dialect
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" database/sql driver
+)
+
+// pgUniqueViolation is SQLSTATE unique_violation
+const pgUniqueViolation = "23505"
+
+// Postgres uses the pgx driver through database/sql
+type Postgres struct{}
+
+func (Postgres) Name() string               { return "postgres" }
+func (Postgres) Driver() string             { return "pgx" }
+func (Postgres) Rebind(query string) string { return rebindNumbered(query) }
+func (Postgres) SupportsLastInsertID() bool { return false }
+func (Postgres) TransactionalDDL() bool     { return true }
+
+func (Postgres) IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
+
+func (Postgres) DatabaseSizeQuery() string {
+	return "SELECT pg_database_size(current_database())"
+}
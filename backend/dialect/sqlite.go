@@ -0,0 +1,45 @@
+package dialect
+
+import (
+	"errors"
+	"strings"
+
+	"modernc.org/sqlite" // pure Go, so the netgo/CGO-free build still works
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqlitePragmas are applied to every connection: enforce foreign keys (needed
+// for ON DELETE CASCADE), wait on locks instead of failing, and use WAL so
+// readers don't block the writer.
+const sqlitePragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+// SQLite is the embedded, single-file database for local installs
+type SQLite struct{}
+
+func (SQLite) Name() string               { return "sqlite" }
+func (SQLite) Driver() string             { return "sqlite" }
+func (SQLite) Rebind(query string) string { return query }
+func (SQLite) SupportsLastInsertID() bool { return true }
+func (SQLite) TransactionalDDL() bool     { return true }
+
+func (SQLite) IsUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code()
+	return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+func (SQLite) DatabaseSizeQuery() string {
+	return "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()"
+}
+
+// sqliteDSN turns a file path into a DSN with our connection pragmas
+func sqliteDSN(path string) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return "file:" + path + sep + sqlitePragmas
+}
@@ -0,0 +1,50 @@
+package dialect
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestSQLiteIsUniqueViolation(t *testing.T) {
+	db, err := sql.Open(SQLite{}.Driver(), "file::memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1) // every connection would get its own in-memory database
+
+	for _, stmt := range []string{
+		"CREATE TABLE artists (id INTEGER PRIMARY KEY, name TEXT NOT NULL)",
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL UNIQUE)",
+		"CREATE TABLE user_artists (user_id INTEGER REFERENCES users (id), artist_id INTEGER REFERENCES artists (id))",
+		"INSERT INTO artists (id, name) VALUES (1, 'Ada')",
+		"INSERT INTO users (id, email) VALUES (1, 'parent@example.com')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		stmt string
+		want bool
+	}{
+		{"unique column", "INSERT INTO users (id, email) VALUES (2, 'parent@example.com')", true},
+		{"primary key", "INSERT INTO artists (id, name) VALUES (1, 'Grace')", true},
+		{"not null", "INSERT INTO artists (id, name) VALUES (2, NULL)", false},
+		{"foreign key", "INSERT INTO user_artists (user_id, artist_id) VALUES (1, 99)", false},
+		{"syntax", "INSERT INTO nowhere VALUES (1)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.Exec(tt.stmt)
+			if err == nil {
+				t.Fatalf("%s succeeded, want an error", tt.stmt)
+			}
+			if got := (SQLite{}).IsUniqueViolation(err); got != tt.want {
+				t.Errorf("IsUniqueViolation(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go-art-api/audit"
+	"go-art-api/config"
+	"go-art-api/mailer"
+	"go-art-api/models"
+	"go-art-api/utils"
+)
+
+// account_tokens purposes and lifetimes
+const (
+	purposePasswordReset = "password_reset"
+	purposeVerifyEmail   = "verify_email"
+
+	passwordResetTTL = time.Hour
+	verifyEmailTTL   = 48 * time.Hour
+
+	accountTokenLength = 43 // ~256 bits of base64url
+)
+
+var errInvalidAccountToken = errors.New("invalid or expired token")
+
+// RequestPasswordReset emails a reset link. It answers the same way whether or
+// not the address has an account, so it can't be used to discover users.
+func RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if !allowRequest(w, mailIPLimiter, clientIP(r)) {
+		return
+	}
+
+	var req models.PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		sendErrorResponse(w, "email is required", http.StatusBadRequest)
+		return
+	}
+	if !allowRequest(w, mailEmailLimiter, accountKey(req.Email)) {
+		return
+	}
+
+	var userID int
+	var fname string
+	err := config.DB.QueryRow("SELECT id, fname FROM users WHERE email = ?", req.Email).Scan(&userID, &fname)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("DB error looking up user for password reset: %v", err)
+		sendErrorResponse(w, "Failed to request password reset", http.StatusInternalServerError)
+		return
+	}
+
+	if err == nil {
+		token, err := createAccountToken(userID, purposePasswordReset, passwordResetTTL)
+		if err != nil {
+			log.Printf("Error creating password reset token for user %d: %v", userID, err)
+			sendErrorResponse(w, "Failed to request password reset", http.StatusInternalServerError)
+			return
+		}
+
+		link := fmt.Sprintf("%s/reset-password?token=%s", config.AppURL(), token)
+		sendMail(mailer.Message{
+			To:      req.Email,
+			Subject: "Reset your Go Art password",
+			Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your Go Art account. "+
+				"To choose a new one, open this link within the next hour:\n\n%s\n\n"+
+				"If this wasn't you, you can ignore this email; your password hasn't changed.\n", fname, link),
+		})
+	}
+
+	sendSuccessResponse(w, nil, "If that email has an account, a reset link is on its way", http.StatusAccepted)
+}
+
+// ConfirmPasswordReset sets a new password using a reset token and signs the
+// user out everywhere, since whoever had the old password may still be in.
+func ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if !allowRequest(w, mailIPLimiter, clientIP(r)) {
+		return
+	}
+
+	var req models.PasswordResetConfirm
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		sendErrorResponse(w, "token and password are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Password) < 8 {
+		sendErrorResponse(w, "password must be at least 8 characters", http.StatusBadRequest)
+		return
+	}
+
+	// The token is checked before the password is hashed, so made-up tokens
+	// can't be used to keep the server busy hashing. Hashing happens outside
+	// the transaction, which would otherwise hold the write lock (all of
+	// SQLite) for as long as it takes.
+	if _, _, err := checkAccountToken(config.DB, req.Token, purposePasswordReset); err == errInvalidAccountToken {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("DB error checking password reset token: %v", err)
+		sendErrorResponse(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		sendPasswordHashError(w, err, "Failed to reset password")
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Used up for real now, in case another request got there while hashing
+	userID, err := consumeAccountToken(tx, req.Token, purposePasswordReset)
+	if err == errInvalidAccountToken {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("DB error consuming password reset token: %v", err)
+		sendErrorResponse(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	// Following the emailed link proves the address too
+	if _, err := tx.Exec(
+		"UPDATE users SET pwd = ?, must_reset_password = ?, email_verified_at = COALESCE(email_verified_at, ?) WHERE id = ?",
+		hashedPassword, false, now, userID,
+	); err != nil {
+		log.Printf("DB error resetting password for user %d: %v", userID, err)
+		sendErrorResponse(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, userID); err != nil {
+		log.Printf("DB error revoking sessions for user %d: %v", userID, err)
+		sendErrorResponse(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	if err := audit.Record(tx, audit.Entry{
+		ActorID: userID, Action: audit.Update, Entity: audit.User, EntityID: userID,
+		After: map[string]string{"password": "reset"},
+	}); err != nil {
+		sendErrorResponse(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		sendErrorResponse(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, nil, "Password has been reset, please log in again", http.StatusOK)
+}
+
+// ResendVerificationEmail sends the signed-in user a new verification link
+func ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	var email, fname string
+	var verifiedAt sql.NullTime
+	err := config.DB.QueryRow("SELECT email, fname, email_verified_at FROM users WHERE id = ?", auth.UserID).
+		Scan(&email, &fname, &verifiedAt)
+	if err != nil {
+		log.Printf("DB error looking up user %d for verification: %v", auth.UserID, err)
+		sendErrorResponse(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+	if verifiedAt.Valid {
+		sendSuccessResponse(w, nil, "Email is already verified", http.StatusOK)
+		return
+	}
+	if !allowRequest(w, mailEmailLimiter, accountKey(email)) {
+		return
+	}
+
+	if err := sendVerificationEmail(auth.UserID, email, fname); err != nil {
+		log.Printf("Error creating verification token for user %d: %v", auth.UserID, err)
+		sendErrorResponse(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, nil, "Verification email sent", http.StatusAccepted)
+}
+
+// ConfirmEmailVerification marks the user's email as verified
+func ConfirmEmailVerification(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailConfirm
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		sendErrorResponse(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeAccountToken(tx, req.Token, purposeVerifyEmail)
+	if err == errInvalidAccountToken {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("DB error consuming verification token: %v", err)
+		sendErrorResponse(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE users SET email_verified_at = COALESCE(email_verified_at, ?) WHERE id = ?",
+		time.Now().UTC(), userID,
+	); err != nil {
+		log.Printf("DB error verifying email for user %d: %v", userID, err)
+		sendErrorResponse(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+	if err := audit.Record(tx, audit.Entry{
+		ActorID: userID, Action: audit.Update, Entity: audit.User, EntityID: userID,
+		After: map[string]bool{"email_verified": true},
+	}); err != nil {
+		sendErrorResponse(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		sendErrorResponse(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, nil, "Email verified", http.StatusOK)
+}
+
+// sendVerificationEmail creates a verification token and mails the link
+func sendVerificationEmail(userID int, email, fname string) error {
+	token, err := createAccountToken(userID, purposeVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", config.AppURL(), token)
+	sendMail(mailer.Message{
+		To:      email,
+		Subject: "Confirm your Go Art email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm this is your email address by opening this link "+
+			"within the next two days:\n\n%s\n\nIf you didn't create a Go Art account, you can ignore this email.\n", fname, link),
+	})
+	return nil
+}
+
+// createAccountToken issues a new single-use token, replacing any unused ones
+// for the same purpose so only the most recent link works
+func createAccountToken(userID int, purpose string, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateSecureToken(accountTokenLength)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	if _, err := config.DB.Exec(
+		"UPDATE account_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL",
+		now, userID, purpose,
+	); err != nil {
+		return "", err
+	}
+	if _, err := config.DB.Exec(
+		"INSERT INTO account_tokens (user_id, purpose, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		userID, purpose, utils.HashToken(token), now, now.Add(ttl),
+	); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// checkAccountToken returns the ID and user of an unused, unexpired token
+// without using it up
+func checkAccountToken(q queryer, token, purpose string) (id, userID int, err error) {
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err = q.QueryRow(
+		"SELECT id, user_id, expires_at, used_at FROM account_tokens WHERE token_hash = ? AND purpose = ?",
+		utils.HashToken(token), purpose,
+	).Scan(&id, &userID, &expiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return 0, 0, errInvalidAccountToken
+	} else if err != nil {
+		return 0, 0, err
+	}
+	if usedAt.Valid || time.Now().After(expiresAt) {
+		return 0, 0, errInvalidAccountToken
+	}
+	return id, userID, nil
+}
+
+// consumeAccountToken marks a token used and returns its user. The conditional
+// update makes a token good for exactly one request even under concurrency.
+func consumeAccountToken(tx *config.Tx, token, purpose string) (int, error) {
+	id, userID, err := checkAccountToken(tx, token, purpose)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec("UPDATE account_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return 0, errInvalidAccountToken
+	}
+	return userID, nil
+}
+
+// sendMail delivers in the background so a slow SMTP server doesn't hold up
+// the response (or reveal through timing whether an account exists)
+func sendMail(msg mailer.Message) {
+	go func() {
+		if err := config.Mailer().Send(msg); err != nil {
+			log.Printf("❌ Failed to send %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
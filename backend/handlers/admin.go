@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-art-api/config"
+	"go-art-api/mailer"
+	"go-art-api/models"
+
+	"github.com/gorilla/mux"
+)
+
+// DisableUser blocks an account from signing in and ends its sessions
+func DisableUser(w http.ResponseWriter, r *http.Request) {
+	before, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+	id := before.ID
+
+	now := time.Now().UTC()
+	user, err := changeUser(r, before, true, "UPDATE users SET disabled_at = COALESCE(disabled_at, ?) WHERE id = ?", now, id)
+	if err != nil {
+		log.Printf("DB error disabling user %d: %v", id, err)
+		sendErrorResponse(w, "Failed to disable user", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("🚫 User %d disabled", id)
+	sendSuccessResponse(w, user, "User disabled", http.StatusOK)
+}
+
+// EnableUser lets a disabled account sign in again
+func EnableUser(w http.ResponseWriter, r *http.Request) {
+	before, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+	id := before.ID
+
+	user, err := changeUser(r, before, false, "UPDATE users SET disabled_at = NULL WHERE id = ?", id)
+	if err != nil {
+		log.Printf("DB error enabling user %d: %v", id, err)
+		sendErrorResponse(w, "Failed to enable user", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, user, "User enabled", http.StatusOK)
+}
+
+// ForcePasswordReset signs a user out everywhere and blocks login until they
+// choose a new password through the emailed reset link
+func ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	before, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+	id := before.ID
+
+	user, err := changeUser(r, before, true, "UPDATE users SET must_reset_password = ? WHERE id = ?", true, id)
+	if err != nil {
+		log.Printf("DB error forcing password reset for user %d: %v", id, err)
+		sendErrorResponse(w, "Failed to force password reset", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := createAccountToken(id, purposePasswordReset, passwordResetTTL)
+	if err != nil {
+		log.Printf("Error creating password reset token for user %d: %v", id, err)
+		sendErrorResponse(w, "Password reset forced, but the email could not be sent", http.StatusInternalServerError)
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", config.AppURL(), token)
+	sendMail(mailer.Message{
+		To:      before.Email,
+		Subject: "Please reset your Go Art password",
+		Body: fmt.Sprintf("Hi %s,\n\nAn administrator has asked you to choose a new password for your Go Art account. "+
+			"You won't be able to log in until you do. Open this link within the next hour:\n\n%s\n\n"+
+			"If the link expires, request a new one from the login page.\n", before.FName, link),
+	})
+
+	sendSuccessResponse(w, user, "Password reset required, reset link sent", http.StatusOK)
+}
+
+// adminTarget loads the user in {id} that an admin action applies to. Admins
+// can't act on themselves, so nobody locks out the last working admin account.
+func adminTarget(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid user ID", http.StatusBadRequest)
+		return models.User{}, false
+	}
+	if auth, _ := currentAuth(r); auth.UserID == id {
+		sendErrorResponse(w, "You can't do that to your own account", http.StatusConflict)
+		return models.User{}, false
+	}
+
+	user, err := fetchUser(config.DB, id)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "User not found", http.StatusNotFound)
+		return user, false
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to check user", http.StatusInternalServerError)
+		return user, false
+	}
+	return user, true
+}
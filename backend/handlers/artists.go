@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-art-api/audit"
+	"go-art-api/config"
+	"go-art-api/models"
+
+	"github.com/gorilla/mux"
+)
+
+var errLastOwner = errors.New("an artist must keep at least one owner")
+
+// GetArtists lists the artists the signed-in user can access, with their role
+func GetArtists(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	rows, err := config.DB.Query(`
+		SELECT a.id, a.name, a.codename, a.created_at, ua.role
+		FROM artists a
+		JOIN user_artists ua ON ua.artist_id = a.id
+		WHERE ua.user_id = ? AND a.deleted_at IS NULL
+		ORDER BY a.name, a.id
+	`, auth.UserID)
+	if err != nil {
+		log.Printf("DB error fetching artists: %v", err)
+		sendErrorResponse(w, "Failed to fetch artists", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	artists := []models.ArtistAccess{}
+	for rows.Next() {
+		var a models.ArtistAccess
+		var codename sql.NullString
+		if err := rows.Scan(&a.ID, &a.Name, &codename, &a.CreatedAt, &a.Role); err != nil {
+			sendErrorResponse(w, "Failed to scan artist data", http.StatusInternalServerError)
+			return
+		}
+		a.Codename = codename.String
+		artists = append(artists, a)
+	}
+
+	sendJSONResponse(w, artists, http.StatusOK)
+}
+
+// CreateArtist adds an artist owned by the signed-in user
+func CreateArtist(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	var artist models.Artist
+	if err := json.NewDecoder(r.Body).Decode(&artist); err != nil {
+		sendErrorResponse(w, "Invalid JSON data", http.StatusBadRequest)
+		return
+	}
+	if len(artist.Name) == 0 || len(artist.Name) > 60 || len(artist.Codename) > 60 {
+		sendErrorResponse(w, "name must be 1-60 characters and codename at most 60", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to create artist", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	id, err := tx.Insert("INSERT INTO artists (name, codename) VALUES (?, ?)", artist.Name, nullIfEmpty(artist.Codename))
+	if err != nil {
+		log.Printf("DB error creating artist: %v", err)
+		sendErrorResponse(w, "Failed to create artist", http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO user_artists (user_id, artist_id, role) VALUES (?, ?, ?)", auth.UserID, id, RoleOwner,
+	); err != nil {
+		log.Printf("DB error linking artist %d to user %d: %v", id, auth.UserID, err)
+		sendErrorResponse(w, "Failed to create artist", http.StatusInternalServerError)
+		return
+	}
+
+	artist.ID = int(id)
+	for _, e := range []audit.Entry{
+		{Action: audit.Create, Entity: audit.Artist, EntityID: artist.ID, After: artist},
+		{Action: audit.Create, Entity: audit.Member, EntityID: auth.UserID, After: models.UserArtist{UserID: auth.UserID, ArtistID: artist.ID, Role: RoleOwner}},
+	} {
+		e.ActorID, e.ArtistID = auth.UserID, artist.ID
+		if err := audit.Record(tx, e); err != nil {
+			sendErrorResponse(w, "Failed to create artist", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		sendErrorResponse(w, "Failed to create artist", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSONResponse(w, models.ArtistAccess{Artist: artist, Role: RoleOwner}, http.StatusCreated)
+}
+
+// DeleteArtist moves an artist, and with it all their artwork, to the trash
+// (owners only)
+func DeleteArtist(w http.ResponseWriter, r *http.Request) {
+	artistID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artist ID", http.StatusBadRequest)
+		return
+	}
+	if !requireArtistRole(w, r, artistID, RoleOwner) {
+		return
+	}
+
+	var before models.Artist
+	var codename sql.NullString
+	if err := config.DB.QueryRow("SELECT id, name, codename FROM artists WHERE id = ?", artistID).
+		Scan(&before.ID, &before.Name, &codename); err != nil {
+		sendErrorResponse(w, "Failed to fetch artist", http.StatusInternalServerError)
+		return
+	}
+	before.Codename = codename.String
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to delete artist", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	auth, _ := currentAuth(r)
+	_, err = tx.Exec("UPDATE artists SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", time.Now().UTC(), artistID)
+	if err == nil {
+		err = audit.Record(tx, audit.Entry{
+			ActorID: auth.UserID, Action: audit.Delete, Entity: audit.Artist, EntityID: artistID, ArtistID: artistID, Before: before,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("DB error deleting artist %d: %v", artistID, err)
+		sendErrorResponse(w, "Failed to delete artist", http.StatusInternalServerError)
+		return
+	}
+	// Too many images to look up one by one
+	imageCache.Clear()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetArtistMembers lists everyone with access to an artist (any role may look)
+func GetArtistMembers(w http.ResponseWriter, r *http.Request) {
+	artistID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artist ID", http.StatusBadRequest)
+		return
+	}
+	if !requireArtistRole(w, r, artistID, RoleViewer) {
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT u.id, u.fname, u.lname, u.email, ua.role
+		FROM user_artists ua
+		JOIN users u ON u.id = ua.user_id
+		WHERE ua.artist_id = ?
+		ORDER BY u.fname, u.lname, u.id
+	`, artistID)
+	if err != nil {
+		log.Printf("DB error fetching members of artist %d: %v", artistID, err)
+		sendErrorResponse(w, "Failed to fetch members", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	members := []models.ArtistMember{}
+	for rows.Next() {
+		var m models.ArtistMember
+		if err := rows.Scan(&m.UserID, &m.FName, &m.LName, &m.Email, &m.Role); err != nil {
+			sendErrorResponse(w, "Failed to scan member data", http.StatusInternalServerError)
+			return
+		}
+		members = append(members, m)
+	}
+
+	sendJSONResponse(w, members, http.StatusOK)
+}
+
+// AddArtistMember gives an existing user access to an artist (owners only)
+func AddArtistMember(w http.ResponseWriter, r *http.Request) {
+	artistID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artist ID", http.StatusBadRequest)
+		return
+	}
+	if !requireArtistRole(w, r, artistID, RoleOwner) {
+		return
+	}
+
+	var req models.MemberCreate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		sendErrorResponse(w, "email and role are required", http.StatusBadRequest)
+		return
+	}
+	if !validRole(req.Role) {
+		sendErrorResponse(w, "role must be owner, editor or viewer", http.StatusBadRequest)
+		return
+	}
+
+	var m models.ArtistMember
+	err = config.DB.QueryRow("SELECT id, fname, lname, email FROM users WHERE email = ?", req.Email).
+		Scan(&m.UserID, &m.FName, &m.LName, &m.Email)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "No account uses that email", http.StatusNotFound)
+		return
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to add member", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to add member", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	m.Role = req.Role
+	auth, _ := currentAuth(r)
+	_, err = tx.Exec("INSERT INTO user_artists (user_id, artist_id, role) VALUES (?, ?, ?)", m.UserID, artistID, req.Role)
+	if err == nil {
+		err = audit.Record(tx, audit.Entry{
+			ActorID: auth.UserID, Action: audit.Create, Entity: audit.Member, EntityID: m.UserID, ArtistID: artistID,
+			After: models.UserArtist{UserID: m.UserID, ArtistID: artistID, Role: m.Role},
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		if config.DB.IsUniqueViolation(err) {
+			sendErrorResponse(w, "That user already has access, change their role instead", http.StatusConflict)
+			return
+		}
+		log.Printf("DB error adding user %d to artist %d: %v", m.UserID, artistID, err)
+		sendErrorResponse(w, "Failed to add member", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSONResponse(w, m, http.StatusCreated)
+}
+
+// UpdateArtistMember changes a member's role (owners only)
+func UpdateArtistMember(w http.ResponseWriter, r *http.Request) {
+	artistID, userID, ok := memberVars(w, r)
+	if !ok || !requireArtistRole(w, r, artistID, RoleOwner) {
+		return
+	}
+
+	var req models.RoleUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !validRole(req.Role) {
+		sendErrorResponse(w, "role must be owner, editor or viewer", http.StatusBadRequest)
+		return
+	}
+
+	auth, _ := currentAuth(r)
+	err := changeMember(artistID, userID, func(tx *config.Tx, role string) error {
+		if _, err := tx.Exec("UPDATE user_artists SET role = ? WHERE user_id = ? AND artist_id = ?", req.Role, userID, artistID); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			ActorID: auth.UserID, Action: audit.Update, Entity: audit.Member, EntityID: userID, ArtistID: artistID,
+			Before: models.UserArtist{UserID: userID, ArtistID: artistID, Role: role},
+			After:  models.UserArtist{UserID: userID, ArtistID: artistID, Role: req.Role},
+		})
+	})
+	if !sendMemberError(w, err, "Failed to update role") {
+		return
+	}
+
+	sendSuccessResponse(w, models.UserArtist{UserID: userID, ArtistID: artistID, Role: req.Role}, "Role updated", http.StatusOK)
+}
+
+// RemoveArtistMember revokes a user's access. Owners can remove anyone and
+// any member can remove themselves.
+func RemoveArtistMember(w http.ResponseWriter, r *http.Request) {
+	artistID, userID, ok := memberVars(w, r)
+	if !ok {
+		return
+	}
+	auth, _ := currentAuth(r)
+	min := RoleOwner
+	if userID == auth.UserID {
+		min = RoleViewer
+	}
+	if !requireArtistRole(w, r, artistID, min) {
+		return
+	}
+
+	err := changeMember(artistID, userID, func(tx *config.Tx, role string) error {
+		if _, err := tx.Exec("DELETE FROM user_artists WHERE user_id = ? AND artist_id = ?", userID, artistID); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			ActorID: auth.UserID, Action: audit.Delete, Entity: audit.Member, EntityID: userID, ArtistID: artistID,
+			Before: models.UserArtist{UserID: userID, ArtistID: artistID, Role: role},
+		})
+	})
+	if !sendMemberError(w, err, "Failed to remove member") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// memberVars parses {id} and {user_id} from the path
+func memberVars(w http.ResponseWriter, r *http.Request) (artistID, userID int, ok bool) {
+	vars := mux.Vars(r)
+	artistID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artist ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	userID, err = strconv.Atoi(vars["user_id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return artistID, userID, true
+}
+
+// changeMember applies change to a member's link in a transaction, refusing
+// if it would leave the artist without an owner. change gets the member's
+// current role.
+func changeMember(artistID, userID int, change func(tx *config.Tx, role string) error) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var role string
+	if err := tx.QueryRow(
+		"SELECT role FROM user_artists WHERE user_id = ? AND artist_id = ?", userID, artistID,
+	).Scan(&role); err != nil {
+		return err
+	}
+
+	if err := change(tx, role); err != nil {
+		return err
+	}
+
+	var owners int
+	if err := tx.QueryRow(
+		"SELECT COUNT(*) FROM user_artists WHERE artist_id = ? AND role = ?", artistID, RoleOwner,
+	).Scan(&owners); err != nil {
+		return err
+	}
+	if owners == 0 {
+		return errLastOwner
+	}
+	return tx.Commit()
+}
+
+// sendMemberError writes the response for a failed changeMember and reports
+// whether the change succeeded
+func sendMemberError(w http.ResponseWriter, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case err == sql.ErrNoRows:
+		sendErrorResponse(w, "Member not found", http.StatusNotFound)
+	case errors.Is(err, errLastOwner):
+		sendErrorResponse(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("DB error changing artist member: %v", err)
+		sendErrorResponse(w, message, http.StatusInternalServerError)
+	}
+	return false
+}
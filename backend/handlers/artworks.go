@@ -1,13 +1,23 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"go-art-api/audit"
 	"go-art-api/config"
+	"go-art-api/imagecache"
+	"go-art-api/models"
 	"go-art-api/utils"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -44,10 +54,13 @@ func CreateArtworkAndUploadImage(w http.ResponseWriter, r *http.Request) {
 		sendErrorResponse(w, "Title, Artist ID, and valid data are required", http.StatusBadRequest)
 		return
 	}
+	if !requireArtistRole(w, r, artistID, RoleEditor) {
+		return
+	}
 
 	// 3. Insert Artwork into Database
 	log.Printf("2. Starting DB INSERT for new artwork (ArtistID: %d)", artistID)
-	result, err := config.DB.Exec(
+	artworkID, err := config.DB.Insert(
 		"INSERT INTO artworks (artist_id, title, grade, school) VALUES (?, ?, ?, ?)",
 		artistID, title, grade, school,
 	)
@@ -57,7 +70,6 @@ func CreateArtworkAndUploadImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	artworkID, _ := result.LastInsertId()
 	if artworkID == 0 {
 		log.Printf("ERROR 2.2: Failed to retrieve new artwork ID.")
 		sendErrorResponse(w, "Failed to retrieve new artwork ID", http.StatusInternalServerError)
@@ -79,8 +91,17 @@ func CreateArtworkAndUploadImage(w http.ResponseWriter, r *http.Request) {
 	originalMime := header.Header.Get("Content-Type")
 	log.Printf("3.2. File found. Original MIME: %s. Starting image processing...", originalMime)
 
+	// Keep the upload as it came, so edits can always start from it
+	original, err := io.ReadAll(file)
+	if err != nil {
+		log.Printf("ERROR 3.2.1: Failed to read image file: %v. Deleting created artwork.", err)
+		config.DB.Exec("DELETE FROM artworks WHERE id = ?", artworkID) // Clean up artwork
+		sendErrorResponse(w, "Failed to read image file", http.StatusBadRequest)
+		return
+	}
+
 	// Process the Image (Generates 2 JPEG BLOBs)
-	thumbData, imageData, err := utils.ProcessImage(file)
+	thumbData, imageData, hash, err := utils.ProcessImage(bytes.NewReader(original))
 	if err != nil {
 		// This is the common hang point if processing is too long or crashes.
 		log.Printf("FATAL PROCESSING ERROR 3.3: Image processing failed: %v. Deleting created artwork.", err)
@@ -91,36 +112,78 @@ func CreateArtworkAndUploadImage(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("4. Image processing complete. Thumb Size: %d bytes, Full Size: %d bytes.", len(thumbData), len(imageData))
 
-	// 5. Insert Image Data into Database (Using the new artworkID)
-	query := `
-        INSERT INTO images (artwork_id, original_mime, thumb, image, url) 
-        VALUES (?, ?, ?, ?, NULL)
-    `
+	// 5. Insert Image Data into Database (Using the new artworkID), provided
+	// the family has room for the original and its renditions
+	palette, storedPalette := imagePalette(thumbData)
+	blurhash := imageBlurHash(thumbData)
 	log.Printf("5. Starting final DB INSERT for image data (Artwork ID: %d)...", artworkID)
-	result, err = config.DB.Exec(query, artworkID, originalMime, thumbData, imageData)
-
+	auth, _ := currentAuth(r)
+	created := audit.Entry{
+		ActorID: auth.UserID, Action: audit.Create, Entity: audit.Artwork, EntityID: int(artworkID), ArtistID: artistID,
+		After: models.Artwork{ArtistID: artistID, Title: title, Grade: grade, School: school},
+	}
+	imageID, err := insertImage(created, originalMime, original, thumbData, imageData, hash, storedPalette, blurhash)
 	if err != nil {
+		config.DB.Exec("DELETE FROM artworks WHERE id = ?", artworkID) // Clean up artwork
+		if errors.Is(err, errQuotaExceeded) {
+			sendQuotaError(w, err)
+			return
+		}
 		// This is the other common crash point (e.g., if a BLOB exceeds MySQL size limit)
 		log.Printf("FATAL DB ERROR 5.1: Database error during image INSERT: %v. Deleting created artwork.", err)
-		config.DB.Exec("DELETE FROM artworks WHERE id = ?", artworkID) // Clean up
 		sendErrorResponse(w, "Failed to save image data to the database", http.StatusInternalServerError)
 		return
 	}
 
-	imageID, _ := result.LastInsertId()
 	log.Printf("5.2. Image data saved successfully. New Image ID: %d", imageID)
 
-	// 6. Success Response
+	// 6. Success Response, warning if the family already has what looks like the same drawing
 	log.Printf("6. Sending final SUCCESS response.")
 	sendSuccessResponse(w, map[string]interface{}{
-		"artwork_id": artworkID,
-		"image_id":   imageID,
-		"title":      title,
-		"image_size": fmt.Sprintf("%.2f KB", float64(len(imageData))/1024),
+		"artwork_id":          artworkID,
+		"image_id":            imageID,
+		"thumb_url":           signedThumbURL(int(imageID)),
+		"title":               title,
+		"image_size":          fmt.Sprintf("%.2f KB", float64(len(imageData))/1024),
+		"palette":             palette,
+		"blurhash":            blurhash,
+		"possible_duplicates": possibleDuplicates(auth.UserID, int(artworkID), hash),
 	}, "Artwork and image created successfully", http.StatusCreated)
 	log.Printf("--- END: CreateArtworkAndUploadImage ---")
 }
 
+// insertImage stores a new artwork's first image, provided the artist's
+// family has room for the original and its renditions. The artwork's
+// creation, described by created, is recorded along with the image's.
+func insertImage(created audit.Entry, mime string, original, thumb, image []byte, hash uint64, palette, blurhash interface{}) (int64, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	artistID, artworkID := created.ArtistID, created.EntityID
+	if err := checkStorageQuota(tx, artistID, int64(len(thumb)+len(image)+len(original)), 0); err != nil {
+		return 0, err
+	}
+	imageID, err := tx.Insert(`
+        INSERT INTO images (artwork_id, original_mime, thumb, image, thumb_bytes, image_bytes, original, original_bytes, phash, palette, blurhash, url) 
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULL)
+    `, artworkID, mime, thumb, image, len(thumb), len(image), original, len(original), int64(hash), palette, blurhash)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range []audit.Entry{created, {
+		ActorID: created.ActorID, Action: audit.Create, Entity: audit.Image, EntityID: int(imageID), ArtistID: artistID,
+		After: imageAudit{ArtworkID: artworkID, Mime: mime, ThumbBytes: len(thumb), ImageBytes: len(image)},
+	}} {
+		if err := audit.Record(tx, e); err != nil {
+			return 0, err
+		}
+	}
+	return imageID, tx.Commit()
+}
+
 // UploadImage handles the upload of a new image, processes it, and saves it to the database.
 func UploadImage(w http.ResponseWriter, r *http.Request) {
 	// 1. Get Artwork ID from URL
@@ -132,6 +195,12 @@ func UploadImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 1.1. Only editors and owners may change an artist's artwork
+	artistID, ok := requireArtworkRole(w, r, artworkID, RoleEditor)
+	if !ok {
+		return
+	}
+
 	// 2. Parse the Multipart Form Data (Max 10MB)
 	err = r.ParseMultipartForm(10 << 20)
 	if err != nil {
@@ -149,32 +218,69 @@ func UploadImage(w http.ResponseWriter, r *http.Request) {
 	// Get original MIME type for metadata storage
 	originalMime := header.Header.Get("Content-Type")
 
-	// 3. Process the Image (Generates 2 JPEG BLOBs)
-	thumbData, imageData, err := utils.ProcessImage(file)
+	// 3. Process the Image (Generates 2 JPEG BLOBs), keeping the upload as it came
+	original, err := io.ReadAll(file)
+	if err != nil {
+		sendErrorResponse(w, "Failed to read image file", http.StatusBadRequest)
+		return
+	}
+	thumbData, imageData, hash, err := utils.ProcessImage(bytes.NewReader(original))
 	if err != nil {
 		sendErrorResponse(w, fmt.Sprintf("Image processing failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// 4. Database Insertion (UPSERT Logic)
+	// 4. Look for an image to replace
 	var existingID int
-	err = config.DB.QueryRow("SELECT id FROM images WHERE artwork_id = ?", artworkID).Scan(&existingID)
+	existing := imageAudit{ArtworkID: artworkID}
+	err = config.DB.QueryRow(
+		"SELECT id, original_mime, thumb_bytes, image_bytes FROM images WHERE artwork_id = ?", artworkID,
+	).Scan(&existingID, &existing.Mime, &existing.ThumbBytes, &existing.ImageBytes)
+	if err != nil && err != sql.ErrNoRows {
+		sendErrorResponse(w, "Failed to fetch existing image", http.StatusInternalServerError)
+		return
+	}
+	noImage := err == sql.ErrNoRows
+
+	// 5. Database Insertion (UPSERT Logic)
+	auth, _ := currentAuth(r)
+	palette, storedPalette := imagePalette(thumbData)
+	blurhash := imageBlurHash(thumbData)
+	imageID := int64(existingID) // Use existing ID if it is an update
+	entry := audit.Entry{Action: audit.Update, Entity: audit.Image, ArtistID: artistID, Before: existing}
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to save image data to the database", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
 
-	var result sql.Result
-	if err == sql.ErrNoRows {
+	// Check the family quota. A replaced image is kept as a revision, so its
+	// bytes still count.
+	if quotaErr := checkStorageQuota(tx, artistID, int64(len(thumbData)+len(imageData)+len(original)), 0); quotaErr != nil {
+		sendQuotaError(w, quotaErr)
+		return
+	}
+	if noImage {
+		entry.Action, entry.Before = audit.Create, nil
 		// INSERT (New image)
 		query := `
-            INSERT INTO images (artwork_id, original_mime, thumb, image, url) 
-            VALUES (?, ?, ?, ?, NULL)
-        `
-		result, err = config.DB.Exec(query, artworkID, originalMime, thumbData, imageData)
-	} else if err == nil {
-		// UPDATE (Replace existing image)
-		query := `
-            UPDATE images SET original_mime = ?, thumb = ?, image = ?, url = NULL
-            WHERE artwork_id = ?
+            INSERT INTO images (artwork_id, original_mime, thumb, image, thumb_bytes, image_bytes, original, original_bytes, phash, palette, blurhash, url) 
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULL)
         `
-		result, err = config.DB.Exec(query, originalMime, thumbData, imageData, artworkID)
+		imageID, err = tx.Insert(query, artworkID, originalMime, thumbData, imageData, len(thumbData), len(imageData), original, len(original), int64(hash), storedPalette, blurhash)
+	} else {
+		// UPDATE (Replace existing image, bringing it back out of the trash if
+		// need be). The old one is kept in a revision so it can be reverted to.
+		err = replaceImage(tx, artworkID, auth.UserID, originalMime, original, thumbData, imageData, hash, storedPalette, blurhash)
+	}
+	if err == nil {
+		entry.ActorID, entry.EntityID = auth.UserID, int(imageID)
+		entry.After = imageAudit{ArtworkID: artworkID, Mime: originalMime, ThumbBytes: len(thumbData), ImageBytes: len(imageData)}
+		err = audit.Record(tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit()
 	}
 
 	if err != nil {
@@ -183,22 +289,152 @@ func UploadImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	imageID, _ := result.LastInsertId()
-	if imageID == 0 && existingID != 0 {
-		imageID = int64(existingID) // Use existing ID if it was an update
-	}
+	refreshEnhanced(artworkID)
+	uncacheImage(int(imageID))
 
-	// 5. Success Response
+	// 6. Success Response, warning if the family already has what looks like the same drawing
 	sendSuccessResponse(w, map[string]interface{}{
-		"image_id":        imageID,
-		"artwork_id":      artworkID,
-		"thumb_size":      fmt.Sprintf("%.2f KB", float64(len(thumbData))/1024),
-		"image_size":      fmt.Sprintf("%.2f KB", float64(len(imageData))/1024),
-		"stored_format":   "image/jpeg",
-		"original_format": originalMime,
+		"image_id":            imageID,
+		"artwork_id":          artworkID,
+		"thumb_url":           signedThumbURL(int(imageID)),
+		"thumb_size":          fmt.Sprintf("%.2f KB", float64(len(thumbData))/1024),
+		"image_size":          fmt.Sprintf("%.2f KB", float64(len(imageData))/1024),
+		"stored_format":       "image/jpeg",
+		"original_format":     originalMime,
+		"palette":             palette,
+		"blurhash":            blurhash,
+		"possible_duplicates": possibleDuplicates(auth.UserID, artworkID, hash),
 	}, "Image uploaded, processed, and saved successfully", http.StatusCreated)
 }
 
+// DeleteArtwork moves an artwork to the trash (editors and owners)
+func DeleteArtwork(w http.ResponseWriter, r *http.Request) {
+	artworkID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artwork ID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireArtworkRole(w, r, artworkID, RoleEditor); !ok {
+		return
+	}
+	before, err := fetchArtwork(artworkID)
+	if err != nil {
+		sendErrorResponse(w, "Failed to fetch artwork", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to delete artwork", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Its image stays as it is and comes back with it on restore
+	auth, _ := currentAuth(r)
+	_, err = tx.Exec("UPDATE artworks SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", time.Now().UTC(), artworkID)
+	if err == nil {
+		err = audit.Record(tx, audit.Entry{
+			ActorID: auth.UserID, Action: audit.Delete, Entity: audit.Artwork, EntityID: artworkID, ArtistID: before.ArtistID, Before: before,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("DB error deleting artwork %d: %v", artworkID, err)
+		sendErrorResponse(w, "Failed to delete artwork", http.StatusInternalServerError)
+		return
+	}
+	uncacheArtwork(artworkID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteImage moves an artwork's image to the trash (editors and owners)
+func DeleteImage(w http.ResponseWriter, r *http.Request) {
+	artworkID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artwork ID", http.StatusBadRequest)
+		return
+	}
+	artistID, ok := requireArtworkRole(w, r, artworkID, RoleEditor)
+	if !ok {
+		return
+	}
+
+	var imageID int
+	before := imageAudit{ArtworkID: artworkID}
+	err = config.DB.QueryRow(
+		"SELECT id, original_mime, thumb_bytes, image_bytes FROM images WHERE artwork_id = ? AND deleted_at IS NULL", artworkID,
+	).Scan(&imageID, &before.Mime, &before.ThumbBytes, &before.ImageBytes)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Artwork has no image", http.StatusNotFound)
+		return
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to fetch image", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to delete image", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	auth, _ := currentAuth(r)
+	_, err = tx.Exec("UPDATE images SET deleted_at = ? WHERE id = ?", time.Now().UTC(), imageID)
+	if err == nil {
+		err = audit.Record(tx, audit.Entry{
+			ActorID: auth.UserID, Action: audit.Delete, Entity: audit.Image, EntityID: imageID, ArtistID: artistID, Before: before,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("DB error deleting image %d: %v", imageID, err)
+		sendErrorResponse(w, "Failed to delete image", http.StatusInternalServerError)
+		return
+	}
+	uncacheImage(imageID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// imageAudit is how an image appears in the audit log, without its bytes
+type imageAudit struct {
+	ArtworkID  int    `json:"artwork_id"`
+	Mime       string `json:"mime"`
+	ThumbBytes int    `json:"thumb_bytes"`
+	ImageBytes int    `json:"image_bytes"`
+	// Edits are how the renditions were made from the original, if they were
+	Edits *models.ImageEdits `json:"edits,omitempty"`
+}
+
+// fetchArtwork loads an artwork's metadata
+func fetchArtwork(id int) (models.Artwork, error) {
+	var a models.Artwork
+	var grade, school, title, description sql.NullString
+	err := config.DB.QueryRow(
+		"SELECT id, artist_id, grade, school, title, description, auto_enhance, created_at FROM artworks WHERE id = ?", id,
+	).Scan(&a.ID, &a.ArtistID, &grade, &school, &title, &description, &a.AutoEnhance, &a.CreatedAt)
+	a.Grade, a.School, a.Title, a.Description = grade.String, school.String, title.String, description.String
+	return a, err
+}
+
+// sendQuotaError maps quota check failures to 413 (over quota) or 500 (lookup failed)
+func sendQuotaError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errQuotaExceeded) {
+		log.Printf("Upload rejected: %v", err)
+		sendErrorResponse(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	log.Printf("DB error checking storage quota: %v", err)
+	sendErrorResponse(w, "Failed to check storage quota", http.StatusInternalServerError)
+}
+
 // GetImage retrieves and serves the full-size image (BLOB)
 func GetImage(w http.ResponseWriter, r *http.Request) {
 	serveImage(w, r, "image")
@@ -218,13 +454,48 @@ func serveImage(w http.ResponseWriter, r *http.Request, column string) {
 		sendErrorResponse(w, "Invalid image ID", http.StatusBadRequest)
 		return
 	}
+	// Signed URLs were checked by whoever signed them; signed-in users are checked here
+	if _, signedIn := currentAuth(r); signedIn && !requireImageRole(w, r, id, RoleViewer) {
+		return
+	}
 
-	// 1. Fetch the image data (BLOB) from the database
+	// 1. Fetch the image data (BLOB) from the database, unless it or its artwork is in the trash.
+	// ?version=plain|enhanced picks a pair of renditions; otherwise the artwork's auto-enhance toggle does.
+	selected, rendition := servedColumn(column), column
+	version := r.URL.Query().Get("version")
+	switch version {
+	case "":
+	case "plain":
+		selected = "i." + column
+	case "enhanced":
+		selected = "i.enhanced_" + column
+	default:
+		sendErrorResponse(w, "version must be plain or enhanced", http.StatusBadRequest)
+		return
+	}
+	if version != "" {
+		rendition = version + "_" + column
+	}
+	key := imagecache.Key{ImageID: id, Rendition: rendition}
+	if imageData, ok := imageCache.Get(key); ok {
+		writeImage(w, r, imageData, "image/jpeg", "HIT")
+		return
+	}
+
+	epoch := imageCache.Epoch()
 	var imageData []byte
-	query := fmt.Sprintf("SELECT %s FROM images WHERE id = ?", column)
+	query := fmt.Sprintf(`
+		SELECT %s FROM images i
+		JOIN artworks a ON a.id = i.artwork_id
+		JOIN artists ar ON ar.id = a.artist_id
+		WHERE i.id = ? AND i.deleted_at IS NULL AND a.deleted_at IS NULL AND ar.deleted_at IS NULL
+	`, selected)
 	err = config.DB.QueryRow(query, id).Scan(&imageData)
 
-	if err == sql.ErrNoRows {
+	if err == nil && imageData == nil && version == "enhanced" {
+		sendErrorResponse(w, "No enhanced version of this image", http.StatusNotFound)
+		return
+	} else if err == sql.ErrNoRows {
 		sendErrorResponse(w, "Image not found", http.StatusNotFound)
 		return
 	} else if err != nil {
@@ -232,10 +503,30 @@ func serveImage(w http.ResponseWriter, r *http.Request, column string) {
 		sendErrorResponse(w, "Failed to retrieve image data", http.StatusInternalServerError)
 		return
 	}
+	imageCache.Add(key, imageData, epoch)
+	writeImage(w, r, imageData, "image/jpeg", "MISS")
+}
 
-	// 2. Set the appropriate HTTP headers (Always JPEG since we process it that way)
-	w.Header().Set("Content-Type", "image/jpeg")
-	w.Header().Set("Cache-Control", "public, max-age=2592000, immutable")
+// writeImage sends an image rendition. X-Cache says whether it came from the
+// in-memory cache. URLs with the image's version (?v=) change when it does, so
+// browsers may keep those; anything else is revalidated against the ETag.
+func writeImage(w http.ResponseWriter, r *http.Request, imageData []byte, contentType, cache string) {
+	sum := sha256.Sum256(imageData)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	// 2. Set the appropriate HTTP headers
+	w.Header().Set("ETag", etag)
+	if r.URL.Query().Get("v") != "" {
+		w.Header().Set("Cache-Control", "private, max-age=2592000")
+	} else {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	w.Header().Set("X-Cache", cache)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
 
 	// 3. Write the raw image data (BLOB) to the response body
 	if _, err := w.Write(imageData); err != nil {
@@ -243,3 +534,14 @@ func serveImage(w http.ResponseWriter, r *http.Request, column string) {
 		// Note: Can't send an HTTP error here, as headers are already sent.
 	}
 }
+
+// etagMatches reports whether an If-None-Match header lists etag
+func etagMatches(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-art-api/audit"
+	"go-art-api/config"
+	"go-art-api/models"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 200
+)
+
+// GetAuditLog lists changes, newest first. Members see changes to their
+// family's artists, changes they made and changes to their own account;
+// admins see everything. Filters: artist_id, entity, entity_id, actor_id,
+// before (an entry ID, for paging) and limit.
+func GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+	q := r.URL.Query()
+
+	admin, err := isAdmin(auth.UserID)
+	if err != nil {
+		log.Printf("DB error checking admin rights of user %d: %v", auth.UserID, err)
+		sendErrorResponse(w, "Failed to fetch audit log", http.StatusInternalServerError)
+		return
+	}
+
+	var where []string
+	var args []interface{}
+	if !admin {
+		artistIDs, err := userFamilyArtistIDs(auth.UserID)
+		if err != nil {
+			sendErrorResponse(w, "Failed to resolve family", http.StatusInternalServerError)
+			return
+		}
+		scope := "l.actor_id = ? OR (l.entity = ? AND l.entity_id = ?)"
+		args = append(args, auth.UserID, audit.User, auth.UserID)
+		if len(artistIDs) > 0 {
+			placeholders, ids := inClause(artistIDs)
+			scope = "l.artist_id IN (" + placeholders + ") OR " + scope
+			args = append(ids, args...)
+		}
+		where = append(where, "("+scope+")")
+	}
+
+	for _, f := range []struct{ param, column string }{
+		{"artist_id", "l.artist_id"},
+		{"entity_id", "l.entity_id"},
+		{"actor_id", "l.actor_id"},
+		{"before", "l.id"},
+	} {
+		v := q.Get(f.param)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			sendErrorResponse(w, fmt.Sprintf("Invalid %s", f.param), http.StatusBadRequest)
+			return
+		}
+		op := " = ?"
+		if f.param == "before" {
+			op = " < ?"
+		}
+		where = append(where, f.column+op)
+		args = append(args, id)
+	}
+	if entity := q.Get("entity"); entity != "" {
+		where = append(where, "l.entity = ?")
+		args = append(args, entity)
+	}
+
+	limit := defaultAuditLimit
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxAuditLimit {
+			sendErrorResponse(w, fmt.Sprintf("limit must be 1-%d", maxAuditLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	query := `
+		SELECT l.id, l.created_at, l.actor_id, u.fname, u.lname, l.action, l.entity, l.entity_id, l.artist_id, l.changes
+		FROM audit_log l
+		LEFT JOIN users u ON u.id = l.actor_id
+	`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY l.id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		log.Printf("DB error fetching audit log: %v", err)
+		sendErrorResponse(w, "Failed to fetch audit log", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		var actorID, artistID sql.NullInt64
+		var fname, lname, changes sql.NullString
+		if err := rows.Scan(
+			&e.ID, &e.CreatedAt, &actorID, &fname, &lname, &e.Action, &e.Entity, &e.EntityID, &artistID, &changes,
+		); err != nil {
+			log.Printf("DB error scanning audit log: %v", err)
+			sendErrorResponse(w, "Failed to scan audit log", http.StatusInternalServerError)
+			return
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			e.ActorID = &id
+		}
+		if artistID.Valid {
+			id := int(artistID.Int64)
+			e.ArtistID = &id
+		}
+		if fname.Valid {
+			e.ActorName = strings.TrimSpace(fname.String + " " + lname.String)
+		}
+		if changes.Valid {
+			e.Changes = json.RawMessage(changes.String)
+		}
+		entries = append(entries, e)
+	}
+
+	sendJSONResponse(w, entries, http.StatusOK)
+}
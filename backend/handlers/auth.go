@@ -0,0 +1,396 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-art-api/config"
+	"go-art-api/models"
+	"go-art-api/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// refreshTokenLength is the length of the random refresh token handed to clients
+const refreshTokenLength = 48
+
+var (
+	errInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	errAccountDisabled     = errors.New("this account has been disabled")
+)
+
+type contextKey string
+
+const authContextKey contextKey = "auth"
+
+// authInfo is what RequireAuth stores in the request context
+type authInfo struct {
+	UserID    int
+	SessionID int
+}
+
+// RequireAuth rejects requests without a valid access token whose session is
+// still active, and makes the user and session available to the handler.
+func RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		tokenString := strings.TrimPrefix(header, "Bearer ")
+		if header == "" || tokenString == header {
+			sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := utils.ParseAccessToken(config.JWTSecret(), tokenString)
+		if err != nil {
+			sendErrorResponse(w, "Invalid or expired access token", http.StatusUnauthorized)
+			return
+		}
+		userID, err := claims.UserID()
+		if err != nil {
+			sendErrorResponse(w, "Invalid or expired access token", http.StatusUnauthorized)
+			return
+		}
+
+		// A revoked session or disabled account must stop working immediately,
+		// not when its access token expires
+		var expiresAt time.Time
+		var revokedAt, disabledAt sql.NullTime
+		err = config.DB.QueryRow(`
+			SELECT s.expires_at, s.revoked_at, u.disabled_at
+			FROM sessions s
+			JOIN users u ON u.id = s.user_id
+			WHERE s.id = ? AND s.user_id = ?
+		`, claims.SessionID, userID).Scan(&expiresAt, &revokedAt, &disabledAt)
+		if err == sql.ErrNoRows || (err == nil && (revokedAt.Valid || time.Now().After(expiresAt))) {
+			sendErrorResponse(w, "Session has been signed out", http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			log.Printf("DB error checking session %d: %v", claims.SessionID, err)
+			sendErrorResponse(w, "Authentication failed", http.StatusInternalServerError)
+			return
+		}
+		if disabledAt.Valid {
+			sendErrorResponse(w, errAccountDisabled.Error(), http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), authContextKey, authInfo{UserID: userID, SessionID: claims.SessionID})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireAuthOrSignature lets through requests for URLs made by signURL, and
+// otherwise wants a signed-in user like RequireAuth. Handlers check the
+// user's role when there is one; whoever signed the URL already did.
+func RequireAuthOrSignature(next http.Handler) http.Handler {
+	authed := RequireAuth(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if validSignature(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		authed.ServeHTTP(w, r)
+	})
+}
+
+// RequireAdmin lets only site administrators through. It runs after RequireAuth.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, ok := currentAuth(r)
+		if !ok {
+			sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		admin, err := isAdmin(auth.UserID)
+		if err != nil {
+			log.Printf("DB error checking admin rights of user %d: %v", auth.UserID, err)
+			sendErrorResponse(w, "Failed to check permissions", http.StatusInternalServerError)
+			return
+		}
+		if !admin {
+			sendErrorResponse(w, "Administrator access required", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isAdmin reports whether the user is a site administrator
+func isAdmin(userID int) (bool, error) {
+	var admin bool
+	err := config.DB.QueryRow("SELECT is_admin FROM users WHERE id = ?", userID).Scan(&admin)
+	return admin, err
+}
+
+// currentAuth returns the authenticated user and session set by RequireAuth
+func currentAuth(r *http.Request) (authInfo, bool) {
+	info, ok := r.Context().Value(authContextKey).(authInfo)
+	return info, ok
+}
+
+// RefreshToken exchanges a refresh token for a new access/refresh token pair.
+// The refresh token rotates on every use; presenting the one it replaced means
+// it was copied, so the whole session is revoked.
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		sendErrorResponse(w, "refresh_token is required", http.StatusBadRequest)
+		return
+	}
+
+	pair, err := rotateSession(r, req.RefreshToken)
+	if err == errInvalidRefreshToken {
+		sendErrorResponse(w, err.Error(), http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		log.Printf("Error refreshing session: %v", err)
+		sendErrorResponse(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, pair, "Token refreshed", http.StatusOK)
+}
+
+// Logout signs out the session that owns the given refresh token. It works
+// with an expired access token and succeeds even if the token is unknown.
+func Logout(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		sendErrorResponse(w, "refresh_token is required", http.StatusBadRequest)
+		return
+	}
+
+	_, err := config.DB.Exec(
+		"UPDATE sessions SET revoked_at = ? WHERE refresh_hash = ? AND revoked_at IS NULL",
+		time.Now().UTC(), utils.HashToken(req.RefreshToken),
+	)
+	if err != nil {
+		log.Printf("DB error logging out: %v", err)
+		sendErrorResponse(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll signs the user out on every device, including this one
+func LogoutAll(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	result, err := config.DB.Exec(
+		"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), auth.UserID,
+	)
+	if err != nil {
+		log.Printf("DB error logging out user %d everywhere: %v", auth.UserID, err)
+		sendErrorResponse(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	revoked, _ := result.RowsAffected()
+
+	sendSuccessResponse(w, map[string]int64{"revoked": revoked}, "Logged out of all devices", http.StatusOK)
+}
+
+// GetSessions lists the user's active sessions, newest activity first
+func GetSessions(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	rows, err := config.DB.Query(`
+		SELECT id, user_agent, ip, created_at, last_used_at, expires_at
+		FROM sessions
+		WHERE user_id = ? AND revoked_at IS NULL
+		ORDER BY last_used_at DESC, id DESC
+	`, auth.UserID)
+	if err != nil {
+		log.Printf("DB error fetching sessions: %v", err)
+		sendErrorResponse(w, "Failed to fetch sessions", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		var userAgent, ip sql.NullString
+		if err := rows.Scan(&s.ID, &userAgent, &ip, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			sendErrorResponse(w, "Failed to scan session data", http.StatusInternalServerError)
+			return
+		}
+		if now.After(s.ExpiresAt) {
+			continue
+		}
+		s.UserAgent, s.IP = userAgent.String, ip.String
+		s.Current = s.ID == auth.SessionID
+		sessions = append(sessions, s)
+	}
+
+	sendJSONResponse(w, sessions, http.StatusOK)
+}
+
+// RevokeSession signs out one of the user's sessions, e.g. a lost phone
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := config.DB.Exec(
+		"UPDATE sessions SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), id, auth.UserID,
+	)
+	if err != nil {
+		log.Printf("DB error revoking session %d: %v", id, err)
+		sendErrorResponse(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		sendErrorResponse(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createSession starts a new session for a user who just logged in
+func createSession(r *http.Request, userID int) (*models.TokenPair, error) {
+	refreshToken, err := utils.GenerateSecureToken(refreshTokenLength)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	sessionID, err := config.DB.Insert(`
+		INSERT INTO sessions (user_id, refresh_hash, user_agent, ip, created_at, last_used_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, userID, utils.HashToken(refreshToken), userAgent(r), clientIP(r), now, now, now.Add(config.RefreshTokenTTL()))
+	if err != nil {
+		return nil, err
+	}
+
+	return issueTokenPair(userID, int(sessionID), refreshToken)
+}
+
+// rotateSession swaps a refresh token for a new one and extends the session
+func rotateSession(r *http.Request, refreshToken string) (*models.TokenPair, error) {
+	hash := utils.HashToken(refreshToken)
+
+	var sessionID, userID int
+	var expiresAt time.Time
+	var revokedAt, disabledAt sql.NullTime
+	err := config.DB.QueryRow(`
+		SELECT s.id, s.user_id, s.expires_at, s.revoked_at, u.disabled_at
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.refresh_hash = ?
+	`, hash).Scan(&sessionID, &userID, &expiresAt, &revokedAt, &disabledAt)
+	if err == sql.ErrNoRows {
+		// A token that was already rotated away is being replayed: assume it
+		// leaked and sign the session out so neither copy keeps working.
+		result, err := config.DB.Exec(
+			"UPDATE sessions SET revoked_at = ? WHERE previous_hash = ? AND revoked_at IS NULL",
+			time.Now().UTC(), hash,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			log.Printf("⚠️  Refresh token reuse detected, session revoked")
+		}
+		return nil, errInvalidRefreshToken
+	} else if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid || disabledAt.Valid || time.Now().After(expiresAt) {
+		return nil, errInvalidRefreshToken
+	}
+
+	newToken, err := utils.GenerateSecureToken(refreshTokenLength)
+	if err != nil {
+		return nil, err
+	}
+
+	// Matching on the old hash makes concurrent refreshes with the same token
+	// race safely: only one of them rotates, the other gets a 401.
+	now := time.Now().UTC()
+	result, err := config.DB.Exec(`
+		UPDATE sessions
+		SET refresh_hash = ?, previous_hash = ?, user_agent = ?, ip = ?, last_used_at = ?, expires_at = ?
+		WHERE id = ? AND refresh_hash = ?
+	`, utils.HashToken(newToken), hash, userAgent(r), clientIP(r), now, now.Add(config.RefreshTokenTTL()), sessionID, hash)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, errInvalidRefreshToken
+	}
+
+	return issueTokenPair(userID, sessionID, newToken)
+}
+
+// issueTokenPair signs an access token for the session
+func issueTokenPair(userID, sessionID int, refreshToken string) (*models.TokenPair, error) {
+	ttl := config.AccessTokenTTL()
+	accessToken, expiresAt, err := utils.IssueAccessToken(config.JWTSecret(), userID, sessionID, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(ttl.Seconds()),
+		ExpiresAt:    expiresAt.UTC(),
+	}, nil
+}
+
+// userAgent returns the request's User-Agent, trimmed to fit the column
+func userAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > 255 {
+		ua = ua[:255]
+	}
+	return ua
+}
+
+// clientIP returns the address of the connecting client. Behind reverse
+// proxies (TRUST_PROXY) it is read from X-Forwarded-For, counting from the
+// right: each trusted proxy appends the address it was connected from, so
+// the entry the last of them added is the client, and anything further left
+// could have been made up by it.
+func clientIP(r *http.Request) string {
+	if proxies := config.TrustedProxies(); proxies > 0 {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(strings.Join(forwarded, ","), ",")
+			i := len(hops) - proxies
+			if i < 0 {
+				i = 0
+			}
+			return strings.TrimSpace(hops[i])
+		}
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+			return realIP
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
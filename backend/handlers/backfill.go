@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+
+	"go-art-api/config"
+	"go-art-api/utils"
+)
+
+// imageBackfill fills in a column worked out from an image's renditions, for
+// images stored before the column existed. compute gets the source rendition
+// and returns the column's value.
+type imageBackfill struct {
+	column  string
+	source  string
+	what    string
+	compute func(data []byte) (interface{}, error)
+}
+
+var imageBackfills = []imageBackfill{
+	{"phash", "image", "perceptual hash", func(data []byte) (interface{}, error) {
+		hash, err := utils.HashImage(bytes.NewReader(data))
+		return int64(hash), err
+	}},
+	// Images from before thumbnails were made only have the full-size rendition
+	{"palette", "COALESCE(thumb, image)", "colour palette", func(data []byte) (interface{}, error) {
+		palette, err := utils.ImagePalette(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		stored, err := json.Marshal(palette)
+		return string(stored), err
+	}},
+	{"blurhash", "COALESCE(thumb, image)", "BlurHash", func(data []byte) (interface{}, error) {
+		return utils.ImageBlurHash(bytes.NewReader(data))
+	}},
+}
+
+// BackfillImages works out whatever is missing from images stored before
+// perceptual hashes, palettes and BlurHashes were. It runs once at startup, in the
+// background.
+func BackfillImages() {
+	for _, b := range imageBackfills {
+		if err := backfillImages(b); err != nil {
+			log.Printf("❌ Image %s backfill failed: %v", b.what, err)
+		}
+	}
+}
+
+func backfillImages(b imageBackfill) error {
+	rows, err := config.DB.Query("SELECT id FROM images WHERE " + b.column + " IS NULL")
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	filled := 0
+	for _, id := range ids {
+		var data []byte
+		if err := config.DB.QueryRow("SELECT "+b.source+" FROM images WHERE id = ?", id).Scan(&data); err != nil {
+			return err
+		}
+		value, err := b.compute(data)
+		if err != nil {
+			log.Printf("Skipping %s of unreadable image %d: %v", b.what, id, err)
+			continue
+		}
+		if _, err := config.DB.Exec("UPDATE images SET "+b.column+" = ? WHERE id = ? AND "+b.column+" IS NULL", value, id); err != nil {
+			return err
+		}
+		filled++
+	}
+	if filled > 0 {
+		log.Printf("🔎 Worked out the %s of %d image(s)", b.what, filled)
+	}
+	return nil
+}
@@ -3,7 +3,6 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
-	"strings"
 
 	"go-art-api/models"
 )
@@ -34,11 +33,6 @@ func sendSuccessResponse(w http.ResponseWriter, data interface{}, message string
 	sendJSONResponse(w, response, status)
 }
 
-// contains checks if a string contains a substring (case-insensitive)
-func contains(s, substr string) bool {
-	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
-}
-
 // nullIfEmpty returns nil if string is empty, otherwise returns the string
 func nullIfEmpty(s string) interface{} {
 	if s == "" {
@@ -70,14 +64,6 @@ func Hello(w http.ResponseWriter, r *http.Request) {
 }
 
 // Placeholder handlers for unimplemented routes
-func GetArtists(w http.ResponseWriter, r *http.Request) {
-	sendSuccessResponse(w, []interface{}{}, "Artists endpoint - coming soon!", http.StatusOK)
-}
-
-func CreateArtist(w http.ResponseWriter, r *http.Request) {
-	sendErrorResponse(w, "Create artist endpoint not implemented yet", http.StatusNotImplemented)
-}
-
 func GetArtistByID(w http.ResponseWriter, r *http.Request) {
 	sendErrorResponse(w, "Get artist by ID endpoint not implemented yet", http.StatusNotImplemented)
 }
@@ -86,10 +72,6 @@ func UpdateArtist(w http.ResponseWriter, r *http.Request) {
 	sendErrorResponse(w, "Update artist endpoint not implemented yet", http.StatusNotImplemented)
 }
 
-func DeleteArtist(w http.ResponseWriter, r *http.Request) {
-	sendErrorResponse(w, "Delete artist endpoint not implemented yet", http.StatusNotImplemented)
-}
-
 func GetArtworks(w http.ResponseWriter, r *http.Request) {
 	sendSuccessResponse(w, []interface{}{}, "Artworks endpoint - coming soon!", http.StatusOK)
 }
@@ -98,18 +80,6 @@ func CreateArtwork(w http.ResponseWriter, r *http.Request) {
 	sendErrorResponse(w, "Create artwork endpoint not implemented yet", http.StatusNotImplemented)
 }
 
-func GetArtworkByID(w http.ResponseWriter, r *http.Request) {
-	sendErrorResponse(w, "Get artwork by ID endpoint not implemented yet", http.StatusNotImplemented)
-}
-
-func UpdateArtwork(w http.ResponseWriter, r *http.Request) {
-	sendErrorResponse(w, "Update artwork endpoint not implemented yet", http.StatusNotImplemented)
-}
-
-func DeleteArtwork(w http.ResponseWriter, r *http.Request) {
-	sendErrorResponse(w, "Delete artwork endpoint not implemented yet", http.StatusNotImplemented)
-}
-
 func GetMediums(w http.ResponseWriter, r *http.Request) {
 	sendSuccessResponse(w, []interface{}{}, "Mediums endpoint - coming soon!", http.StatusOK)
 }
@@ -131,10 +101,6 @@ func DeleteMedium(w http.ResponseWriter, r *http.Request) {
 }
 
 // Special route placeholders
-func GetArtworksView(w http.ResponseWriter, r *http.Request) {
-	sendSuccessResponse(w, []interface{}{}, "Artworks view endpoint - coming soon!", http.StatusOK)
-}
-
 func GetArtworksByArtist(w http.ResponseWriter, r *http.Request) {
 	sendSuccessResponse(w, []interface{}{}, "Get artworks by artist - coming soon!", http.StatusOK)
 }
@@ -155,10 +121,6 @@ func SearchArtists(w http.ResponseWriter, r *http.Request) {
 	sendSuccessResponse(w, []interface{}{}, "Search artists - coming soon!", http.StatusOK)
 }
 
-func SearchArtworks(w http.ResponseWriter, r *http.Request) {
-	sendSuccessResponse(w, []interface{}{}, "Search artworks - coming soon!", http.StatusOK)
-}
-
 func GetOverviewStats(w http.ResponseWriter, r *http.Request) {
 	sendSuccessResponse(w, map[string]int{
 		"users":    0,
@@ -170,11 +132,3 @@ func GetOverviewStats(w http.ResponseWriter, r *http.Request) {
 func GetUserArtists(w http.ResponseWriter, r *http.Request) {
 	sendSuccessResponse(w, []interface{}{}, "Get user artists - coming soon!", http.StatusOK)
 }
-
-func AddUserArtist(w http.ResponseWriter, r *http.Request) {
-	sendErrorResponse(w, "Add user artist endpoint not implemented yet", http.StatusNotImplemented)
-}
-
-func RemoveUserArtist(w http.ResponseWriter, r *http.Request) {
-	sendErrorResponse(w, "Remove user artist endpoint not implemented yet", http.StatusNotImplemented)
-}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"database/sql"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-art-api/config"
+	"go-art-api/dialect"
+	"go-art-api/migrations"
+)
+
+// openTestDB connects config.DB to a migrated, empty database: url if given
+// (e.g. BENCH_DATABASE_URL, which is migrated, filled and emptied again), or
+// else a temporary SQLite file
+func openTestDB(tb testing.TB, url string) *config.Database {
+	tb.Helper()
+	// Migrations log every step
+	log.SetOutput(io.Discard)
+	tb.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	if url == "" {
+		url = "sqlite://" + filepath.Join(tb.TempDir(), "test.db")
+	}
+	d, dsn, err := dialect.FromURL(url)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	sqlDB, err := sql.Open(d.Driver(), dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { sqlDB.Close() })
+
+	// Refuse to fill (and then empty) a database that's in use
+	if version, err := migrations.CurrentVersion(sqlDB); err != nil {
+		tb.Fatal(err)
+	} else if version != 0 {
+		tb.Fatalf("%s must point at an empty database, this one is at migration %d", url, version)
+	}
+	if _, err := migrations.Up(sqlDB, d); err != nil {
+		tb.Fatalf("migrating: %v", err)
+	}
+	tb.Cleanup(func() {
+		if _, err := migrations.To(sqlDB, d, 0); err != nil {
+			tb.Errorf("emptying the test database: %v", err)
+		}
+	})
+
+	previous := config.DB
+	config.DB = &config.Database{DB: sqlDB, Dialect: d}
+	tb.Cleanup(func() { config.DB = previous })
+	return config.DB
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go-art-api/config"
+
+	"github.com/gorilla/mux"
+)
+
+// downloadEntry is one artwork's row in the archive's index.html and metadata.csv
+type downloadEntry struct {
+	ArtworkID   int
+	Title       string
+	Grade       string
+	School      string
+	Description string
+	Mediums     string
+	CreatedAt   time.Time
+	Folder      string
+	File        string
+	ThumbFile   string
+}
+
+// DownloadArtistArchive streams a zip of every artwork for an artist, grouped
+// into folders by grade (default) or year, with index.html and metadata.csv.
+// Query params: group=grade|year, thumbs=true to include thumbnails.
+func DownloadArtistArchive(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	artistID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artist ID", http.StatusBadRequest)
+		return
+	}
+
+	group := r.URL.Query().Get("group")
+	if group == "" {
+		group = "grade"
+	}
+	if group != "grade" && group != "year" {
+		sendErrorResponse(w, "group must be 'grade' or 'year'", http.StatusBadRequest)
+		return
+	}
+	includeThumbs := r.URL.Query().Get("thumbs") == "true"
+	if !requireArtistRole(w, r, artistID, RoleViewer) {
+		return
+	}
+
+	var artistName string
+	err = config.DB.QueryRow("SELECT COALESCE(codename, name) FROM artists WHERE id = ?", artistID).Scan(&artistName)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Artist not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to fetch artist", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT a.id, a.title, a.grade, a.school, a.description, a.created_at, v.mediums, `+servedColumn("image")+`, `+servedColumn("thumb")+`
+		FROM artworks a
+		JOIN all_artwork_data v ON v.artwork_id = a.id
+		LEFT JOIN images i ON i.artwork_id = a.id AND i.deleted_at IS NULL
+		WHERE a.artist_id = ?
+		ORDER BY a.created_at, a.id
+	`, artistID)
+	if err != nil {
+		log.Printf("DB error fetching artworks for download: %v", err)
+		sendErrorResponse(w, "Failed to fetch artworks", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	// Large archives outlive the server's WriteTimeout, so lift it for this response
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-art.zip"`, slugify(artistName, "artist")))
+
+	// From here on the response is streaming; errors can only be logged
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	var entries []downloadEntry
+	usedNames := map[string]bool{}
+	for rows.Next() {
+		var e downloadEntry
+		var title, grade, school, description, mediums sql.NullString
+		var image, thumb []byte
+		if err := rows.Scan(&e.ArtworkID, &title, &grade, &school, &description, &e.CreatedAt, &mediums, &image, &thumb); err != nil {
+			log.Printf("Error scanning artwork for download: %v", err)
+			return
+		}
+		e.Title, e.Grade, e.School, e.Description, e.Mediums =
+			title.String, grade.String, school.String, description.String, mediums.String
+
+		if group == "year" {
+			e.Folder = strconv.Itoa(e.CreatedAt.Year())
+		} else {
+			e.Folder = slugify(e.Grade, "no-grade")
+		}
+
+		if len(image) > 0 {
+			base := uniqueName(usedNames, e.Folder, e.CreatedAt.Format("2006-01-02")+"_"+slugify(e.Title, "untitled"))
+			e.File = path.Join(e.Folder, base+".jpg")
+			if err := writeZipFile(zw, e.File, e.CreatedAt, image); err != nil {
+				log.Printf("Error writing %s to archive: %v", e.File, err)
+				return
+			}
+			if includeThumbs && len(thumb) > 0 {
+				e.ThumbFile = path.Join(e.Folder, "thumbs", base+".jpg")
+				if err := writeZipFile(zw, e.ThumbFile, e.CreatedAt, thumb); err != nil {
+					log.Printf("Error writing %s to archive: %v", e.ThumbFile, err)
+					return
+				}
+			}
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error reading artworks for download: %v", err)
+		return
+	}
+
+	if err := writeMetadataCSV(zw, entries); err != nil {
+		log.Printf("Error writing metadata.csv: %v", err)
+		return
+	}
+	if err := writeIndexHTML(zw, artistName, entries); err != nil {
+		log.Printf("Error writing index.html: %v", err)
+	}
+}
+
+// writeZipFile stores an already-compressed JPEG in the archive
+func writeZipFile(zw *zip.Writer, name string, modified time.Time, data []byte) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
+
+// writeMetadataCSV writes one row per artwork
+func writeMetadataCSV(zw *zip.Writer, entries []downloadEntry) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: "metadata.csv", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(fw)
+	cw.Write([]string{"artwork_id", "title", "grade", "school", "mediums", "description", "created_at", "file"})
+	for _, e := range entries {
+		cw.Write([]string{
+			strconv.Itoa(e.ArtworkID), e.Title, e.Grade, e.School, e.Mediums, e.Description,
+			e.CreatedAt.Format("2006-01-02"), e.File,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var downloadIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>{{.Artist}} - Art Archive</title>
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; margin: 40px; color: #333; }
+        .grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(220px, 1fr)); gap: 20px; }
+        .card img { width: 100%; border-radius: 6px; }
+        .meta { font-size: 0.85em; color: #666; }
+    </style>
+</head>
+<body>
+    <h1>🎨 {{.Artist}}</h1>
+    <p>{{len .Entries}} artworks, downloaded {{.Generated}}</p>
+    {{range .Folders}}
+    <h2>{{.Name}}</h2>
+    <div class="grid">
+        {{range .Entries}}
+        <div class="card">
+            {{if .File}}<a href="{{.File}}"><img src="{{if .ThumbFile}}{{.ThumbFile}}{{else}}{{.File}}{{end}}" alt="{{.Title}}"></a>{{end}}
+            <strong>{{if .Title}}{{.Title}}{{else}}Untitled{{end}}</strong>
+            <div class="meta">{{.CreatedAt.Format "Jan 2, 2006"}}{{if .School}} · {{.School}}{{end}}{{if .Mediums}} · {{.Mediums}}{{end}}</div>
+            {{if .Description}}<p>{{.Description}}</p>{{end}}
+        </div>
+        {{end}}
+    </div>
+    {{end}}
+</body>
+</html>
+`))
+
+// writeIndexHTML writes a browsable gallery page linking to the images
+func writeIndexHTML(zw *zip.Writer, artist string, entries []downloadEntry) error {
+	type folder struct {
+		Name    string
+		Entries []downloadEntry
+	}
+
+	var folders []folder
+	index := map[string]int{}
+	for _, e := range entries {
+		i, ok := index[e.Folder]
+		if !ok {
+			i = len(folders)
+			index[e.Folder] = i
+			folders = append(folders, folder{Name: e.Folder})
+		}
+		folders[i].Entries = append(folders[i].Entries, e)
+	}
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: "index.html", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	return downloadIndexTemplate.Execute(fw, map[string]interface{}{
+		"Artist":    artist,
+		"Entries":   entries,
+		"Folders":   folders,
+		"Generated": time.Now().Format("Jan 2, 2006"),
+	})
+}
+
+// uniqueName returns base, or base-2, base-3... if already used in the folder
+func uniqueName(used map[string]bool, folder, base string) string {
+	name := base
+	for n := 2; used[folder+"/"+name]; n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	used[folder+"/"+name] = true
+	return name
+}
+
+// slugify turns a title into a lowercase, filename-safe slug
+func slugify(s, fallback string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	slug := []rune(strings.TrimSuffix(b.String(), "-"))
+	if len(slug) > 60 {
+		slug = []rune(strings.TrimSuffix(string(slug[:60]), "-"))
+	}
+	if len(slug) == 0 {
+		return fallback
+	}
+	return string(slug)
+}
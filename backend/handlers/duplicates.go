@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"go-art-api/config"
+	"go-art-api/models"
+	"go-art-api/utils"
+)
+
+// duplicateDistance is how many bits apart two perceptual hashes can be and
+// still count as the same drawing. Different drawings are usually 20 or more
+// apart; reshoots of the same one, under 10.
+const duplicateDistance = 10
+
+// hashedImage is a live image's perceptual hash and what it belongs to
+type hashedImage struct {
+	models.DuplicateArtwork
+	hash uint64
+}
+
+// GetDuplicates lists groups of artworks whose images look like the same
+// drawing, for cleaning up double uploads. It covers the artists the user is
+// linked to, or just ?artist_id=; ?distance= (0-32) loosens or tightens the match.
+func GetDuplicates(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	distance := duplicateDistance
+	if s := r.URL.Query().Get("distance"); s != "" {
+		d, err := strconv.Atoi(s)
+		if err != nil || d < 0 || d > 32 {
+			sendErrorResponse(w, "distance must be between 0 and 32", http.StatusBadRequest)
+			return
+		}
+		distance = d
+	}
+
+	var artistIDs []int
+	if s := r.URL.Query().Get("artist_id"); s != "" {
+		artistID, err := strconv.Atoi(s)
+		if err != nil {
+			sendErrorResponse(w, "Invalid artist ID", http.StatusBadRequest)
+			return
+		}
+		if !requireArtistRole(w, r, artistID, RoleViewer) {
+			return
+		}
+		artistIDs = []int{artistID}
+	} else {
+		var err error
+		if artistIDs, err = userFamilyArtistIDs(auth.UserID); err != nil {
+			sendErrorResponse(w, "Failed to resolve family", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	images, err := familyHashes(artistIDs)
+	if err != nil {
+		log.Printf("DB error fetching image hashes: %v", err)
+		sendErrorResponse(w, "Failed to fetch duplicates", http.StatusInternalServerError)
+		return
+	}
+
+	// Union-find over every close pair, so A~B and B~C put all three together
+	parent := make([]int, len(images))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range images {
+		for j := i + 1; j < len(images); j++ {
+			if utils.HashDistance(images[i].hash, images[j].hash) <= distance {
+				parent[root(j)] = root(i)
+			}
+		}
+	}
+
+	groups := map[int][]hashedImage{}
+	for i, img := range images {
+		groups[root(i)] = append(groups[root(i)], img)
+	}
+	clusters := []models.DuplicateCluster{}
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		cluster := models.DuplicateCluster{}
+		for i, img := range group {
+			cluster.Artworks = append(cluster.Artworks, img.DuplicateArtwork)
+			for _, other := range group[i+1:] {
+				if d := utils.HashDistance(img.hash, other.hash); d > cluster.MaxDistance {
+					cluster.MaxDistance = d
+				}
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Artworks[0].ArtworkID < clusters[j].Artworks[0].ArtworkID })
+
+	sendJSONResponse(w, clusters, http.StatusOK)
+}
+
+// possibleDuplicates returns the other artworks whose image looks like hash,
+// among the artists the uploading user is linked to (so never artworks they
+// can't open). It only feeds a warning, so failures are logged rather than
+// returned.
+func possibleDuplicates(userID, artworkID int, hash uint64) []int {
+	ids := []int{}
+	artistIDs, err := userFamilyArtistIDs(userID)
+	if err != nil {
+		log.Printf("DB error resolving artists of user %d: %v", userID, err)
+		return ids
+	}
+	images, err := familyHashes(artistIDs)
+	if err != nil {
+		log.Printf("DB error fetching image hashes: %v", err)
+		return ids
+	}
+	for _, img := range images {
+		if img.ArtworkID != artworkID && utils.HashDistance(img.hash, hash) <= duplicateDistance {
+			ids = append(ids, img.ArtworkID)
+		}
+	}
+	return ids
+}
+
+// familyHashes loads the hashes of the images of a set of artists, leaving
+// out anything in the trash, in artwork order
+func familyHashes(artistIDs []int) ([]hashedImage, error) {
+	if len(artistIDs) == 0 {
+		return nil, nil
+	}
+	placeholders, args := inClause(artistIDs)
+	rows, err := config.DB.Query(fmt.Sprintf(`
+		SELECT i.id, a.id, a.artist_id, a.title, i.phash
+		FROM images i
+		JOIN artworks a ON a.id = i.artwork_id
+		JOIN artists ar ON ar.id = a.artist_id
+		WHERE a.artist_id IN (%s) AND i.phash IS NOT NULL
+			AND i.deleted_at IS NULL AND a.deleted_at IS NULL AND ar.deleted_at IS NULL
+		ORDER BY a.id
+	`, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []hashedImage
+	for rows.Next() {
+		var img hashedImage
+		var title sql.NullString
+		var hash int64
+		if err := rows.Scan(&img.ImageID, &img.ArtworkID, &img.ArtistID, &title, &hash); err != nil {
+			return nil, err
+		}
+		img.Title, img.hash = title.String, uint64(hash)
+		images = append(images, img)
+	}
+	return images, rows.Err()
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"go-art-api/audit"
+	"go-art-api/config"
+	"go-art-api/models"
+	"go-art-api/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// EditArtworkImage regenerates an artwork's image from its original with the
+// given rotate, flip, perspective and crop (editors and owners). Edits always
+// start from the original, so sending new ones replaces the old rather than
+// adding to them, and sending none gets the original back. The renditions
+// being replaced are kept as a revision.
+func EditArtworkImage(w http.ResponseWriter, r *http.Request) {
+	artworkID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artwork ID", http.StatusBadRequest)
+		return
+	}
+	artistID, ok := requireArtworkRole(w, r, artworkID, RoleEditor)
+	if !ok {
+		return
+	}
+
+	var edits models.ImageEdits
+	if err := json.NewDecoder(r.Body).Decode(&edits); err != nil {
+		sendErrorResponse(w, "Invalid JSON data", http.StatusBadRequest)
+		return
+	}
+	if err := utils.ValidateEdits(edits); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var imageID int
+	var original, rendition []byte
+	var beforeEdits sql.NullString
+	before := imageAudit{ArtworkID: artworkID}
+	err = config.DB.QueryRow(`
+		SELECT id, original_mime, original, image, thumb_bytes, image_bytes, edits
+		FROM images WHERE artwork_id = ? AND deleted_at IS NULL
+	`, artworkID).Scan(&imageID, &before.Mime, &original, &rendition, &before.ThumbBytes, &before.ImageBytes, &beforeEdits)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Artwork has no image", http.StatusNotFound)
+		return
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to fetch image", http.StatusInternalServerError)
+		return
+	}
+	before.Edits = parseEdits(beforeEdits)
+
+	// Images uploaded before originals were kept can only start from their full-size rendition
+	source := original
+	if len(source) == 0 {
+		source = rendition
+	}
+	thumbData, imageData, hash, err := utils.EditImage(bytes.NewReader(source), edits)
+	if err != nil {
+		sendErrorResponse(w, fmt.Sprintf("Image edit failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	editsJSON, err := json.Marshal(edits)
+	if err != nil {
+		sendErrorResponse(w, "Failed to save edits", http.StatusInternalServerError)
+		return
+	}
+	var storedEdits interface{} = string(editsJSON)
+	after := imageAudit{ArtworkID: artworkID, Mime: before.Mime, ThumbBytes: len(thumbData), ImageBytes: len(imageData), Edits: &edits}
+	if string(editsJSON) == "{}" {
+		storedEdits, after.Edits = nil, nil
+	}
+
+	palette, storedPalette := imagePalette(thumbData)
+	blurhash := imageBlurHash(thumbData)
+	auth, _ := currentAuth(r)
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to save edited image", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// The current renditions move into history rather than going away, with
+	// a copy of the original
+	if quotaErr := checkStorageQuota(tx, artistID, int64(len(thumbData)+len(imageData)+len(original)), 0); quotaErr != nil {
+		sendQuotaError(w, quotaErr)
+		return
+	}
+
+	err = saveRevision(tx, artworkID, auth.UserID, true)
+	if err == nil {
+		_, err = tx.Exec(
+			"UPDATE images SET thumb = ?, image = ?, version = version + 1, thumb_bytes = ?, image_bytes = ?, edits = ?, phash = ?, palette = ?, blurhash = ? WHERE id = ?",
+			thumbData, imageData, len(thumbData), len(imageData), storedEdits, int64(hash), storedPalette, blurhash, imageID,
+		)
+	}
+	if err == nil {
+		err = audit.Record(tx, audit.Entry{
+			ActorID: auth.UserID, Action: audit.Update, Entity: audit.Image, EntityID: imageID, ArtistID: artistID,
+			Before: before, After: after,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("DB error saving edited image of artwork %d: %v", artworkID, err)
+		sendErrorResponse(w, "Failed to save edited image", http.StatusInternalServerError)
+		return
+	}
+	refreshEnhanced(artworkID)
+	uncacheImage(imageID)
+
+	sendSuccessResponse(w, map[string]interface{}{
+		"image_id":   imageID,
+		"artwork_id": artworkID,
+		"edits":      edits,
+		"palette":    palette,
+		"blurhash":   blurhash,
+		"thumb_size": fmt.Sprintf("%.2f KB", float64(len(thumbData))/1024),
+		"image_size": fmt.Sprintf("%.2f KB", float64(len(imageData))/1024),
+	}, "Image edited", http.StatusOK)
+}
+
+// GetImageEdits returns the edits behind an artwork's current image, so they
+// can be adjusted and sent again
+func GetImageEdits(w http.ResponseWriter, r *http.Request) {
+	artworkID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artwork ID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireArtworkRole(w, r, artworkID, RoleViewer); !ok {
+		return
+	}
+
+	var edits sql.NullString
+	var originalBytes int
+	err = config.DB.QueryRow(
+		"SELECT edits, original_bytes FROM images WHERE artwork_id = ? AND deleted_at IS NULL", artworkID,
+	).Scan(&edits, &originalBytes)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Artwork has no image", http.StatusNotFound)
+		return
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to fetch image", http.StatusInternalServerError)
+		return
+	}
+
+	current := parseEdits(edits)
+	if current == nil {
+		current = &models.ImageEdits{}
+	}
+	sendJSONResponse(w, map[string]interface{}{
+		"edits":        current,
+		"has_original": originalBytes > 0,
+	}, http.StatusOK)
+}
+
+// GetOriginalImage serves an artwork's image as it was uploaded, before any
+// edits or scaling
+func GetOriginalImage(w http.ResponseWriter, r *http.Request) {
+	artworkID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artwork ID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireArtworkRole(w, r, artworkID, RoleViewer); !ok {
+		return
+	}
+
+	var original []byte
+	err = config.DB.QueryRow(
+		"SELECT original FROM images WHERE artwork_id = ? AND deleted_at IS NULL", artworkID,
+	).Scan(&original)
+	if err == sql.ErrNoRows || (err == nil && len(original) == 0) {
+		sendErrorResponse(w, "No original kept for this image", http.StatusNotFound)
+		return
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to fetch image", http.StatusInternalServerError)
+		return
+	}
+
+	// Sniffed rather than trusting the MIME type the uploader claimed
+	w.Header().Set("Content-Type", http.DetectContentType(original))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	if _, err := w.Write(original); err != nil {
+		log.Printf("Error writing original image to response: %v", err)
+	}
+}
+
+// parseEdits decodes stored edits, or returns nil for none
+func parseEdits(s sql.NullString) *models.ImageEdits {
+	if !s.Valid {
+		return nil
+	}
+	var edits models.ImageEdits
+	if err := json.Unmarshal([]byte(s.String), &edits); err != nil {
+		log.Printf("Ignoring unreadable image edits %q: %v", s.String, err)
+		return nil
+	}
+	return &edits
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"go-art-api/audit"
+	"go-art-api/config"
+	"go-art-api/models"
+	"go-art-api/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// SetAutoEnhance turns auto-enhance on or off for an artwork (editors and
+// owners). While it is on, the artwork's image is kept in a second, enhanced
+// pair of renditions and those are served by default. The plain pair stays
+// as it is, so turning it off again loses nothing.
+func SetAutoEnhance(w http.ResponseWriter, r *http.Request) {
+	artworkID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artwork ID", http.StatusBadRequest)
+		return
+	}
+	artistID, ok := requireArtworkRole(w, r, artworkID, RoleEditor)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		sendErrorResponse(w, `Send {"enabled": true} or {"enabled": false}`, http.StatusBadRequest)
+		return
+	}
+
+	before, err := fetchArtwork(artworkID)
+	if err != nil {
+		sendErrorResponse(w, "Failed to fetch artwork", http.StatusInternalServerError)
+		return
+	}
+	after := before
+	after.AutoEnhance = *req.Enabled
+
+	// An artwork without an image yet gets enhanced once one is uploaded
+	var thumbData, imageData []byte
+	var paper []models.ImagePoint
+	var existing int64
+	if after.AutoEnhance {
+		thumbData, imageData, paper, err = enhancedRenditions(artworkID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("Error enhancing image of artwork %d: %v", artworkID, err)
+			sendErrorResponse(w, "Failed to enhance image", http.StatusInternalServerError)
+			return
+		}
+		err = config.DB.QueryRow(
+			"SELECT enhanced_thumb_bytes + enhanced_image_bytes FROM images WHERE artwork_id = ?", artworkID,
+		).Scan(&existing)
+		if err != nil && err != sql.ErrNoRows {
+			sendErrorResponse(w, "Failed to fetch image", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	auth, _ := currentAuth(r)
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to save auto-enhance", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if after.AutoEnhance {
+		if quotaErr := checkStorageQuota(tx, artistID, int64(len(thumbData)+len(imageData)), existing); quotaErr != nil {
+			sendQuotaError(w, quotaErr)
+			return
+		}
+	}
+
+	_, err = tx.Exec("UPDATE artworks SET auto_enhance = ? WHERE id = ?", after.AutoEnhance, artworkID)
+	if err == nil {
+		err = storeEnhanced(tx, artworkID, thumbData, imageData)
+	}
+	if err == nil && after.AutoEnhance != before.AutoEnhance {
+		err = audit.Record(tx, audit.Entry{
+			ActorID: auth.UserID, Action: audit.Update, Entity: audit.Artwork, EntityID: artworkID, ArtistID: artistID,
+			Before: before, After: after,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("DB error saving auto-enhance of artwork %d: %v", artworkID, err)
+		sendErrorResponse(w, "Failed to save auto-enhance", http.StatusInternalServerError)
+		return
+	}
+	uncacheArtwork(artworkID)
+
+	sendSuccessResponse(w, map[string]interface{}{
+		"artwork_id":    artworkID,
+		"auto_enhance":  after.AutoEnhance,
+		"paper":         paper,
+		"enhanced_size": fmt.Sprintf("%.2f KB", float64(len(imageData))/1024),
+	}, "Auto-enhance saved", http.StatusOK)
+}
+
+// enhancedRenditions makes the auto-enhanced renditions of an artwork's
+// current image, from its original with the same edits. sql.ErrNoRows means
+// it has no image.
+func enhancedRenditions(artworkID int) (thumbData, imageData []byte, paper []models.ImagePoint, err error) {
+	var original, rendition []byte
+	var stored sql.NullString
+	err = config.DB.QueryRow(
+		"SELECT original, image, edits FROM images WHERE artwork_id = ? AND deleted_at IS NULL", artworkID,
+	).Scan(&original, &rendition, &stored)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// As with edits, images from before originals were kept start from their full-size rendition
+	source := original
+	if len(source) == 0 {
+		source = rendition
+	}
+	var edits models.ImageEdits
+	if e := parseEdits(stored); e != nil {
+		edits = *e
+	}
+	return utils.EnhanceImage(bytes.NewReader(source), edits)
+}
+
+// storeEnhanced saves an artwork's enhanced renditions, or clears them if
+// they are nil. Either may change what's served, so the image's version goes up.
+func storeEnhanced(q audit.Execer, artworkID int, thumbData, imageData []byte) error {
+	_, err := q.Exec(
+		"UPDATE images SET enhanced_thumb = ?, enhanced_image = ?, enhanced_thumb_bytes = ?, enhanced_image_bytes = ?, version = version + 1 WHERE artwork_id = ?",
+		thumbData, imageData, len(thumbData), len(imageData), artworkID,
+	)
+	return err
+}
+
+// refreshEnhanced brings an artwork's enhanced renditions in line with a new
+// or edited image: remade while auto-enhance is on, cleared otherwise. If
+// they can't be made the plain renditions are served instead.
+func refreshEnhanced(artworkID int) {
+	var enabled bool
+	if err := config.DB.QueryRow("SELECT auto_enhance FROM artworks WHERE id = ?", artworkID).Scan(&enabled); err != nil {
+		log.Printf("DB error checking auto-enhance of artwork %d: %v", artworkID, err)
+		return
+	}
+
+	var thumbData, imageData []byte
+	if enabled {
+		var err error
+		thumbData, imageData, _, err = enhancedRenditions(artworkID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("Error enhancing image of artwork %d, serving it as it is: %v", artworkID, err)
+		}
+	}
+	if err := storeEnhanced(config.DB, artworkID, thumbData, imageData); err != nil {
+		log.Printf("DB error saving enhanced image of artwork %d: %v", artworkID, err)
+	}
+}
+
+// servedColumn is the SQL for the image column served by default: the
+// enhanced rendition while the artwork (a) has auto-enhance on and one has
+// been made, or else the plain one (i)
+func servedColumn(column string) string {
+	return fmt.Sprintf("CASE WHEN a.auto_enhance AND i.enhanced_%[1]s IS NOT NULL THEN i.enhanced_%[1]s ELSE i.%[1]s END", column)
+}
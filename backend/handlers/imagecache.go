@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"go-art-api/config"
+	"go-art-api/imagecache"
+)
+
+// imageCache holds recently served renditions, keyed by image ID and what
+// was asked for (e.g. "thumb" or "enhanced_image"). Anything that changes
+// what an image serves drops it with uncacheImage or uncacheArtwork.
+var imageCache = imagecache.New(0)
+
+// SetImageCacheSize sets how much image data is kept in memory; 0 disables
+// the cache. Call it at startup.
+func SetImageCacheSize(maxBytes int64) {
+	imageCache.Resize(maxBytes)
+}
+
+// GetImageCacheStats reports the image cache's hits, misses, evictions and
+// current size
+func GetImageCacheStats(w http.ResponseWriter, r *http.Request) {
+	sendJSONResponse(w, imageCache.Stats(), http.StatusOK)
+}
+
+// uncacheImage drops every cached rendition of an image
+func uncacheImage(imageID int) {
+	imageCache.RemoveImage(imageID)
+}
+
+// uncacheArtwork drops the cached renditions of an artwork's image
+func uncacheArtwork(artworkID int) {
+	var imageID int
+	err := config.DB.QueryRow("SELECT id FROM images WHERE artwork_id = ?", artworkID).Scan(&imageID)
+	if err == sql.ErrNoRows {
+		return
+	} else if err != nil {
+		// Without the ID, play safe
+		log.Printf("Clearing image cache, couldn't find the image of artwork %d: %v", artworkID, err)
+		imageCache.Clear()
+		return
+	}
+	imageCache.RemoveImage(imageID)
+}
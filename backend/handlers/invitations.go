@@ -0,0 +1,485 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-art-api/audit"
+	"go-art-api/config"
+	"go-art-api/mailer"
+	"go-art-api/models"
+	"go-art-api/utils"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	invitationTTL        = 7 * 24 * time.Hour
+	maxInvitationArtists = 20
+)
+
+var errInvalidInvitation = errors.New("invitation is invalid, expired or already used")
+
+// pendingInvitation matches invitations that can still be accepted or resent
+const pendingInvitation = "i.accepted_at IS NULL AND i.cancelled_at IS NULL"
+
+// CreateInvitation invites someone by email to one or more artists, each with
+// its own role. The inviter must own every artist in the invitation.
+func CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	var req models.InvitationCreate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid JSON data", http.StatusBadRequest)
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" || len(req.Email) > 60 || !strings.Contains(req.Email, "@") {
+		sendErrorResponse(w, "a valid email is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Artists) == 0 || len(req.Artists) > maxInvitationArtists {
+		sendErrorResponse(w, fmt.Sprintf("invite to 1-%d artists", maxInvitationArtists), http.StatusBadRequest)
+		return
+	}
+	seen := map[int]bool{}
+	for _, a := range req.Artists {
+		if !validRole(a.Role) {
+			sendErrorResponse(w, "role must be owner, editor or viewer", http.StatusBadRequest)
+			return
+		}
+		if seen[a.ArtistID] {
+			sendErrorResponse(w, "each artist may only appear once", http.StatusBadRequest)
+			return
+		}
+		seen[a.ArtistID] = true
+		if !requireArtistRole(w, r, a.ArtistID, RoleOwner) {
+			return
+		}
+	}
+	if !allowRequest(w, mailEmailLimiter, accountKey(req.Email)) {
+		return
+	}
+
+	token, err := utils.GenerateSecureToken(accountTokenLength)
+	if err != nil {
+		sendErrorResponse(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	id, err := tx.Insert(
+		"INSERT INTO invitations (email, token_hash, invited_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		req.Email, utils.HashToken(token), auth.UserID, now, now.Add(invitationTTL),
+	)
+	if err != nil {
+		log.Printf("DB error creating invitation: %v", err)
+		sendErrorResponse(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+	for _, a := range req.Artists {
+		if _, err := tx.Exec(
+			"INSERT INTO invitation_artists (invitation_id, artist_id, role) VALUES (?, ?, ?)", id, a.ArtistID, a.Role,
+		); err != nil {
+			log.Printf("DB error adding artist %d to invitation %d: %v", a.ArtistID, id, err)
+			sendErrorResponse(w, "Failed to create invitation", http.StatusInternalServerError)
+			return
+		}
+		if err := audit.Record(tx, audit.Entry{
+			ActorID: auth.UserID, Action: audit.Create, Entity: audit.Invitation, EntityID: int(id), ArtistID: a.ArtistID,
+			After: invitationAudit{Email: req.Email, Role: a.Role, ExpiresAt: now.Add(invitationTTL)},
+		}); err != nil {
+			sendErrorResponse(w, "Failed to create invitation", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		sendErrorResponse(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+
+	invitation, err := fetchInvitation(int(id))
+	if err != nil {
+		log.Printf("DB error fetching invitation %d: %v", id, err)
+		sendErrorResponse(w, "Failed to fetch invitation", http.StatusInternalServerError)
+		return
+	}
+	sendInvitationEmail(invitation, token)
+
+	sendJSONResponse(w, invitation, http.StatusCreated)
+}
+
+// GetInvitations lists pending invitations to artists the user owns,
+// including expired ones so they can be resent
+func GetInvitations(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	invitations, err := fetchInvitations(pendingInvitation+` AND i.id IN (
+		SELECT ia.invitation_id FROM invitation_artists ia
+		JOIN user_artists ua ON ua.artist_id = ia.artist_id
+		WHERE ua.user_id = ? AND ua.role = ?
+	)`, auth.UserID, RoleOwner)
+	if err != nil {
+		log.Printf("DB error fetching invitations: %v", err)
+		sendErrorResponse(w, "Failed to fetch invitations", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSONResponse(w, invitations, http.StatusOK)
+}
+
+// GetReceivedInvitations lists pending invitations sent to the user's email
+func GetReceivedInvitations(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	invitations, err := fetchInvitations(
+		pendingInvitation+" AND LOWER(i.email) = (SELECT LOWER(email) FROM users WHERE id = ?)", auth.UserID,
+	)
+	if err != nil {
+		log.Printf("DB error fetching received invitations: %v", err)
+		sendErrorResponse(w, "Failed to fetch invitations", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSONResponse(w, invitations, http.StatusOK)
+}
+
+// ResendInvitation emails a fresh link and restarts the expiry. The old link
+// stops working.
+func ResendInvitation(w http.ResponseWriter, r *http.Request) {
+	invitation, ok := manageableInvitation(w, r)
+	if !ok {
+		return
+	}
+	if !allowRequest(w, mailEmailLimiter, accountKey(invitation.Email)) {
+		return
+	}
+
+	token, err := utils.GenerateSecureToken(accountTokenLength)
+	if err != nil {
+		sendErrorResponse(w, "Failed to resend invitation", http.StatusInternalServerError)
+		return
+	}
+
+	expiresAt := time.Now().UTC().Add(invitationTTL)
+	if err := changeInvitation(r, audit.Update, invitation, expiresAt,
+		"UPDATE invitations SET token_hash = ?, expires_at = ? WHERE id = ?",
+		utils.HashToken(token), expiresAt, invitation.ID,
+	); err != nil {
+		log.Printf("DB error resending invitation %d: %v", invitation.ID, err)
+		sendErrorResponse(w, "Failed to resend invitation", http.StatusInternalServerError)
+		return
+	}
+	invitation.ExpiresAt, invitation.Expired = expiresAt, false
+	sendInvitationEmail(invitation, token)
+
+	sendSuccessResponse(w, invitation, "Invitation resent", http.StatusOK)
+}
+
+// CancelInvitation withdraws a pending invitation
+func CancelInvitation(w http.ResponseWriter, r *http.Request) {
+	invitation, ok := manageableInvitation(w, r)
+	if !ok {
+		return
+	}
+
+	if err := changeInvitation(r, audit.Delete, invitation, time.Time{},
+		"UPDATE invitations SET cancelled_at = ? WHERE id = ?", time.Now().UTC(), invitation.ID,
+	); err != nil {
+		log.Printf("DB error cancelling invitation %d: %v", invitation.ID, err)
+		sendErrorResponse(w, "Failed to cancel invitation", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvitation links the signed-in user to the invitation's artists. The
+// emailed token is what grants access, so the invitee may accept with an
+// account under a different address. Existing access is never downgraded.
+func AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	var req models.InvitationAccept
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		sendErrorResponse(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to accept invitation", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var invitationID int
+	var expiresAt time.Time
+	var acceptedAt, cancelledAt sql.NullTime
+	err = tx.QueryRow(
+		"SELECT id, expires_at, accepted_at, cancelled_at FROM invitations WHERE token_hash = ?",
+		utils.HashToken(req.Token),
+	).Scan(&invitationID, &expiresAt, &acceptedAt, &cancelledAt)
+	if err == sql.ErrNoRows || (err == nil && (acceptedAt.Valid || cancelledAt.Valid || time.Now().After(expiresAt))) {
+		sendErrorResponse(w, errInvalidInvitation.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("DB error looking up invitation: %v", err)
+		sendErrorResponse(w, "Failed to accept invitation", http.StatusInternalServerError)
+		return
+	}
+
+	// Claim the invitation first so two concurrent accepts can't both apply it
+	result, err := tx.Exec(
+		"UPDATE invitations SET accepted_at = ?, accepted_by = ? WHERE id = ? AND accepted_at IS NULL",
+		time.Now().UTC(), auth.UserID, invitationID,
+	)
+	if err != nil {
+		log.Printf("DB error accepting invitation %d: %v", invitationID, err)
+		sendErrorResponse(w, "Failed to accept invitation", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		sendErrorResponse(w, errInvalidInvitation.Error(), http.StatusBadRequest)
+		return
+	}
+
+	granted, err := applyInvitation(tx, invitationID, auth.UserID)
+	if err == errInvalidInvitation {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("DB error applying invitation %d: %v", invitationID, err)
+		sendErrorResponse(w, "Failed to accept invitation", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		sendErrorResponse(w, "Failed to accept invitation", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, granted, "Invitation accepted", http.StatusOK)
+}
+
+// applyInvitation links the user to each invited artist, keeping whichever
+// of the existing and invited roles gives more access. Only artists the
+// inviter still owns count: someone removed or demoted since can't hand out
+// access through invitations they sent before. With none left the
+// invitation is errInvalidInvitation.
+func applyInvitation(tx *config.Tx, invitationID, userID int) ([]models.UserArtist, error) {
+	rows, err := tx.Query(`
+		SELECT ia.artist_id, ia.role FROM invitation_artists ia
+		JOIN invitations i ON i.id = ia.invitation_id
+		JOIN user_artists ua ON ua.artist_id = ia.artist_id AND ua.user_id = i.invited_by
+		JOIN artists a ON a.id = ia.artist_id
+		WHERE ia.invitation_id = ? AND ua.role = ? AND a.deleted_at IS NULL
+	`, invitationID, RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	var invited []models.UserArtist
+	for rows.Next() {
+		ua := models.UserArtist{UserID: userID}
+		if err := rows.Scan(&ua.ArtistID, &ua.Role); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		invited = append(invited, ua)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(invited) == 0 {
+		return nil, errInvalidInvitation
+	}
+
+	for i, ua := range invited {
+		var current string
+		err := tx.QueryRow(
+			"SELECT role FROM user_artists WHERE user_id = ? AND artist_id = ?", userID, ua.ArtistID,
+		).Scan(&current)
+		entry := audit.Entry{ActorID: userID, Entity: audit.Member, EntityID: userID, ArtistID: ua.ArtistID, After: ua}
+		switch {
+		case err == sql.ErrNoRows:
+			entry.Action = audit.Create
+			_, err = tx.Exec("INSERT INTO user_artists (user_id, artist_id, role) VALUES (?, ?, ?)", userID, ua.ArtistID, ua.Role)
+		case err != nil:
+		case roleRank[ua.Role] > roleRank[current]:
+			entry.Action = audit.Update
+			entry.Before = models.UserArtist{UserID: userID, ArtistID: ua.ArtistID, Role: current}
+			_, err = tx.Exec("UPDATE user_artists SET role = ? WHERE user_id = ? AND artist_id = ?", ua.Role, userID, ua.ArtistID)
+		default:
+			invited[i].Role = current
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := audit.Record(tx, audit.Entry{
+			ActorID: userID, Action: audit.Update, Entity: audit.Invitation, EntityID: invitationID, ArtistID: ua.ArtistID,
+			After: map[string]int{"accepted_by": userID},
+		}); err != nil {
+			return nil, err
+		}
+		if entry.Action != "" {
+			if err := audit.Record(tx, entry); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return invited, nil
+}
+
+// invitationAudit is how an invitation appears in the audit log: one entry
+// per artist, without the token
+type invitationAudit struct {
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// changeInvitation runs an update on an invitation and records the change to
+// each artist's part of it in the same transaction. A zero expiresAt means
+// the invitation is gone afterwards.
+func changeInvitation(r *http.Request, action string, invitation *models.Invitation, expiresAt time.Time, query string, args ...interface{}) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+	auth, _ := currentAuth(r)
+	for _, a := range invitation.Artists {
+		before := invitationAudit{Email: invitation.Email, Role: a.Role, ExpiresAt: invitation.ExpiresAt}
+		entry := audit.Entry{
+			ActorID: auth.UserID, Action: action, Entity: audit.Invitation, EntityID: invitation.ID, ArtistID: a.ArtistID, Before: before,
+		}
+		if !expiresAt.IsZero() {
+			after := before
+			after.ExpiresAt = expiresAt
+			entry.After = after
+		}
+		if err := audit.Record(tx, entry); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// manageableInvitation loads the pending invitation in the path if the user
+// owns one of its artists; otherwise it writes a 404. Having sent it isn't
+// enough, as the sender may since have lost their access.
+func manageableInvitation(w http.ResponseWriter, r *http.Request) (*models.Invitation, bool) {
+	auth, _ := currentAuth(r)
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid invitation ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	invitations, err := fetchInvitations(pendingInvitation+` AND i.id = ? AND i.id IN (
+		SELECT ia.invitation_id FROM invitation_artists ia
+		JOIN user_artists ua ON ua.artist_id = ia.artist_id
+		WHERE ua.user_id = ? AND ua.role = ?
+	)`, id, auth.UserID, RoleOwner)
+	if err != nil {
+		log.Printf("DB error fetching invitation %d: %v", id, err)
+		sendErrorResponse(w, "Failed to fetch invitation", http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(invitations) == 0 {
+		sendErrorResponse(w, "Invitation not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &invitations[0], true
+}
+
+// fetchInvitation loads one invitation regardless of its state
+func fetchInvitation(id int) (*models.Invitation, error) {
+	invitations, err := fetchInvitations("i.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(invitations) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &invitations[0], nil
+}
+
+// fetchInvitations loads the invitations matching where (aliased as i) with
+// their artists
+func fetchInvitations(where string, args ...interface{}) ([]models.Invitation, error) {
+	rows, err := config.DB.Query(`
+		SELECT i.id, i.email, u.fname, u.lname, i.created_at, i.expires_at,
+		       ia.artist_id, COALESCE(ar.codename, ar.name), ia.role
+		FROM invitations i
+		JOIN users u ON u.id = i.invited_by
+		JOIN invitation_artists ia ON ia.invitation_id = i.id
+		JOIN artists ar ON ar.id = ia.artist_id
+		WHERE `+where+`
+		ORDER BY i.created_at DESC, i.id DESC, ar.name
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	invitations := []models.Invitation{}
+	index := map[int]int{}
+	for rows.Next() {
+		var inv models.Invitation
+		var fname, lname string
+		var a models.InvitationArtist
+		if err := rows.Scan(&inv.ID, &inv.Email, &fname, &lname, &inv.CreatedAt, &inv.ExpiresAt,
+			&a.ArtistID, &a.ArtistName, &a.Role); err != nil {
+			return nil, err
+		}
+
+		i, ok := index[inv.ID]
+		if !ok {
+			inv.InvitedBy = strings.TrimSpace(fname + " " + lname)
+			inv.Expired = now.After(inv.ExpiresAt)
+			i = len(invitations)
+			index[inv.ID] = i
+			invitations = append(invitations, inv)
+		}
+		invitations[i].Artists = append(invitations[i].Artists, a)
+	}
+	return invitations, rows.Err()
+}
+
+// sendInvitationEmail mails the invitation link
+func sendInvitationEmail(invitation *models.Invitation, token string) {
+	var lines []string
+	for _, a := range invitation.Artists {
+		lines = append(lines, fmt.Sprintf("  - %s (%s)", a.ArtistName, a.Role))
+	}
+
+	link := fmt.Sprintf("%s/invite?token=%s", config.AppURL(), token)
+	sendMail(mailer.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("%s invited you to Go Art", invitation.InvitedBy),
+		Body: fmt.Sprintf("Hi,\n\n%s invited you to see the artwork of:\n\n%s\n\n"+
+			"Open this link to accept. You can create an account or log in first; the link works for 7 days:\n\n%s\n",
+			invitation.InvitedBy, strings.Join(lines, "\n"), link),
+	})
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go-art-api/config"
+	"go-art-api/models"
+)
+
+const (
+	defaultListingLimit = 100
+	maxListingLimit     = 500
+)
+
+// GetArtworksView lists artworks from the all_artwork_data view, oldest
+// first, for galleries. Rows carry each image's thumb_url and blurhash rather
+// than the thumbnail, so clients fetch thumbnails as they scroll into view.
+// It covers the artists the user is linked to, or just ?artist_id=. Filters:
+// grade, school, after (an artwork ID, for paging) and limit.
+func GetArtworksView(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+	q := r.URL.Query()
+
+	var where []string
+	var args []interface{}
+	if s := q.Get("artist_id"); s != "" {
+		artistID, err := strconv.Atoi(s)
+		if err != nil {
+			sendErrorResponse(w, "Invalid artist ID", http.StatusBadRequest)
+			return
+		}
+		if !requireArtistRole(w, r, artistID, RoleViewer) {
+			return
+		}
+		where = append(where, "v.artist_id = ?")
+		args = append(args, artistID)
+	} else {
+		where = append(where, "v.artist_id IN (SELECT artist_id FROM user_artists WHERE user_id = ?)")
+		args = append(args, auth.UserID)
+	}
+	if grade := q.Get("grade"); grade != "" {
+		where = append(where, "v.grade = ?")
+		args = append(args, grade)
+	}
+	if school := q.Get("school"); school != "" {
+		where = append(where, "v.school = ?")
+		args = append(args, school)
+	}
+	if s := q.Get("after"); s != "" {
+		after, err := strconv.Atoi(s)
+		if err != nil {
+			sendErrorResponse(w, "Invalid after", http.StatusBadRequest)
+			return
+		}
+		where = append(where, "v.artwork_id > ?")
+		args = append(args, after)
+	}
+
+	limit := defaultListingLimit
+	if s := q.Get("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > maxListingLimit {
+			sendErrorResponse(w, fmt.Sprintf("limit must be 1-%d", maxListingLimit), http.StatusBadRequest)
+			return
+		}
+	}
+	args = append(args, limit)
+
+	rows, err := config.DB.Query(`
+		SELECT v.artwork_id, v.artist_id, v.grade, v.school, v.title, v.description, v.artist_name, v.url,
+		       v.image_id, v.thumb_url, v.blurhash, v.mediums
+		FROM all_artwork_data v
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY v.artwork_id LIMIT ?
+	`, args...)
+	if err != nil {
+		log.Printf("DB error fetching artworks view: %v", err)
+		sendErrorResponse(w, "Failed to fetch artworks", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	artworks := []models.ArtworkView{}
+	for rows.Next() {
+		var v models.ArtworkView
+		var grade, school, title, description, imageURL, thumbURL, blurhash, mediums sql.NullString
+		var imageID sql.NullInt64
+		if err := rows.Scan(
+			&v.ArtworkID, &v.ArtistID, &grade, &school, &title, &description, &v.ArtistName, &imageURL,
+			&imageID, &thumbURL, &blurhash, &mediums,
+		); err != nil {
+			log.Printf("DB error scanning artworks view: %v", err)
+			sendErrorResponse(w, "Failed to scan artworks", http.StatusInternalServerError)
+			return
+		}
+		v.Grade, v.School, v.Title, v.Description = grade.String, school.String, title.String, description.String
+		v.URL, v.ImageID = imageURL.String, int(imageID.Int64)
+		if thumbURL.Valid {
+			// Signed, as <img> tags can't send the Authorization header
+			if u, err := url.Parse(thumbURL.String); err == nil {
+				v.ThumbURL = signURL(u.Path, u.Query())
+			}
+		}
+		v.BlurHash, v.Mediums = blurhash.String, mediums.String
+		artworks = append(artworks, v)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("DB error reading artworks view: %v", err)
+		sendErrorResponse(w, "Failed to fetch artworks", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSONResponse(w, artworks, http.StatusOK)
+}
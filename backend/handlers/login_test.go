@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-art-api/utils"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Logging in with a hash made under weaker Argon2 parameters replaces it
+// with one under the current policy
+func TestLoginUpgradesWeakHash(t *testing.T) {
+	db := openTestDB(t, "")
+
+	const password = "password123"
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		t.Fatal(err)
+	}
+	weak := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, 8*1024, 1, 1,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte(password), salt, 1, 8*1024, 1, 32)))
+	userID, err := db.Insert(
+		"INSERT INTO users (fname, lname, email, pwd, email_verified_at) VALUES (?, ?, ?, ?, ?)",
+		"Old", "Hash", "old-hash@example.com", weak, time.Now().UTC(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utils.NeedsRehash(weak) {
+		t.Fatal("the weak hash already matches the current policy")
+	}
+
+	w := httptest.NewRecorder()
+	body := fmt.Sprintf(`{"email":"old-hash@example.com","password":%q}`, password)
+	LoginUser(w, httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("login returned %d: %s", w.Code, w.Body)
+	}
+
+	var stored string
+	if err := db.QueryRow("SELECT pwd FROM users WHERE id = ?", userID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored == weak || utils.NeedsRehash(stored) {
+		t.Errorf("hash wasn't upgraded: %s", stored)
+	}
+	if ok, err := utils.VerifyPassword(password, stored); err != nil || !ok {
+		t.Errorf("upgraded hash doesn't verify the password: %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-art-api/audit"
+	"go-art-api/config"
+	"go-art-api/models"
+	"go-art-api/utils"
+)
+
+// GetMe returns the signed-in user's own profile
+func GetMe(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	user, err := fetchUser(config.DB, auth.UserID)
+	if err != nil {
+		log.Printf("DB error fetching user %d: %v", auth.UserID, err)
+		sendErrorResponse(w, "Failed to fetch profile", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSONResponse(w, user, http.StatusOK)
+}
+
+// UpdateMe changes the signed-in user's name and email. Changing the email
+// takes the current password too, and the new one has to be verified again,
+// so a verification link is sent to it.
+func UpdateMe(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	var req models.ProfileUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid JSON data", http.StatusBadRequest)
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if len(req.FName) == 0 || len(req.FName) > 30 || len(req.LName) == 0 || len(req.LName) > 60 {
+		sendErrorResponse(w, "first name must be 1-30 characters and last name 1-60", http.StatusBadRequest)
+		return
+	}
+	if len(req.Email) > 60 || !strings.Contains(req.Email, "@") {
+		sendErrorResponse(w, "a valid email is required", http.StatusBadRequest)
+		return
+	}
+
+	current, err := fetchUser(config.DB, auth.UserID)
+	if err != nil {
+		log.Printf("DB error fetching user %d: %v", auth.UserID, err)
+		sendErrorResponse(w, "Failed to update profile", http.StatusInternalServerError)
+		return
+	}
+	emailChanged := !strings.EqualFold(req.Email, current.Email)
+	if emailChanged && !allowRequest(w, mailEmailLimiter, accountKey(req.Email)) {
+		return
+	}
+	// Password resets go to the email, so a session alone can't move it
+	if emailChanged {
+		if req.CurrentPassword == "" {
+			sendErrorResponse(w, "current_password is required to change your email", http.StatusBadRequest)
+			return
+		}
+		if !checkCurrentPassword(w, auth.UserID, req.CurrentPassword, "Failed to update profile") {
+			return
+		}
+	}
+
+	query := "UPDATE users SET fname = ?, lname = ?, email = ? WHERE id = ?"
+	if emailChanged {
+		query = "UPDATE users SET fname = ?, lname = ?, email = ?, email_verified_at = NULL WHERE id = ?"
+	}
+	user, err := changeUser(r, current, false, query, req.FName, req.LName, req.Email, auth.UserID)
+	if err != nil {
+		if config.DB.IsUniqueViolation(err) {
+			sendErrorResponse(w, "Email already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("DB error updating profile of user %d: %v", auth.UserID, err)
+		sendErrorResponse(w, "Failed to update profile", http.StatusInternalServerError)
+		return
+	}
+
+	if emailChanged {
+		if err := sendVerificationEmail(auth.UserID, req.Email, req.FName); err != nil {
+			log.Printf("Error sending verification email to user %d: %v", auth.UserID, err)
+		}
+	}
+
+	sendSuccessResponse(w, user, "Profile updated", http.StatusOK)
+}
+
+// checkCurrentPassword verifies the signed-in user's password before a
+// sensitive change, or writes the error. It shares login's lockout, so a
+// stolen session can't be used to guess the password.
+func checkCurrentPassword(w http.ResponseWriter, userID int, password, failure string) bool {
+	var email, hashedPassword string
+	err := config.DB.QueryRow("SELECT email, pwd FROM users WHERE id = ?", userID).Scan(&email, &hashedPassword)
+	if err != nil {
+		log.Printf("DB error fetching user %d: %v", userID, err)
+		sendErrorResponse(w, failure, http.StatusInternalServerError)
+		return false
+	}
+
+	account := accountKey(email)
+	if locked := loginLockout.Locked(account); locked > 0 {
+		sendTooManyRequests(w, "Too many failed password attempts, try again later", locked)
+		return false
+	}
+	isValid, err := utils.VerifyPassword(password, hashedPassword)
+	if err != nil {
+		sendPasswordHashError(w, err, failure)
+		return false
+	}
+	if !isValid {
+		loginLockout.Fail(account)
+		sendErrorResponse(w, "Current password is incorrect", http.StatusUnauthorized)
+		return false
+	}
+	loginLockout.Reset(account)
+	return true
+}
+
+// ChangePassword sets a new password after checking the current one. Other
+// sessions are signed out; the one making the change stays signed in.
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	var req models.PasswordChange
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.CurrentPassword == "" {
+		sendErrorResponse(w, "current_password and new_password are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.NewPassword) < 8 {
+		sendErrorResponse(w, "new password must be at least 8 characters", http.StatusBadRequest)
+		return
+	}
+
+	if !checkCurrentPassword(w, auth.UserID, req.CurrentPassword, "Failed to change password") {
+		return
+	}
+
+	newHash, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		sendPasswordHashError(w, err, "Failed to change password")
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET pwd = ? WHERE id = ?", newHash, auth.UserID); err != nil {
+		log.Printf("DB error changing password for user %d: %v", auth.UserID, err)
+		sendErrorResponse(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec(
+		"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND id <> ? AND revoked_at IS NULL",
+		time.Now().UTC(), auth.UserID, auth.SessionID,
+	); err != nil {
+		log.Printf("DB error revoking sessions for user %d: %v", auth.UserID, err)
+		sendErrorResponse(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+	if err := audit.Record(tx, audit.Entry{
+		ActorID: auth.UserID, Action: audit.Update, Entity: audit.User, EntityID: auth.UserID,
+		After: map[string]string{"password": "changed"},
+	}); err != nil {
+		sendErrorResponse(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		sendErrorResponse(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, nil, "Password changed, other sessions have been signed out", http.StatusOK)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-art-api/config"
+	"go-art-api/models"
+	"go-art-api/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// colorDistance is how far (ΔE) a palette colour can be from the one searched
+// for and still match: different shades of blue, but not purple
+const colorDistance = 25
+
+// artworkDetailQuery selects an artwork with its current image, for
+// scanArtworkDetail. Callers add the WHERE clause.
+const artworkDetailQuery = `
+	SELECT a.id, a.artist_id, a.grade, a.school, a.title, a.description, a.auto_enhance, a.created_at, i.id, i.palette, i.blurhash
+	FROM artworks a
+	JOIN artists ar ON ar.id = a.artist_id
+	LEFT JOIN images i ON i.artwork_id = a.id AND i.deleted_at IS NULL
+`
+
+// GetArtworkByID returns an artwork with its image ID, colour palette and
+// BlurHash
+func GetArtworkByID(w http.ResponseWriter, r *http.Request) {
+	artworkID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artwork ID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireArtworkRole(w, r, artworkID, RoleViewer); !ok {
+		return
+	}
+
+	a, err := scanArtworkDetail(config.DB.QueryRow(artworkDetailQuery+" WHERE a.id = ?", artworkID))
+	if err != nil {
+		log.Printf("DB error fetching artwork %d: %v", artworkID, err)
+		sendErrorResponse(w, "Failed to fetch artwork", http.StatusInternalServerError)
+		return
+	}
+	sendJSONResponse(w, a, http.StatusOK)
+}
+
+// SearchArtworks finds artworks of the artists the user is linked to, or just
+// ?artist_id=. ?q= matches words in the title or description. ?color=#rrggbb
+// keeps artworks with a palette colour within ?distance= (ΔE, default 25) of
+// it, nearest first; otherwise the newest come first.
+func SearchArtworks(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+	query := r.URL.Query()
+
+	var target [3]uint8
+	colorSearch := query.Get("color") != ""
+	if colorSearch {
+		var err error
+		if target, err = utils.ParseHexColor(query.Get("color")); err != nil {
+			sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	maxDistance := float64(colorDistance)
+	if s := query.Get("distance"); s != "" {
+		d, err := strconv.ParseFloat(s, 64)
+		if err != nil || d < 0 || d > 100 {
+			sendErrorResponse(w, "distance must be between 0 and 100", http.StatusBadRequest)
+			return
+		}
+		maxDistance = d
+	}
+
+	var artistIDs []int
+	if s := query.Get("artist_id"); s != "" {
+		artistID, err := strconv.Atoi(s)
+		if err != nil {
+			sendErrorResponse(w, "Invalid artist ID", http.StatusBadRequest)
+			return
+		}
+		if !requireArtistRole(w, r, artistID, RoleViewer) {
+			return
+		}
+		artistIDs = []int{artistID}
+	} else {
+		var err error
+		if artistIDs, err = userFamilyArtistIDs(auth.UserID); err != nil {
+			sendErrorResponse(w, "Failed to resolve family", http.StatusInternalServerError)
+			return
+		}
+	}
+	results := []models.ArtworkDetail{}
+	if len(artistIDs) == 0 {
+		sendJSONResponse(w, results, http.StatusOK)
+		return
+	}
+
+	placeholders, args := inClause(artistIDs)
+	where := []string{fmt.Sprintf("a.artist_id IN (%s)", placeholders), "a.deleted_at IS NULL", "ar.deleted_at IS NULL"}
+	// LOWER on both sides, as LIKE is case-sensitive on PostgreSQL
+	for _, word := range strings.Fields(strings.ToLower(query.Get("q"))) {
+		where = append(where, "(LOWER(a.title) LIKE ? OR LOWER(a.description) LIKE ?)")
+		args = append(args, "%"+word+"%", "%"+word+"%")
+	}
+	rows, err := config.DB.Query(
+		artworkDetailQuery+" WHERE "+strings.Join(where, " AND ")+" ORDER BY a.created_at DESC, a.id DESC", args...,
+	)
+	if err != nil {
+		log.Printf("DB error searching artworks: %v", err)
+		sendErrorResponse(w, "Failed to search artworks", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	nearest := map[int]float64{}
+	for rows.Next() {
+		a, err := scanArtworkDetail(rows)
+		if err != nil {
+			log.Printf("DB error scanning artworks: %v", err)
+			sendErrorResponse(w, "Failed to scan artworks", http.StatusInternalServerError)
+			return
+		}
+		if colorSearch {
+			best := math.Inf(1)
+			for _, c := range a.Palette {
+				if rgb, err := utils.ParseHexColor(c.Hex); err == nil {
+					best = math.Min(best, utils.ColorDistance(rgb, target))
+				}
+			}
+			if best > maxDistance {
+				continue
+			}
+			nearest[a.ID] = best
+		}
+		results = append(results, a)
+	}
+	if colorSearch {
+		sort.SliceStable(results, func(i, j int) bool { return nearest[results[i].ID] < nearest[results[j].ID] })
+	}
+
+	sendJSONResponse(w, results, http.StatusOK)
+}
+
+// scanArtworkDetail reads a row selected by artworkDetailQuery
+func scanArtworkDetail(row interface{ Scan(...interface{}) error }) (models.ArtworkDetail, error) {
+	var a models.ArtworkDetail
+	var grade, school, title, description, palette, blurhash sql.NullString
+	var imageID sql.NullInt64
+	err := row.Scan(&a.ID, &a.ArtistID, &grade, &school, &title, &description, &a.AutoEnhance, &a.CreatedAt, &imageID, &palette, &blurhash)
+	a.Grade, a.School, a.Title, a.Description = grade.String, school.String, title.String, description.String
+	a.ImageID, a.BlurHash = int(imageID.Int64), blurhash.String
+	if palette.Valid {
+		if err := json.Unmarshal([]byte(palette.String), &a.Palette); err != nil {
+			log.Printf("Ignoring unreadable palette of artwork %d: %v", a.ID, err)
+		}
+	}
+	return a, err
+}
+
+// imagePalette extracts the dominant colours of a thumbnail, returning them
+// and their JSON for the palette column (nil if they couldn't be worked out)
+func imagePalette(thumb []byte) ([]models.PaletteColor, interface{}) {
+	palette, err := utils.ImagePalette(bytes.NewReader(thumb))
+	if err != nil {
+		log.Printf("Could not extract image palette: %v", err)
+		return nil, nil
+	}
+	stored, err := json.Marshal(palette)
+	if err != nil {
+		return nil, nil
+	}
+	return palette, string(stored)
+}
+
+// imageBlurHash works out a thumbnail's BlurHash placeholder for the blurhash
+// column (nil if it couldn't be)
+func imageBlurHash(thumb []byte) interface{} {
+	hash, err := utils.ImageBlurHash(bytes.NewReader(thumb))
+	if err != nil {
+		log.Printf("Could not work out image BlurHash: %v", err)
+		return nil
+	}
+	return hash
+}
@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image/jpeg"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-art-api/config"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/gorilla/mux"
+)
+
+// Portfolio layout (mm)
+const (
+	portfolioMargin     = 15.0
+	portfolioTextHeight = 55.0 // space reserved under each artwork for its details
+	portfolioCaption    = 8.0  // caption height under each contact sheet thumbnail
+)
+
+var portfolioPageSizes = map[string]string{"a4": "A4", "a5": "A5", "letter": "Letter", "legal": "Legal"}
+
+// A portfolio holds every full-size image it prints in memory until the PDF
+// is written, so both its length and how many render at once are bounded
+const maxPortfolioArtworks = 200
+
+var portfolioSlots = make(chan struct{}, 2)
+
+// portfolioItem is one artwork and its images as pulled from all_artwork_data
+type portfolioItem struct {
+	ArtworkID   int
+	Title       string
+	Grade       string
+	School      string
+	Description string
+	ArtistName  string
+	Mediums     string
+	CreatedAt   time.Time
+	Image       []byte
+	Thumb       []byte
+}
+
+// portfolioOptions controls the PDF layout
+type portfolioOptions struct {
+	Title        string
+	PageSize     string
+	Orientation  string
+	ContactSheet bool
+	Columns      int
+}
+
+// GeneratePortfolio renders a printable PDF portfolio: a cover page, one page
+// per artwork and a contact sheet of thumbnails. Artworks can be filtered by
+// artist (path or ?artist_id=), grade, school and year. Layout options:
+// page_size=a4|a5|letter|legal, orientation=portrait|landscape,
+// contact_sheet=false, columns=N, title=...
+func GeneratePortfolio(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	opts, err := parsePortfolioOptions(q.Get("page_size"), q.Get("orientation"), q.Get("contact_sheet"), q.Get("columns"))
+	if err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.Title = q.Get("title")
+
+	// Build the filter
+	var where []string
+	var args []interface{}
+
+	artistIDStr := mux.Vars(r)["id"]
+	if artistIDStr == "" {
+		artistIDStr = q.Get("artist_id")
+	}
+	if artistIDStr != "" {
+		artistID, err := strconv.Atoi(artistIDStr)
+		if err != nil {
+			sendErrorResponse(w, "Invalid artist ID", http.StatusBadRequest)
+			return
+		}
+		if !requireArtistRole(w, r, artistID, RoleViewer) {
+			return
+		}
+		where = append(where, "a.artist_id = ?")
+		args = append(args, artistID)
+	} else {
+		// Without an artist, print from everything the user can see
+		auth, _ := currentAuth(r)
+		where = append(where, "a.artist_id IN (SELECT artist_id FROM user_artists WHERE user_id = ?)")
+		args = append(args, auth.UserID)
+	}
+	if grade := q.Get("grade"); grade != "" {
+		where = append(where, "v.grade = ?")
+		args = append(args, grade)
+	}
+	if school := q.Get("school"); school != "" {
+		where = append(where, "v.school = ?")
+		args = append(args, school)
+	}
+	if yearStr := q.Get("year"); yearStr != "" {
+		year, err := strconv.Atoi(yearStr)
+		if err != nil {
+			sendErrorResponse(w, "Invalid year", http.StatusBadRequest)
+			return
+		}
+		// A date range instead of YEAR() keeps this portable across dialects
+		where = append(where, "a.created_at >= ? AND a.created_at < ?")
+		args = append(args, time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+
+	query := `
+		SELECT v.artwork_id, v.title, v.grade, v.school, v.description, v.artist_name, v.mediums,
+		       a.created_at, ` + servedColumn("image") + `, ` + servedColumn("thumb") + `
+		FROM all_artwork_data v
+		JOIN artworks a ON a.id = v.artwork_id
+		LEFT JOIN images i ON i.artwork_id = v.artwork_id AND i.deleted_at IS NULL`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY a.created_at, a.id LIMIT ?"
+	args = append(args, maxPortfolioArtworks+1)
+
+	select {
+	case portfolioSlots <- struct{}{}:
+		defer func() { <-portfolioSlots }()
+	default:
+		w.Header().Set("Retry-After", "5")
+		sendErrorResponse(w, "Other portfolios are being printed, try again shortly", http.StatusServiceUnavailable)
+		return
+	}
+
+	items, err := fetchPortfolioItems(query, args...)
+	if err != nil {
+		log.Printf("DB error fetching portfolio artworks: %v", err)
+		sendErrorResponse(w, "Failed to fetch artworks", http.StatusInternalServerError)
+		return
+	}
+	if len(items) == 0 {
+		sendErrorResponse(w, "No artworks match the requested filters", http.StatusNotFound)
+		return
+	}
+	if len(items) > maxPortfolioArtworks {
+		sendErrorResponse(w, fmt.Sprintf("More than %d artworks match, narrow it down by artist, grade, school or year", maxPortfolioArtworks), http.StatusBadRequest)
+		return
+	}
+
+	if opts.Title == "" {
+		opts.Title = "Art Portfolio"
+		if artistIDStr != "" {
+			opts.Title = items[0].ArtistName + "'s Portfolio"
+		}
+	}
+
+	pdf := renderPortfolio(items, opts)
+	if err := pdf.Error(); err != nil {
+		log.Printf("Error rendering portfolio PDF: %v", err)
+		sendErrorResponse(w, "Failed to render portfolio", http.StatusInternalServerError)
+		return
+	}
+
+	// Rendering dozens of full-size images can take a while
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.pdf"`, slugify(opts.Title, "portfolio")))
+	if err := pdf.Output(w); err != nil {
+		log.Printf("Error writing portfolio PDF: %v", err)
+	}
+}
+
+// parsePortfolioOptions validates the layout query params
+func parsePortfolioOptions(pageSize, orientation, contactSheet, columns string) (portfolioOptions, error) {
+	opts := portfolioOptions{PageSize: "A4", Orientation: "P", ContactSheet: contactSheet != "false", Columns: 4}
+
+	if pageSize != "" {
+		size, ok := portfolioPageSizes[strings.ToLower(pageSize)]
+		if !ok {
+			return opts, fmt.Errorf("page_size must be one of a4, a5, letter, legal")
+		}
+		opts.PageSize = size
+	}
+
+	switch strings.ToLower(orientation) {
+	case "", "portrait":
+	case "landscape":
+		opts.Orientation = "L"
+	default:
+		return opts, fmt.Errorf("orientation must be 'portrait' or 'landscape'")
+	}
+
+	if columns != "" {
+		n, err := strconv.Atoi(columns)
+		if err != nil || n < 1 || n > 8 {
+			return opts, fmt.Errorf("columns must be between 1 and 8")
+		}
+		opts.Columns = n
+	}
+
+	return opts, nil
+}
+
+// fetchPortfolioItems runs the portfolio query
+func fetchPortfolioItems(query string, args ...interface{}) ([]portfolioItem, error) {
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []portfolioItem
+	for rows.Next() {
+		var it portfolioItem
+		var title, grade, school, description, mediums sql.NullString
+		if err := rows.Scan(&it.ArtworkID, &title, &grade, &school, &description, &it.ArtistName, &mediums,
+			&it.CreatedAt, &it.Image, &it.Thumb); err != nil {
+			return nil, err
+		}
+		it.Title, it.Grade, it.School, it.Description, it.Mediums =
+			title.String, grade.String, school.String, description.String, mediums.String
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// renderPortfolio lays out the whole document
+func renderPortfolio(items []portfolioItem, opts portfolioOptions) *fpdf.Fpdf {
+	pdf := fpdf.New(opts.Orientation, "mm", opts.PageSize, "")
+	pdf.SetTitle(opts.Title, true)
+	pdf.SetCreator("Go Art API", true)
+	pdf.SetMargins(portfolioMargin, portfolioMargin, portfolioMargin)
+	pdf.SetAutoPageBreak(false, portfolioMargin)
+
+	// Core PDF fonts are cp1252, so translate titles like "Café" from UTF-8
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	renderPortfolioCover(pdf, tr, items, opts.Title)
+	for _, it := range items {
+		renderPortfolioArtwork(pdf, tr, it)
+	}
+	if opts.ContactSheet {
+		renderPortfolioContactSheet(pdf, tr, items, opts.Columns)
+	}
+	return pdf
+}
+
+// renderPortfolioCover draws the title page
+func renderPortfolioCover(pdf *fpdf.Fpdf, tr func(string) string, items []portfolioItem, title string) {
+	pdf.AddPage()
+	pageW, pageH := pdf.GetPageSize()
+	usableW := pageW - 2*portfolioMargin
+
+	pdf.SetY(pageH / 3)
+	pdf.SetFont("Helvetica", "B", 32)
+	pdf.MultiCell(usableW, 14, tr(title), "", "C", false)
+
+	first, last := items[0].CreatedAt, items[len(items)-1].CreatedAt
+	dates := first.Format("January 2006")
+	if first.Format("200601") != last.Format("200601") {
+		dates += " - " + last.Format("January 2006")
+	}
+
+	pdf.Ln(6)
+	pdf.SetFont("Helvetica", "", 16)
+	pdf.CellFormat(usableW, 10, tr(dates), "", 1, "C", false, 0, "")
+	pdf.CellFormat(usableW, 10, fmt.Sprintf("%d artworks", len(items)), "", 1, "C", false, 0, "")
+
+	pdf.SetY(pageH - portfolioMargin - 10)
+	pdf.SetFont("Helvetica", "I", 10)
+	pdf.SetTextColor(120, 120, 120)
+	pdf.CellFormat(usableW, 10, "Printed "+time.Now().Format("January 2, 2006"), "", 1, "C", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// renderPortfolioArtwork draws one artwork per page with its details underneath
+func renderPortfolioArtwork(pdf *fpdf.Fpdf, tr func(string) string, it portfolioItem) {
+	pdf.AddPage()
+	pageW, pageH := pdf.GetPageSize()
+	usableW := pageW - 2*portfolioMargin
+	imageAreaH := pageH - 2*portfolioMargin - portfolioTextHeight
+
+	textY := portfolioMargin + imageAreaH
+	if len(it.Image) > 0 {
+		name := fmt.Sprintf("artwork-%d", it.ArtworkID)
+		if drawn := placeImage(pdf, name, it.Image, portfolioMargin, portfolioMargin, usableW, imageAreaH); drawn > 0 {
+			textY = portfolioMargin + drawn
+		}
+	}
+
+	pdf.SetXY(portfolioMargin, textY+6)
+	pdf.SetFont("Helvetica", "B", 18)
+	title := it.Title
+	if title == "" {
+		title = "Untitled"
+	}
+	pdf.MultiCell(usableW, 8, tr(title), "", "L", false)
+
+	var details []string
+	for _, d := range []string{gradeLabel(it.Grade), it.School, it.Mediums, it.CreatedAt.Format("January 2, 2006")} {
+		if d != "" {
+			details = append(details, d)
+		}
+	}
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.SetTextColor(90, 90, 90)
+	pdf.MultiCell(usableW, 6, tr(strings.Join(details, "  |  ")), "", "L", false)
+	pdf.SetTextColor(0, 0, 0)
+
+	if it.Description != "" {
+		pdf.Ln(2)
+		pdf.SetFont("Helvetica", "", 11)
+		pdf.MultiCell(usableW, 5.5, tr(it.Description), "", "L", false)
+	}
+}
+
+// renderPortfolioContactSheet draws a grid of thumbnails with short captions
+func renderPortfolioContactSheet(pdf *fpdf.Fpdf, tr func(string) string, items []portfolioItem, columns int) {
+	pageW, pageH := pdf.GetPageSize()
+	usableW := pageW - 2*portfolioMargin
+	cellW := usableW / float64(columns)
+	cellH := cellW + portfolioCaption
+	top := portfolioMargin + 14
+	rowsPerPage := int((pageH - top - portfolioMargin) / cellH)
+	if rowsPerPage < 1 {
+		rowsPerPage = 1
+	}
+	perPage := rowsPerPage * columns
+
+	for i, it := range items {
+		if i%perPage == 0 {
+			pdf.AddPage()
+			pdf.SetFont("Helvetica", "B", 16)
+			pdf.CellFormat(usableW, 10, "Contact Sheet", "", 1, "L", false, 0, "")
+		}
+
+		slot := i % perPage
+		x := portfolioMargin + float64(slot%columns)*cellW
+		y := top + float64(slot/columns)*cellH
+		pad := 2.0
+
+		thumb := it.Thumb
+		if len(thumb) == 0 {
+			thumb = it.Image
+		}
+		if len(thumb) > 0 {
+			placeImage(pdf, fmt.Sprintf("thumb-%d", it.ArtworkID), thumb, x+pad, y+pad, cellW-2*pad, cellW-2*pad)
+		}
+
+		title := it.Title
+		if title == "" {
+			title = "Untitled"
+		}
+		pdf.SetXY(x, y+cellW)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(cellW, portfolioCaption/2, tr(truncateToWidth(pdf, title, cellW-2*pad)), "", 2, "C", false, 0, "")
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(cellW, portfolioCaption/2, tr(gradeLabel(it.Grade)), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	}
+}
+
+// placeImage fits a JPEG into a box (centered horizontally, top aligned) and
+// returns the drawn height, or 0 if the image couldn't be read. fpdf keeps its
+// first error for the whole document, so a bad image is skipped before it
+// gets there rather than failing every page.
+func placeImage(pdf *fpdf.Fpdf, name string, data []byte, x, y, boxW, boxH float64) float64 {
+	if _, err := jpeg.DecodeConfig(bytes.NewReader(data)); err != nil {
+		log.Printf("Leaving unreadable image %s out of the portfolio: %v", name, err)
+		return 0
+	}
+	info := pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "JPG"}, bytes.NewReader(data))
+	if info == nil || info.Width() == 0 || info.Height() == 0 {
+		return 0
+	}
+
+	w, h := boxW, boxW*info.Height()/info.Width()
+	if h > boxH {
+		w, h = boxH*info.Width()/info.Height(), boxH
+	}
+	pdf.ImageOptions(name, x+(boxW-w)/2, y, w, h, false, fpdf.ImageOptions{ImageType: "JPG"}, 0, "")
+	return h
+}
+
+// truncateToWidth shortens text with an ellipsis to fit within w
+func truncateToWidth(pdf *fpdf.Fpdf, text string, w float64) string {
+	if pdf.GetStringWidth(text) <= w {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > w {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// gradeLabel prefixes bare grade numbers ("2" -> "Grade 2")
+func gradeLabel(grade string) string {
+	if _, err := strconv.Atoi(grade); err == nil {
+		return "Grade " + grade
+	}
+	return grade
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParsePortfolioOptions(t *testing.T) {
+	opts, err := parsePortfolioOptions("Letter", "landscape", "false", "6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.PageSize != "Letter" || opts.Orientation != "L" || opts.ContactSheet || opts.Columns != 6 {
+		t.Errorf("got %+v", opts)
+	}
+
+	for _, bad := range [][4]string{
+		{"a3", "", "", ""},
+		{"", "sideways", "", ""},
+		{"", "", "", "0"},
+		{"", "", "", "9"},
+		{"", "", "", "two"},
+	} {
+		if _, err := parsePortfolioOptions(bad[0], bad[1], bad[2], bad[3]); err == nil {
+			t.Errorf("parsePortfolioOptions(%q) accepted", bad)
+		}
+	}
+}
+
+// One unreadable image leaves a gap in the portfolio rather than failing it
+func TestRenderPortfolioSkipsUnreadableImages(t *testing.T) {
+	image, err := os.ReadFile(benchImage)
+	if err != nil {
+		t.Skipf("test image: %v", err)
+	}
+	created := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	items := []portfolioItem{
+		{ArtworkID: 1, Title: "Birch", Grade: "2", CreatedAt: created, Image: image, Thumb: image},
+		{ArtworkID: 2, Title: "Café", CreatedAt: created, Image: []byte("not a jpeg"), Thumb: []byte{0xff, 0xd8, 0xff}},
+		{ArtworkID: 3, Title: "No image", CreatedAt: created},
+	}
+	opts, _ := parsePortfolioOptions("", "", "", "")
+	opts.Title = "Test Portfolio"
+
+	pdf := renderPortfolio(items, opts)
+	if err := pdf.Error(); err != nil {
+		t.Fatalf("rendering: %v", err)
+	}
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out.Bytes(), []byte("%PDF")) {
+		t.Errorf("output isn't a PDF: %q", out.Bytes()[:16])
+	}
+	// Cover, three artworks and a contact sheet
+	if n := pdf.PageCount(); n != 5 {
+		t.Errorf("%d pages, want 5", n)
+	}
+}
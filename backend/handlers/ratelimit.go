@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-art-api/ratelimit"
+	"go-art-api/utils"
+)
+
+// Throttles for the unauthenticated endpoints that hash passwords or send
+// mail. Per-IP limits stop floods; per-account limits and the lockout stop
+// credential stuffing spread across many addresses.
+var (
+	loginIPLimiter    = ratelimit.NewLimiter(10, time.Minute)
+	registerIPLimiter = ratelimit.NewLimiter(5, 10*time.Minute)
+	mailIPLimiter     = ratelimit.NewLimiter(5, 10*time.Minute)
+	mailEmailLimiter  = ratelimit.NewLimiter(3, time.Hour)
+
+	// 5 wrong passwords lock the account for 30s, doubling up to 15 minutes
+	loginLockout = ratelimit.NewLockout(5, 30*time.Second, 15*time.Minute, time.Hour)
+)
+
+// allowRequest takes a token from limiter for key, or writes a 429
+func allowRequest(w http.ResponseWriter, limiter *ratelimit.Limiter, key string) bool {
+	ok, retryAfter := limiter.Allow(key)
+	if !ok {
+		sendTooManyRequests(w, "Too many requests, please slow down", retryAfter)
+	}
+	return ok
+}
+
+// sendTooManyRequests writes a 429 with Retry-After in whole seconds
+func sendTooManyRequests(w http.ResponseWriter, message string, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	sendErrorResponse(w, message, http.StatusTooManyRequests)
+}
+
+// sendPasswordHashError reports a failed HashPassword/VerifyPassword, telling
+// the client to retry when the server was just busy hashing
+func sendPasswordHashError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, utils.ErrHashBusy) {
+		w.Header().Set("Retry-After", "1")
+		sendErrorResponse(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	sendErrorResponse(w, message, http.StatusInternalServerError)
+}
+
+// accountKey normalizes an email for per-account limits
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"go-art-api/config"
+	"go-art-api/imagecache"
+	"go-art-api/models"
+	"go-art-api/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// SignImageURL returns a signed URL for a resized copy of an image (members).
+// Send {"image_id", "w", "h", "fit": "cover|contain", "fmt": "jpeg|png"},
+// sizes from utils.ResizeSizes; only URLs signed here are served, so nobody
+// can make the server render every size under the sun. Like thumb_url, the
+// URL expires and is for the image's current version.
+func SignImageURL(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ImageID int    `json:"image_id"`
+		Width   int    `json:"w"`
+		Height  int    `json:"h"`
+		Fit     string `json:"fit"`
+		Format  string `json:"fmt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	opts := utils.ResizeOptions{Width: req.Width, Height: req.Height, Fit: req.Fit, Format: req.Format}
+	if err := utils.ValidateResize(&opts); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !requireImageRole(w, r, req.ImageID, RoleViewer) {
+		return
+	}
+
+	var version int
+	if err := config.DB.QueryRow("SELECT version FROM images WHERE id = ?", req.ImageID).Scan(&version); err != nil {
+		log.Printf("DB error fetching version of image %d: %v", req.ImageID, err)
+		sendErrorResponse(w, "Failed to sign image URL", http.StatusInternalServerError)
+		return
+	}
+
+	q := url.Values{}
+	if opts.Width > 0 {
+		q.Set("w", strconv.Itoa(opts.Width))
+	}
+	if opts.Height > 0 {
+		q.Set("h", strconv.Itoa(opts.Height))
+	}
+	q.Set("fit", opts.Fit)
+	q.Set("fmt", opts.Format)
+	q.Set("v", strconv.Itoa(version))
+	sendJSONResponse(w, map[string]string{"url": signURL(fmt.Sprintf("/api/img/%d", req.ImageID), q)}, http.StatusOK)
+}
+
+// GetResizedImage serves a resized copy of an image from an unexpired URL
+// made by SignImageURL for its current version, rendering it from the kept original (with its edits, and
+// auto-enhanced if that's on) the first time and from the image cache after
+func GetResizedImage(w http.ResponseWriter, r *http.Request) {
+	imageID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid image ID", http.StatusBadRequest)
+		return
+	}
+	if !validSignature(r) {
+		sendErrorResponse(w, "Invalid or expired signature", http.StatusForbidden)
+		return
+	}
+	q := r.URL.Query()
+	version, err := strconv.Atoi(q.Get("v"))
+	if err != nil {
+		sendErrorResponse(w, "Invalid v", http.StatusBadRequest)
+		return
+	}
+	opts := utils.ResizeOptions{Fit: q.Get("fit"), Format: q.Get("fmt")}
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{{"w", &opts.Width}, {"h", &opts.Height}} {
+		if s := q.Get(p.name); s != "" {
+			if *p.value, err = strconv.Atoi(s); err != nil {
+				sendErrorResponse(w, fmt.Sprintf("Invalid %s", p.name), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if err := utils.ValidateResize(&opts); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	contentType := "image/" + opts.Format
+	key := imagecache.Key{ImageID: imageID, Rendition: fmt.Sprintf("v%d-%dx%d-%s.%s", version, opts.Width, opts.Height, opts.Fit, opts.Format)}
+	if data, ok := imageCache.Get(key); ok {
+		writeImage(w, r, data, contentType, "HIT")
+		return
+	}
+
+	// Images from before originals were kept only have the full-size rendition
+	epoch := imageCache.Epoch()
+	var original []byte
+	var edits sql.NullString
+	var current int
+	err = config.DB.QueryRow(`
+		SELECT COALESCE(i.original, i.image), i.edits, a.auto_enhance, i.version FROM images i
+		JOIN artworks a ON a.id = i.artwork_id
+		JOIN artists ar ON ar.id = a.artist_id
+		WHERE i.id = ? AND i.deleted_at IS NULL AND a.deleted_at IS NULL AND ar.deleted_at IS NULL
+	`, imageID).Scan(&original, &edits, &opts.Enhance, &current)
+	if err == nil && current != version {
+		sendErrorResponse(w, "The image has changed since this URL was signed", http.StatusGone)
+		return
+	} else if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Image not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("DB error fetching original of image %d: %v", imageID, err)
+		sendErrorResponse(w, "Failed to retrieve image data", http.StatusInternalServerError)
+		return
+	}
+
+	var applied models.ImageEdits
+	if e := parseEdits(edits); e != nil {
+		applied = *e
+	}
+	data, err := utils.ResizeImage(bytes.NewReader(original), applied, opts)
+	if err != nil {
+		log.Printf("Error resizing image %d: %v", imageID, err)
+		sendErrorResponse(w, "Failed to resize image", http.StatusInternalServerError)
+		return
+	}
+	imageCache.Add(key, data, epoch)
+	writeImage(w, r, data, contentType, "MISS")
+}
@@ -0,0 +1,528 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-art-api/audit"
+	"go-art-api/config"
+	"go-art-api/models"
+	"go-art-api/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// UpdateArtwork changes an artwork's details (editors and owners). How it
+// looked before is kept as a revision.
+func UpdateArtwork(w http.ResponseWriter, r *http.Request) {
+	artworkID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artwork ID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireArtworkRole(w, r, artworkID, RoleEditor); !ok {
+		return
+	}
+
+	var req models.ArtworkUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid JSON data", http.StatusBadRequest)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" || len(req.Title) > 100 || len(req.Grade) > 20 || len(req.School) > 30 || len(req.Description) > 500 {
+		sendErrorResponse(w, "title must be 1-100 characters, grade up to 20, school up to 30 and description up to 500", http.StatusBadRequest)
+		return
+	}
+
+	before, err := fetchArtwork(artworkID)
+	if err != nil {
+		sendErrorResponse(w, "Failed to fetch artwork", http.StatusInternalServerError)
+		return
+	}
+	after := before
+	after.Grade, after.School, after.Title, after.Description = req.Grade, req.School, req.Title, req.Description
+	if after == before {
+		sendSuccessResponse(w, after, "Nothing to change", http.StatusOK)
+		return
+	}
+
+	auth, _ := currentAuth(r)
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to update artwork", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := saveRevision(tx, artworkID, auth.UserID, false); err != nil {
+		log.Printf("DB error saving revision of artwork %d: %v", artworkID, err)
+		sendErrorResponse(w, "Failed to update artwork", http.StatusInternalServerError)
+		return
+	}
+	if err := updateArtworkDetails(tx, after); err != nil {
+		log.Printf("DB error updating artwork %d: %v", artworkID, err)
+		sendErrorResponse(w, "Failed to update artwork", http.StatusInternalServerError)
+		return
+	}
+	if err := audit.Record(tx, audit.Entry{
+		ActorID: auth.UserID, Action: audit.Update, Entity: audit.Artwork, EntityID: artworkID, ArtistID: before.ArtistID,
+		Before: before, After: after,
+	}); err != nil {
+		sendErrorResponse(w, "Failed to update artwork", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		sendErrorResponse(w, "Failed to update artwork", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, after, "Artwork updated", http.StatusOK)
+}
+
+// GetArtworkRevisions lists an artwork's earlier versions, newest first
+func GetArtworkRevisions(w http.ResponseWriter, r *http.Request) {
+	artworkID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artwork ID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireArtworkRole(w, r, artworkID, RoleViewer); !ok {
+		return
+	}
+
+	rows, err := config.DB.Query(revisionQuery+" WHERE r.artwork_id = ? ORDER BY r.id DESC", artworkID)
+	if err != nil {
+		log.Printf("DB error fetching revisions of artwork %d: %v", artworkID, err)
+		sendErrorResponse(w, "Failed to fetch revisions", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	revisions := []models.ArtworkRevision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			log.Printf("DB error scanning revisions: %v", err)
+			sendErrorResponse(w, "Failed to scan revisions", http.StatusInternalServerError)
+			return
+		}
+		revisions = append(revisions, rev)
+	}
+
+	sendJSONResponse(w, revisions, http.StatusOK)
+}
+
+// GetArtworkRevisionDiff compares a revision with a later one (?to=<id>) or,
+// by default, with the artwork as it is now
+func GetArtworkRevisionDiff(w http.ResponseWriter, r *http.Request) {
+	artworkID, rev, ok := revisionTarget(w, r, RoleViewer)
+	if !ok {
+		return
+	}
+
+	from, err := revisionState(artworkID, &rev)
+	if err != nil {
+		log.Printf("DB error loading revision %d: %v", rev.ID, err)
+		sendErrorResponse(w, "Failed to compare revisions", http.StatusInternalServerError)
+		return
+	}
+
+	var to artworkState
+	if v := r.URL.Query().Get("to"); v != "" {
+		toID, err := strconv.Atoi(v)
+		if err != nil {
+			sendErrorResponse(w, "Invalid revision ID in to", http.StatusBadRequest)
+			return
+		}
+		toRev, err := fetchRevision(artworkID, toID)
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, "Revision not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			sendErrorResponse(w, "Failed to fetch revision", http.StatusInternalServerError)
+			return
+		}
+		to, err = revisionState(artworkID, &toRev)
+	} else {
+		to, err = revisionState(artworkID, nil)
+	}
+	if err != nil {
+		log.Printf("DB error loading artwork %d for diff: %v", artworkID, err)
+		sendErrorResponse(w, "Failed to compare revisions", http.StatusInternalServerError)
+		return
+	}
+
+	changes, err := audit.Diff(from, to)
+	if err != nil {
+		sendErrorResponse(w, "Failed to compare revisions", http.StatusInternalServerError)
+		return
+	}
+	sendJSONResponse(w, changes, http.StatusOK)
+}
+
+// GetRevisionImage serves the image an artwork had at a revision
+func GetRevisionImage(w http.ResponseWriter, r *http.Request) {
+	serveRevisionImage(w, r, "image")
+}
+
+// GetRevisionThumbnail serves the thumbnail an artwork had at a revision
+func GetRevisionThumbnail(w http.ResponseWriter, r *http.Request) {
+	serveRevisionImage(w, r, "thumb")
+}
+
+func serveRevisionImage(w http.ResponseWriter, r *http.Request, column string) {
+	artworkID, rev, ok := revisionTarget(w, r, RoleViewer)
+	if !ok {
+		return
+	}
+
+	sourceID, err := revisionImageSource(artworkID, rev.ID)
+	if err != nil {
+		sendErrorResponse(w, "Failed to fetch image", http.StatusInternalServerError)
+		return
+	}
+	var data []byte
+	if sourceID != 0 {
+		err = config.DB.QueryRow("SELECT "+column+" FROM artwork_revisions WHERE id = ?", sourceID).Scan(&data)
+	} else {
+		err = config.DB.QueryRow("SELECT "+column+" FROM images WHERE artwork_id = ? AND deleted_at IS NULL", artworkID).Scan(&data)
+	}
+	if err == sql.ErrNoRows || (err == nil && len(data) == 0) {
+		sendErrorResponse(w, "Image not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to fetch image", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=3600") // members only, unlike current images
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Error writing revision image to response: %v", err)
+	}
+}
+
+// RevertArtwork puts an artwork's details and image back the way they were at
+// a revision (editors and owners). The revert is itself a change, so how the
+// artwork looked just before it is kept as a new revision.
+func RevertArtwork(w http.ResponseWriter, r *http.Request) {
+	artworkID, rev, ok := revisionTarget(w, r, RoleEditor)
+	if !ok {
+		return
+	}
+
+	before, err := fetchArtwork(artworkID)
+	if err != nil {
+		sendErrorResponse(w, "Failed to fetch artwork", http.StatusInternalServerError)
+		return
+	}
+	after := before
+	after.Grade, after.School, after.Title, after.Description = rev.Grade, rev.School, rev.Title, rev.Description
+
+	// The image only changes if a later change replaced it
+	sourceID, err := revisionImageSource(artworkID, rev.ID)
+	if err != nil {
+		sendErrorResponse(w, "Failed to revert artwork", http.StatusInternalServerError)
+		return
+	}
+	var image models.Image
+	var original, currentOriginal []byte
+	var edits, currentEdits sql.NullString
+	var current imageAudit
+	var hash, palette, blurhash interface{}
+	if sourceID != 0 {
+		err = config.DB.QueryRow(
+			"SELECT original_mime, thumb, image, thumb_bytes, image_bytes, edits, original FROM artwork_revisions WHERE id = ?", sourceID,
+		).Scan(&image.OriginalMime, &image.Thumb, &image.Image, &image.ThumbBytes, &image.ImageBytes, &edits, &original)
+		if err != nil {
+			sendErrorResponse(w, "Failed to fetch revision image", http.StatusInternalServerError)
+			return
+		}
+		err = config.DB.QueryRow(
+			"SELECT id, original_mime, thumb_bytes, image_bytes, edits, original FROM images WHERE artwork_id = ?", artworkID,
+		).Scan(&image.ID, &current.Mime, &current.ThumbBytes, &current.ImageBytes, &currentEdits, &currentOriginal)
+		if err != nil && err != sql.ErrNoRows {
+			sendErrorResponse(w, "Failed to fetch current image", http.StatusInternalServerError)
+			return
+		}
+		// Revisions saved before they kept originals leave the current one be
+		if original == nil {
+			original = currentOriginal
+		}
+		current.ArtworkID = artworkID
+		current.Edits = parseEdits(currentEdits)
+
+		// Revisions don't keep hashes, palettes or BlurHashes, so they're worked out again
+		if h, err := utils.HashImage(bytes.NewReader(image.Image)); err == nil {
+			hash = int64(h)
+		} else {
+			log.Printf("Could not hash revision %d image: %v", sourceID, err)
+		}
+		_, palette = imagePalette(image.Thumb)
+		blurhash = imageBlurHash(image.Thumb)
+	}
+
+	auth, _ := currentAuth(r)
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to revert artwork", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// The current image moves into history rather than going away
+	if sourceID != 0 {
+		if quotaErr := checkStorageQuota(tx, before.ArtistID, int64(image.ThumbBytes+image.ImageBytes+len(original)), 0); quotaErr != nil {
+			sendQuotaError(w, quotaErr)
+			return
+		}
+	}
+
+	err = saveRevision(tx, artworkID, auth.UserID, sourceID != 0)
+	if err == nil {
+		err = updateArtworkDetails(tx, after)
+	}
+	if err == nil && sourceID != 0 {
+		if image.ID != 0 {
+			_, err = tx.Exec(`
+				UPDATE images SET original_mime = ?, thumb = ?, image = ?, thumb_bytes = ?, image_bytes = ?, original = ?, original_bytes = ?,
+					edits = ?, phash = ?, palette = ?, blurhash = ?, url = NULL, deleted_at = NULL, version = version + 1
+				WHERE id = ?
+			`, image.OriginalMime, image.Thumb, image.Image, image.ThumbBytes, image.ImageBytes, original, len(original),
+				edits, hash, palette, blurhash, image.ID)
+		} else {
+			var id int64
+			id, err = tx.Insert(
+				"INSERT INTO images (artwork_id, original_mime, thumb, image, thumb_bytes, image_bytes, original, original_bytes, edits, phash, palette, blurhash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+				artworkID, image.OriginalMime, image.Thumb, image.Image, image.ThumbBytes, image.ImageBytes, original, len(original), edits, hash, palette, blurhash,
+			)
+			image.ID = int(id)
+		}
+	}
+	if err != nil {
+		log.Printf("DB error reverting artwork %d to revision %d: %v", artworkID, rev.ID, err)
+		sendErrorResponse(w, "Failed to revert artwork", http.StatusInternalServerError)
+		return
+	}
+
+	entries := []audit.Entry{{
+		ActorID: auth.UserID, Action: audit.Update, Entity: audit.Artwork, EntityID: artworkID, ArtistID: before.ArtistID,
+		Before: before, After: after,
+	}}
+	if sourceID != 0 {
+		imageEntry := audit.Entry{
+			ActorID: auth.UserID, Action: audit.Update, Entity: audit.Image, EntityID: image.ID, ArtistID: before.ArtistID,
+			Before: current,
+			After: imageAudit{
+				ArtworkID: artworkID, Mime: image.OriginalMime, ThumbBytes: image.ThumbBytes, ImageBytes: image.ImageBytes,
+				Edits: parseEdits(edits),
+			},
+		}
+		if current.Mime == "" {
+			imageEntry.Action, imageEntry.Before = audit.Create, nil
+		}
+		entries = append(entries, imageEntry)
+	}
+	for _, e := range entries {
+		if err := audit.Record(tx, e); err != nil {
+			sendErrorResponse(w, "Failed to revert artwork", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		sendErrorResponse(w, "Failed to revert artwork", http.StatusInternalServerError)
+		return
+	}
+	if sourceID != 0 {
+		refreshEnhanced(artworkID)
+		uncacheImage(image.ID)
+	}
+
+	sendSuccessResponse(w, after, "Artwork reverted", http.StatusOK)
+}
+
+// artworkState is what a revision diff compares: the details plus which image
+type artworkState struct {
+	Grade       string    `json:"grade"`
+	School      string    `json:"school"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Image       *imageRef `json:"image"`
+}
+
+// imageRef identifies an image by the revision holding it (0 for the current image)
+type imageRef struct {
+	RevisionID int    `json:"revision_id,omitempty"`
+	Mime       string `json:"mime"`
+	ImageBytes int    `json:"image_bytes"`
+}
+
+// revisionState returns how an artwork looked at rev, or now if rev is nil
+func revisionState(artworkID int, rev *models.ArtworkRevision) (artworkState, error) {
+	var state artworkState
+	sourceID := 0
+	if rev != nil {
+		state = artworkState{Grade: rev.Grade, School: rev.School, Title: rev.Title, Description: rev.Description}
+		var err error
+		if sourceID, err = revisionImageSource(artworkID, rev.ID); err != nil {
+			return state, err
+		}
+	} else {
+		a, err := fetchArtwork(artworkID)
+		if err != nil {
+			return state, err
+		}
+		state = artworkState{Grade: a.Grade, School: a.School, Title: a.Title, Description: a.Description}
+	}
+
+	ref := imageRef{RevisionID: sourceID}
+	var err error
+	if sourceID != 0 {
+		err = config.DB.QueryRow(
+			"SELECT original_mime, image_bytes FROM artwork_revisions WHERE id = ?", sourceID,
+		).Scan(&ref.Mime, &ref.ImageBytes)
+	} else {
+		err = config.DB.QueryRow(
+			"SELECT original_mime, image_bytes FROM images WHERE artwork_id = ? AND deleted_at IS NULL", artworkID,
+		).Scan(&ref.Mime, &ref.ImageBytes)
+	}
+	if err == sql.ErrNoRows {
+		return state, nil
+	} else if err != nil {
+		return state, err
+	}
+	state.Image = &ref
+	return state, nil
+}
+
+// saveRevision records how an artwork looks right now, ahead of a change.
+// withImage also keeps a copy of its current image and the original it was
+// made from, for changes that replace it.
+func saveRevision(tx *config.Tx, artworkID, actorID int, withImage bool) error {
+	query := `
+		INSERT INTO artwork_revisions (artwork_id, created_at, actor_id, grade, school, title, description)
+		SELECT id, ?, ?, grade, school, title, description FROM artworks WHERE id = ?
+	`
+	if withImage {
+		query = `
+			INSERT INTO artwork_revisions
+				(artwork_id, created_at, actor_id, grade, school, title, description, original_mime, thumb, image, thumb_bytes, image_bytes, edits,
+					original, original_bytes)
+			SELECT a.id, ?, ?, a.grade, a.school, a.title, a.description,
+				i.original_mime, i.thumb, i.image, COALESCE(i.thumb_bytes, 0), COALESCE(i.image_bytes, 0), i.edits,
+				i.original, COALESCE(i.original_bytes, 0)
+			FROM artworks a LEFT JOIN images i ON i.artwork_id = a.id
+			WHERE a.id = ?
+		`
+	}
+	_, err := tx.Exec(query, time.Now().UTC(), actorID, artworkID)
+	return err
+}
+
+// replaceImage swaps an artwork's image for a new upload in tx, keeping the
+// old renditions in a revision. palette is the JSON from imagePalette and
+// blurhash what imageBlurHash made of the thumbnail.
+func replaceImage(tx *config.Tx, artworkID, actorID int, mime string, original, thumb, image []byte, hash uint64, palette, blurhash interface{}) error {
+	if err := saveRevision(tx, artworkID, actorID, true); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		UPDATE images SET original_mime = ?, thumb = ?, image = ?, thumb_bytes = ?, image_bytes = ?,
+			original = ?, original_bytes = ?, edits = NULL, phash = ?, palette = ?, blurhash = ?,
+			url = NULL, deleted_at = NULL, version = version + 1
+		WHERE artwork_id = ?
+	`, mime, thumb, image, len(thumb), len(image), original, len(original), int64(hash), palette, blurhash, artworkID)
+	return err
+}
+
+// updateArtworkDetails writes an artwork's grade, school, title and description
+func updateArtworkDetails(tx *config.Tx, a models.Artwork) error {
+	_, err := tx.Exec(
+		"UPDATE artworks SET grade = ?, school = ?, title = ?, description = ? WHERE id = ?",
+		nullIfEmpty(a.Grade), nullIfEmpty(a.School), nullIfEmpty(a.Title), nullIfEmpty(a.Description), a.ID,
+	)
+	return err
+}
+
+// revisionImageSource returns the revision holding the image an artwork had
+// at revID: the first revision from then on that kept one. 0 means nothing
+// has replaced it since, so it is the current image.
+func revisionImageSource(artworkID, revID int) (int, error) {
+	var id int
+	err := config.DB.QueryRow(`
+		SELECT id FROM artwork_revisions
+		WHERE artwork_id = ? AND id >= ? AND original_mime IS NOT NULL
+		ORDER BY id LIMIT 1
+	`, artworkID, revID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// revisionTarget checks the signed-in user's role for the artwork in {id} and
+// loads its revision {rev}
+func revisionTarget(w http.ResponseWriter, r *http.Request, min string) (int, models.ArtworkRevision, bool) {
+	artworkID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid artwork ID", http.StatusBadRequest)
+		return 0, models.ArtworkRevision{}, false
+	}
+	revID, err := strconv.Atoi(mux.Vars(r)["rev"])
+	if err != nil {
+		sendErrorResponse(w, "Invalid revision ID", http.StatusBadRequest)
+		return 0, models.ArtworkRevision{}, false
+	}
+	if _, ok := requireArtworkRole(w, r, artworkID, min); !ok {
+		return 0, models.ArtworkRevision{}, false
+	}
+
+	rev, err := fetchRevision(artworkID, revID)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Revision not found", http.StatusNotFound)
+		return 0, rev, false
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to fetch revision", http.StatusInternalServerError)
+		return 0, rev, false
+	}
+	return artworkID, rev, true
+}
+
+const revisionQuery = `
+	SELECT r.id, r.artwork_id, r.created_at, r.actor_id, u.fname, u.lname,
+		r.grade, r.school, r.title, r.description, r.original_mime, r.image_bytes
+	FROM artwork_revisions r
+	LEFT JOIN users u ON u.id = r.actor_id
+`
+
+// fetchRevision loads one of an artwork's revisions
+func fetchRevision(artworkID, revID int) (models.ArtworkRevision, error) {
+	return scanRevision(config.DB.QueryRow(revisionQuery+" WHERE r.id = ? AND r.artwork_id = ?", revID, artworkID))
+}
+
+func scanRevision(row interface{ Scan(...interface{}) error }) (models.ArtworkRevision, error) {
+	var rev models.ArtworkRevision
+	var actorID sql.NullInt64
+	var fname, lname, grade, school, title, description, mime sql.NullString
+	err := row.Scan(
+		&rev.ID, &rev.ArtworkID, &rev.CreatedAt, &actorID, &fname, &lname,
+		&grade, &school, &title, &description, &mime, &rev.ImageBytes,
+	)
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		rev.ActorID = &id
+	}
+	if fname.Valid {
+		rev.ActorName = strings.TrimSpace(fname.String + " " + lname.String)
+	}
+	rev.Grade, rev.School, rev.Title, rev.Description = grade.String, school.String, title.String, description.String
+	rev.HasImage = mime.Valid
+	return rev, err
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"go-art-api/config"
+)
+
+// Roles a user can hold for an artist, from least to most access
+const (
+	RoleViewer = "viewer" // browse, download and print
+	RoleEditor = "editor" // also upload, edit and delete artwork
+	RoleOwner  = "owner"  // also manage who has access
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// validRole reports whether role is one of the known roles
+func validRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// artistRole returns the user's role for an artist, or "" if they aren't
+// linked or the artist is in the trash
+func artistRole(userID, artistID int) (string, error) {
+	var role string
+	err := config.DB.QueryRow(`
+		SELECT ua.role FROM user_artists ua
+		JOIN artists a ON a.id = ua.artist_id
+		WHERE ua.user_id = ? AND ua.artist_id = ? AND a.deleted_at IS NULL
+	`, userID, artistID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// requireArtistRole checks that the signed-in user has at least min for the
+// artist. Users with no link get a 404 so artist IDs can't be probed; linked
+// users with too little access get a 403.
+func requireArtistRole(w http.ResponseWriter, r *http.Request, artistID int, min string) bool {
+	return checkArtistRole(w, r, artistID, min, "Artist not found")
+}
+
+// checkArtistRole is requireArtistRole with the 404 message for users with no
+// link, which must be the same one given when what they asked for doesn't
+// exist at all
+func checkArtistRole(w http.ResponseWriter, r *http.Request, artistID int, min, notFound string) bool {
+	auth, ok := currentAuth(r)
+	if !ok {
+		sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return false
+	}
+
+	role, err := artistRole(auth.UserID, artistID)
+	if err != nil {
+		log.Printf("DB error checking role of user %d for artist %d: %v", auth.UserID, artistID, err)
+		sendErrorResponse(w, "Failed to check permissions", http.StatusInternalServerError)
+		return false
+	}
+	if role == "" {
+		sendErrorResponse(w, notFound, http.StatusNotFound)
+		return false
+	}
+	if roleRank[role] < roleRank[min] {
+		sendErrorResponse(w, "You need "+min+" access to this artist", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// requireArtworkRole is requireArtistRole for the artist that owns an artwork
+func requireArtworkRole(w http.ResponseWriter, r *http.Request, artworkID int, min string) (artistID int, ok bool) {
+	return checkArtworkRole(w, r, artworkID, min, "Artwork not found")
+}
+
+func checkArtworkRole(w http.ResponseWriter, r *http.Request, artworkID int, min, notFound string) (artistID int, ok bool) {
+	err := config.DB.QueryRow("SELECT artist_id FROM artworks WHERE id = ? AND deleted_at IS NULL", artworkID).Scan(&artistID)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, notFound, http.StatusNotFound)
+		return 0, false
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to fetch artwork", http.StatusInternalServerError)
+		return 0, false
+	}
+	return artistID, checkArtistRole(w, r, artistID, min, notFound)
+}
+
+// requireImageRole is requireArtworkRole for the artwork an image belongs to
+func requireImageRole(w http.ResponseWriter, r *http.Request, imageID int, min string) bool {
+	var artworkID int
+	err := config.DB.QueryRow("SELECT artwork_id FROM images WHERE id = ? AND deleted_at IS NULL", imageID).Scan(&artworkID)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Image not found", http.StatusNotFound)
+		return false
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to fetch image", http.StatusInternalServerError)
+		return false
+	}
+	_, ok := checkArtworkRole(w, r, artworkID, min, "Image not found")
+	return ok
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-art-api/config"
+	"go-art-api/models"
+)
+
+// errQuotaExceeded is returned when an upload would push a family over its quota
+var errQuotaExceeded = errors.New("family storage quota exceeded")
+
+// GetStorageStats reports overall storage usage and headroom against the
+// configured limit. Pass ?artist_id= (an artist you can see) or ?user_id=
+// (yourself, unless you're an admin) to include a family breakdown.
+func GetStorageStats(w http.ResponseWriter, r *http.Request) {
+	stats := models.StorageStats{
+		LimitBytes:  config.StorageLimitBytes(),
+		FamilyQuota: config.FamilyQuotaBytes(),
+	}
+
+	err := config.DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(thumb_bytes), 0), COALESCE(SUM(image_bytes), 0), COALESCE(SUM(original_bytes), 0),
+			COALESCE(SUM(enhanced_thumb_bytes + enhanced_image_bytes), 0)
+		FROM images
+	`).Scan(&stats.ImageCount, &stats.ThumbBytes, &stats.ImageBytes, &stats.OriginalBytes, &stats.EnhancedBytes)
+	if err != nil {
+		log.Printf("DB error fetching storage stats: %v", err)
+		sendErrorResponse(w, "Failed to fetch storage stats", http.StatusInternalServerError)
+		return
+	}
+	err = config.DB.QueryRow(
+		"SELECT COALESCE(SUM(thumb_bytes + image_bytes + original_bytes), 0) FROM artwork_revisions",
+	).Scan(&stats.RevisionBytes)
+	if err != nil {
+		log.Printf("DB error fetching revision storage: %v", err)
+		sendErrorResponse(w, "Failed to fetch storage stats", http.StatusInternalServerError)
+		return
+	}
+	stats.TotalBytes = stats.ThumbBytes + stats.ImageBytes + stats.OriginalBytes + stats.EnhancedBytes + stats.RevisionBytes
+
+	// The DB's own view of its size includes indexes and row overhead, which is
+	// what actually counts against the host's limit.
+	stats.DatabaseBytes, err = databaseSize()
+	if err != nil {
+		log.Printf("Could not read database size, falling back to image bytes: %v", err)
+		stats.DatabaseBytes = stats.TotalBytes
+	}
+	stats.HeadroomBytes, stats.UsedPercent = headroom(stats.DatabaseBytes, stats.LimitBytes)
+
+	var artistIDs []int
+	if idStr := r.URL.Query().Get("artist_id"); idStr != "" {
+		artistID, err := strconv.Atoi(idStr)
+		if err != nil {
+			sendErrorResponse(w, "Invalid artist ID", http.StatusBadRequest)
+			return
+		}
+		if !requireArtistRole(w, r, artistID, RoleViewer) {
+			return
+		}
+		artistIDs, err = familyArtistIDs(config.DB, artistID)
+		if err != nil {
+			sendErrorResponse(w, "Failed to resolve family", http.StatusInternalServerError)
+			return
+		}
+	} else if idStr := r.URL.Query().Get("user_id"); idStr != "" {
+		userID, err := strconv.Atoi(idStr)
+		if err != nil {
+			sendErrorResponse(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		// Other users' families are for admins
+		if auth, _ := currentAuth(r); userID != auth.UserID {
+			admin, err := isAdmin(auth.UserID)
+			if err != nil {
+				sendErrorResponse(w, "Failed to check permissions", http.StatusInternalServerError)
+				return
+			}
+			if !admin {
+				sendErrorResponse(w, "Administrator access required", http.StatusForbidden)
+				return
+			}
+		}
+		artistIDs, err = userFamilyArtistIDs(userID)
+		if err != nil {
+			sendErrorResponse(w, "Failed to resolve family", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if artistIDs != nil {
+		family, err := familyStorage(config.DB, artistIDs)
+		if err != nil {
+			log.Printf("DB error fetching family storage: %v", err)
+			sendErrorResponse(w, "Failed to fetch family storage", http.StatusInternalServerError)
+			return
+		}
+		stats.Family = family
+	}
+
+	sendSuccessResponse(w, stats, "Storage stats", http.StatusOK)
+}
+
+// checkStorageQuota returns errQuotaExceeded if adding incoming bytes to the
+// artist's family (less any bytes being replaced) would exceed the quota.
+// Call it in the transaction that writes them: it locks the family's artists
+// until that commits, so parallel uploads are counted one after another
+// rather than each fitting on its own.
+func checkStorageQuota(tx *config.Tx, artistID int, incoming, replaced int64) error {
+	quota := config.FamilyQuotaBytes()
+	if quota == 0 {
+		return nil
+	}
+
+	// A no-op UPDATE locks the rows on MySQL and PostgreSQL and takes the
+	// write lock on SQLite, where SELECT ... FOR UPDATE doesn't exist. The
+	// artist goes first so the family is read under the lock, then the rest.
+	if _, err := tx.Exec("UPDATE artists SET id = id WHERE id = ?", artistID); err != nil {
+		return err
+	}
+	artistIDs, err := familyArtistIDs(tx, artistID)
+	if err != nil {
+		return err
+	}
+	placeholders, args := inClause(artistIDs)
+	if _, err := tx.Exec(fmt.Sprintf("UPDATE artists SET id = id WHERE id IN (%s)", placeholders), args...); err != nil {
+		return err
+	}
+	family, err := familyStorage(tx, artistIDs)
+	if err != nil {
+		return err
+	}
+
+	if family.UsedBytes-replaced+incoming > quota {
+		return fmt.Errorf("%w: %.2f MB used of %.2f MB, upload needs %.2f MB",
+			errQuotaExceeded, megabytes(family.UsedBytes), megabytes(quota), megabytes(incoming-replaced))
+	}
+	return nil
+}
+
+// familyArtistIDs returns the artist plus every artist that shares an owner
+// with it. Editors and viewers don't count, so a grandparent viewing two
+// families doesn't pool their quotas.
+func familyArtistIDs(q queryer, artistID int) ([]int, error) {
+	rows, err := q.Query(`
+		SELECT DISTINCT ua2.artist_id
+		FROM user_artists ua1
+		JOIN user_artists ua2 ON ua1.user_id = ua2.user_id
+		WHERE ua1.artist_id = ? AND ua1.role = ? AND ua2.role = ?
+	`, artistID, RoleOwner, RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{artistID}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if id != artistID {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// userFamilyArtistIDs returns every artist linked to the user
+func userFamilyArtistIDs(userID int) ([]int, error) {
+	rows, err := config.DB.Query("SELECT artist_id FROM user_artists WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// queryer is satisfied by both config.DB and a *config.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// familyStorage sums the stored image bytes across a set of artists
+func familyStorage(q queryer, artistIDs []int) (*models.FamilyStorage, error) {
+	family := &models.FamilyStorage{
+		ArtistIDs:  artistIDs,
+		QuotaBytes: config.FamilyQuotaBytes(),
+	}
+
+	if len(artistIDs) > 0 {
+		placeholders, args := inClause(artistIDs)
+		query := fmt.Sprintf(`
+			SELECT COUNT(i.id), COALESCE(SUM(i.thumb_bytes + i.image_bytes + i.original_bytes + i.enhanced_thumb_bytes + i.enhanced_image_bytes), 0)
+			FROM images i
+			JOIN artworks a ON a.id = i.artwork_id
+			WHERE a.artist_id IN (%s)
+		`, placeholders)
+		if err := q.QueryRow(query, args...).Scan(&family.ImageCount, &family.UsedBytes); err != nil {
+			return nil, err
+		}
+
+		// Earlier images kept in edit history count too
+		query = fmt.Sprintf(`
+			SELECT COALESCE(SUM(r.thumb_bytes + r.image_bytes + r.original_bytes), 0)
+			FROM artwork_revisions r
+			JOIN artworks a ON a.id = r.artwork_id
+			WHERE a.artist_id IN (%s)
+		`, placeholders)
+		if err := q.QueryRow(query, args...).Scan(&family.RevisionBytes); err != nil {
+			return nil, err
+		}
+		family.UsedBytes += family.RevisionBytes
+	}
+
+	family.HeadroomBytes, family.UsedPercent = headroom(family.UsedBytes, family.QuotaBytes)
+	return family, nil
+}
+
+// databaseSize returns the size of the current database as reported by the DB
+func databaseSize() (int64, error) {
+	var size int64
+	err := config.DB.QueryRow(config.DB.Dialect.DatabaseSizeQuery()).Scan(&size)
+	return size, err
+}
+
+// headroom returns remaining bytes and percentage used (a zero limit means unlimited)
+func headroom(used, limit int64) (int64, float64) {
+	if limit == 0 {
+		return 0, 0
+	}
+	remaining := limit - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, float64(used) / float64(limit) * 100
+}
+
+// inClause builds "?, ?, ?" placeholders and matching args for an IN (...) list
+func inClause(ids []int) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return strings.Join(placeholders, ", "), args
+}
+
+// megabytes converts bytes to MB for human-readable messages
+func megabytes(b int64) float64 {
+	return float64(b) / (1 << 20)
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"go-art-api/audit"
+	"go-art-api/config"
+	"go-art-api/models"
+
+	"github.com/gorilla/mux"
+)
+
+// trashKind describes one kind of trashable row, keyed by its {type} in the
+// URL. lookup returns the owning artist and deleted_at of a trashed row;
+// parentTrashed counts trashed rows it hangs off, which block a restore.
+type trashKind struct {
+	table         string
+	entity        string
+	minRole       string
+	lookup        string
+	parentTrashed string
+}
+
+var trashKinds = map[string]trashKind{
+	"artists": {
+		table: "artists", entity: audit.Artist, minRole: RoleOwner,
+		lookup: "SELECT id, deleted_at FROM artists WHERE id = ? AND deleted_at IS NOT NULL",
+	},
+	"artworks": {
+		table: "artworks", entity: audit.Artwork, minRole: RoleEditor,
+		lookup: "SELECT artist_id, deleted_at FROM artworks WHERE id = ? AND deleted_at IS NOT NULL",
+		parentTrashed: `
+			SELECT COUNT(*) FROM artworks a JOIN artists ar ON ar.id = a.artist_id
+			WHERE a.id = ? AND ar.deleted_at IS NOT NULL`,
+	},
+	"images": {
+		table: "images", entity: audit.Image, minRole: RoleEditor,
+		lookup: `
+			SELECT a.artist_id, i.deleted_at FROM images i JOIN artworks a ON a.id = i.artwork_id
+			WHERE i.id = ? AND i.deleted_at IS NOT NULL`,
+		parentTrashed: `
+			SELECT COUNT(*) FROM images i
+			JOIN artworks a ON a.id = i.artwork_id
+			JOIN artists ar ON ar.id = a.artist_id
+			WHERE i.id = ? AND (a.deleted_at IS NOT NULL OR ar.deleted_at IS NOT NULL)`,
+	},
+}
+
+// GetTrash lists what the signed-in user can restore, newest first: artists
+// they own, and artworks and images of artists they can edit
+func GetTrash(w http.ResponseWriter, r *http.Request) {
+	auth, _ := currentAuth(r)
+
+	roles, err := linkedRoles(auth.UserID)
+	if err != nil {
+		log.Printf("DB error fetching roles of user %d: %v", auth.UserID, err)
+		sendErrorResponse(w, "Failed to fetch trash", http.StatusInternalServerError)
+		return
+	}
+	var owned, editable []int
+	for artistID, role := range roles {
+		if roleRank[role] >= roleRank[RoleOwner] {
+			owned = append(owned, artistID)
+		}
+		if roleRank[role] >= roleRank[RoleEditor] {
+			editable = append(editable, artistID)
+		}
+	}
+
+	retention := config.TrashRetention()
+	items := []models.TrashItem{}
+	for _, q := range []struct {
+		kind, query string
+		artistIDs   []int
+	}{
+		{"artist", "SELECT id, id, 0, name, deleted_at FROM artists WHERE deleted_at IS NOT NULL AND id IN (%s)", owned},
+		{"artwork", "SELECT id, artist_id, 0, title, deleted_at FROM artworks WHERE deleted_at IS NOT NULL AND artist_id IN (%s)", editable},
+		{"image", `
+			SELECT i.id, a.artist_id, a.id, a.title, i.deleted_at FROM images i JOIN artworks a ON a.id = i.artwork_id
+			WHERE i.deleted_at IS NOT NULL AND a.artist_id IN (%s)`, editable},
+	} {
+		if len(q.artistIDs) == 0 {
+			continue
+		}
+		placeholders, args := inClause(q.artistIDs)
+		rows, err := config.DB.Query(fmt.Sprintf(q.query, placeholders), args...)
+		if err != nil {
+			log.Printf("DB error fetching trashed %ss: %v", q.kind, err)
+			sendErrorResponse(w, "Failed to fetch trash", http.StatusInternalServerError)
+			return
+		}
+		for rows.Next() {
+			item := models.TrashItem{Type: q.kind}
+			var name sql.NullString
+			if err := rows.Scan(&item.ID, &item.ArtistID, &item.ArtworkID, &name, &item.DeletedAt); err != nil {
+				rows.Close()
+				log.Printf("DB error scanning trashed %ss: %v", q.kind, err)
+				sendErrorResponse(w, "Failed to scan trash", http.StatusInternalServerError)
+				return
+			}
+			item.Name = name.String
+			item.PurgeAt = item.DeletedAt.Add(retention)
+			items = append(items, item)
+		}
+		rows.Close()
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	sendJSONResponse(w, items, http.StatusOK)
+}
+
+// RestoreTrashItem takes an artist, artwork or image back out of the trash.
+// Something inside a trashed artist or artwork can't come back on its own.
+func RestoreTrashItem(w http.ResponseWriter, r *http.Request) {
+	kind, id, artistID, deletedAt, ok := trashTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if kind.parentTrashed != "" {
+		var n int
+		if err := config.DB.QueryRow(kind.parentTrashed, id).Scan(&n); err != nil {
+			sendErrorResponse(w, "Failed to restore", http.StatusInternalServerError)
+			return
+		}
+		if n > 0 {
+			sendErrorResponse(w, "What this belongs to is in the trash too, restore that first", http.StatusConflict)
+			return
+		}
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to restore", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	auth, _ := currentAuth(r)
+	_, err = tx.Exec("UPDATE "+kind.table+" SET deleted_at = NULL WHERE id = ?", id)
+	if err == nil {
+		err = audit.Record(tx, audit.Entry{
+			ActorID: auth.UserID, Action: audit.Update, Entity: kind.entity, EntityID: id, ArtistID: artistID,
+			Before: map[string]time.Time{"deleted_at": deletedAt}, After: map[string]interface{}{"deleted_at": nil},
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("DB error restoring %s %d: %v", kind.entity, id, err)
+		sendErrorResponse(w, "Failed to restore", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, nil, "Restored", http.StatusOK)
+}
+
+// DeleteTrashItem permanently deletes something in the trash without waiting
+// for it to expire. Anything inside it goes too (ON DELETE CASCADE).
+func DeleteTrashItem(w http.ResponseWriter, r *http.Request) {
+	kind, id, artistID, deletedAt, ok := trashTarget(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to delete", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	auth, _ := currentAuth(r)
+	_, err = tx.Exec("DELETE FROM "+kind.table+" WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err == nil {
+		err = audit.Record(tx, audit.Entry{
+			ActorID: auth.UserID, Action: audit.Delete, Entity: kind.entity, EntityID: id, ArtistID: artistID,
+			Before: map[string]time.Time{"deleted_at": deletedAt},
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("DB error purging %s %d: %v", kind.entity, id, err)
+		sendErrorResponse(w, "Failed to delete", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// trashTarget loads the trashed row in {type}/{id} and checks the signed-in
+// user's role for its artist. Rows that aren't in the trash are a 404.
+func trashTarget(w http.ResponseWriter, r *http.Request) (kind trashKind, id, artistID int, deletedAt time.Time, ok bool) {
+	kind, found := trashKinds[mux.Vars(r)["type"]]
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if !found || err != nil {
+		sendErrorResponse(w, "Invalid trash item", http.StatusBadRequest)
+		return
+	}
+
+	err = config.DB.QueryRow(kind.lookup, id).Scan(&artistID, &deletedAt)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "Not found in the trash", http.StatusNotFound)
+		return
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to fetch trash item", http.StatusInternalServerError)
+		return
+	}
+
+	// requireArtistRole treats a trashed artist as missing, so the link is
+	// checked directly here
+	auth, _ := currentAuth(r)
+	roles, err := linkedRoles(auth.UserID)
+	if err != nil {
+		sendErrorResponse(w, "Failed to check permissions", http.StatusInternalServerError)
+		return
+	}
+	role, linked := roles[artistID]
+	if !linked {
+		sendErrorResponse(w, "Not found in the trash", http.StatusNotFound)
+		return
+	}
+	if roleRank[role] < roleRank[kind.minRole] {
+		sendErrorResponse(w, "You need "+kind.minRole+" access to this artist", http.StatusForbidden)
+		return
+	}
+	return kind, id, artistID, deletedAt, true
+}
+
+// linkedRoles maps each artist the user is linked to, trashed or not, to
+// their role
+func linkedRoles(userID int) (map[int]string, error) {
+	rows, err := config.DB.Query("SELECT artist_id, role FROM user_artists WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := map[int]string{}
+	for rows.Next() {
+		var artistID int
+		var role string
+		if err := rows.Scan(&artistID, &role); err != nil {
+			return nil, err
+		}
+		roles[artistID] = role
+	}
+	return roles, rows.Err()
+}
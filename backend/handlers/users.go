@@ -4,9 +4,13 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
+	"go-art-api/audit"
 	"go-art-api/config"
 	"go-art-api/models"
 	"go-art-api/utils"
@@ -16,7 +20,9 @@ import (
 
 // GetUsers retrieves all users
 func GetUsers(w http.ResponseWriter, r *http.Request) {
-	rows, err := config.DB.Query("SELECT id, fname, lname, email, created_at FROM users ORDER BY id")
+	rows, err := config.DB.Query(
+		"SELECT id, fname, lname, email, email_verified_at, is_admin, disabled_at, must_reset_password, created_at FROM users ORDER BY id",
+	)
 	if err != nil {
 		sendErrorResponse(w, "Failed to fetch users", http.StatusInternalServerError)
 		return
@@ -26,10 +32,15 @@ func GetUsers(w http.ResponseWriter, r *http.Request) {
 	var users []models.User
 	for rows.Next() {
 		var u models.User
-		if err := rows.Scan(&u.ID, &u.FName, &u.LName, &u.Email, &u.CreatedAt); err != nil {
+		var verifiedAt, disabledAt sql.NullTime
+		if err := rows.Scan(
+			&u.ID, &u.FName, &u.LName, &u.Email, &verifiedAt, &u.IsAdmin, &disabledAt, &u.MustResetPassword, &u.CreatedAt,
+		); err != nil {
 			sendErrorResponse(w, "Failed to scan user data", http.StatusInternalServerError)
 			return
 		}
+		u.EmailVerifiedAt = nullTimePtr(verifiedAt)
+		u.DisabledAt = nullTimePtr(disabledAt)
 		users = append(users, u)
 	}
 
@@ -53,18 +64,16 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 	// Hash the password using Argon2
 	hashedPassword, err := utils.HashPassword(userCreate.Password)
 	if err != nil {
-		sendErrorResponse(w, "Failed to hash password", http.StatusInternalServerError)
+		sendPasswordHashError(w, err, "Failed to hash password")
 		return
 	}
 
 	// Insert user into database
-	result, err := config.DB.Exec(
-		"INSERT INTO users (fname, lname, email, pwd) VALUES (?, ?, ?, ?)",
-		userCreate.FName, userCreate.LName, userCreate.Email, hashedPassword,
-	)
+	auth, _ := currentAuth(r)
+	id, err := insertUser(auth.UserID, userCreate, hashedPassword)
 	if err != nil {
 		// Check for duplicate email
-		if contains(err.Error(), "Duplicate entry") {
+		if config.DB.IsUniqueViolation(err) {
 			sendErrorResponse(w, "Email already exists", http.StatusConflict)
 			return
 		}
@@ -72,9 +81,6 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the created user ID
-	id, _ := result.LastInsertId()
-
 	// Return the created user (without password)
 	user := models.User{
 		ID:    int(id),
@@ -88,6 +94,10 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 
 // RegisterUser handles user registration
 func RegisterUser(w http.ResponseWriter, r *http.Request) {
+	if !allowRequest(w, registerIPLimiter, clientIP(r)) {
+		return
+	}
+
 	var userCreate models.UserCreate
 	if err := json.NewDecoder(r.Body).Decode(&userCreate); err != nil {
 		sendErrorResponse(w, "Invalid JSON data", http.StatusBadRequest)
@@ -100,20 +110,22 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Registration also sends mail, so it shares the per-address mail limit
+	if !allowRequest(w, mailEmailLimiter, accountKey(userCreate.Email)) {
+		return
+	}
+
 	// Hash password
 	hashedPassword, err := utils.HashPassword(userCreate.Password)
 	if err != nil {
-		sendErrorResponse(w, "Failed to process registration", http.StatusInternalServerError)
+		sendPasswordHashError(w, err, "Failed to process registration")
 		return
 	}
 
 	// Insert user
-	result, err := config.DB.Exec(
-		"INSERT INTO users (fname, lname, email, pwd) VALUES (?, ?, ?, ?)",
-		userCreate.FName, userCreate.LName, userCreate.Email, hashedPassword,
-	)
+	id, err := insertUser(0, userCreate, hashedPassword)
 	if err != nil {
-		if contains(err.Error(), "Duplicate entry") {
+		if config.DB.IsUniqueViolation(err) {
 			sendErrorResponse(w, "Email already registered", http.StatusConflict)
 			return
 		}
@@ -121,7 +133,10 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, _ := result.LastInsertId()
+	// The account works straight away; verification is confirmed separately
+	if err := sendVerificationEmail(int(id), userCreate.Email, userCreate.FName); err != nil {
+		log.Printf("Error sending verification email to user %d: %v", id, err)
+	}
 
 	response := models.APIResponse{
 		Success: true,
@@ -137,6 +152,10 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 
 // LoginUser handles user authentication
 func LoginUser(w http.ResponseWriter, r *http.Request) {
+	if !allowRequest(w, loginIPLimiter, clientIP(r)) {
+		return
+	}
+
 	var login models.UserLogin
 	if err := json.NewDecoder(r.Body).Decode(&login); err != nil {
 		sendErrorResponse(w, "Invalid JSON data", http.StatusBadRequest)
@@ -148,15 +167,37 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Checked before touching Argon2, so a locked account costs nothing to hammer
+	account := accountKey(login.Email)
+	if locked := loginLockout.Locked(account); locked > 0 {
+		sendTooManyRequests(w, "Too many failed login attempts, try again later", locked)
+		return
+	}
+
 	// Get user from database
 	var user models.User
 	var hashedPassword string
+	var verifiedAt, disabledAt sql.NullTime
 	err := config.DB.QueryRow(
-		"SELECT id, fname, lname, email, pwd FROM users WHERE email = ?",
+		"SELECT id, fname, lname, email, pwd, email_verified_at, is_admin, disabled_at, must_reset_password FROM users WHERE email = ?",
 		login.Email,
-	).Scan(&user.ID, &user.FName, &user.LName, &user.Email, &hashedPassword)
+	).Scan(
+		&user.ID, &user.FName, &user.LName, &user.Email, &hashedPassword,
+		&verifiedAt, &user.IsAdmin, &disabledAt, &user.MustResetPassword,
+	)
 
 	if err == sql.ErrNoRows {
+		// Hash anyway, so unknown emails take as long as wrong passwords and
+		// timing doesn't tell which accounts exist
+		hash, err := dummyPasswordHash()
+		if err == nil {
+			_, err = utils.VerifyPassword(login.Password, hash)
+		}
+		if err != nil {
+			sendPasswordHashError(w, err, "Login failed")
+			return
+		}
+		loginLockout.Fail(account)
 		sendErrorResponse(w, "Invalid email or password", http.StatusUnauthorized)
 		return
 	} else if err != nil {
@@ -164,25 +205,59 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	user.EmailVerifiedAt = nullTimePtr(verifiedAt)
+
 	// Verify password
 	isValid, err := utils.VerifyPassword(login.Password, hashedPassword)
 	if err != nil {
-		sendErrorResponse(w, "Login failed", http.StatusInternalServerError)
+		sendPasswordHashError(w, err, "Login failed")
 		return
 	}
 
 	if !isValid {
+		if lock := loginLockout.Fail(account); lock > 0 {
+			log.Printf("⚠️  Login for user %d locked for %s after repeated failures", user.ID, lock)
+		}
 		sendErrorResponse(w, "Invalid email or password", http.StatusUnauthorized)
 		return
 	}
+	loginLockout.Reset(account)
+
+	// Only reported once the password checks out, so these don't reveal
+	// anything about accounts the caller can't sign in to
+	if disabledAt.Valid {
+		sendErrorResponse(w, errAccountDisabled.Error(), http.StatusForbidden)
+		return
+	}
+	if user.MustResetPassword {
+		sendErrorResponse(w, "A password reset is required, use the link emailed to you", http.StatusForbidden)
+		return
+	}
 
-	// In a real app, you'd generate a JWT token here
+	// The plaintext is only available now, so this is when old hashes get upgraded
+	if utils.NeedsRehash(hashedPassword) {
+		rehashPassword(user.ID, login.Password, hashedPassword)
+	}
+
+	pair, err := createSession(r, user.ID)
+	if err != nil {
+		log.Printf("Error creating session for user %d: %v", user.ID, err)
+		sendErrorResponse(w, "Login failed", http.StatusInternalServerError)
+		return
+	}
+
+	// "token" is kept for clients written before refresh tokens existed
 	response := models.APIResponse{
 		Success: true,
 		Message: "Login successful",
 		Data: map[string]interface{}{
-			"user":  user,
-			"token": "jwt-token-would-go-here",
+			"user":          user,
+			"token":         pair.AccessToken,
+			"access_token":  pair.AccessToken,
+			"refresh_token": pair.RefreshToken,
+			"token_type":    pair.TokenType,
+			"expires_in":    pair.ExpiresIn,
+			"expires_at":    pair.ExpiresAt,
 		},
 	}
 
@@ -198,9 +273,7 @@ func GetUserByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var u models.User
-	err = config.DB.QueryRow("SELECT id, fname, lname, email FROM users WHERE id = ?", id).
-		Scan(&u.ID, &u.FName, &u.LName, &u.Email)
+	u, err := fetchUser(config.DB, id)
 
 	if err == sql.ErrNoRows {
 		sendErrorResponse(w, "User not found", http.StatusNotFound)
@@ -234,23 +307,29 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update user
-	_, err = config.DB.Exec(
+	before, err := fetchUser(config.DB, id)
+	if err == sql.ErrNoRows {
+		sendErrorResponse(w, "User not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to fetch user", http.StatusInternalServerError)
+		return
+	}
+
+	// Update user and return them as they are now
+	after, err := changeUser(r, before, false,
 		"UPDATE users SET fname = ?, lname = ?, email = ? WHERE id = ?",
 		userUpdate.FName, userUpdate.LName, userUpdate.Email, id,
 	)
 	if err != nil {
-		if contains(err.Error(), "Duplicate entry") {
+		if config.DB.IsUniqueViolation(err) {
 			sendErrorResponse(w, "Email already exists", http.StatusConflict)
 			return
 		}
 		sendErrorResponse(w, "Failed to update user", http.StatusInternalServerError)
 		return
 	}
-
-	// Return updated user
-	userUpdate.ID = id
-	sendJSONResponse(w, userUpdate, http.StatusOK)
+	sendJSONResponse(w, after, http.StatusOK)
 }
 
 // DeleteUser deletes a user
@@ -262,21 +341,38 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if user exists
-	var exists bool
-	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", id).Scan(&exists)
-	if err != nil {
-		sendErrorResponse(w, "Failed to check user", http.StatusInternalServerError)
+	if auth, _ := currentAuth(r); auth.UserID == id {
+		sendErrorResponse(w, "You can't delete your own account", http.StatusConflict)
 		return
 	}
 
-	if !exists {
+	// Check if user exists
+	before, err := fetchUser(config.DB, id)
+	if err == sql.ErrNoRows {
 		sendErrorResponse(w, "User not found", http.StatusNotFound)
 		return
+	} else if err != nil {
+		sendErrorResponse(w, "Failed to check user", http.StatusInternalServerError)
+		return
 	}
 
 	// Delete user
-	_, err = config.DB.Exec("DELETE FROM users WHERE id = ?", id)
+	tx, err := config.DB.Begin()
+	if err != nil {
+		sendErrorResponse(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	auth, _ := currentAuth(r)
+	if _, err = tx.Exec("DELETE FROM users WHERE id = ?", id); err == nil {
+		err = audit.Record(tx, audit.Entry{
+			ActorID: auth.UserID, Action: audit.Delete, Entity: audit.User, EntityID: id, Before: before,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		sendErrorResponse(w, "Failed to delete user", http.StatusInternalServerError)
 		return
@@ -286,6 +382,129 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 }
 
 // Helper functions
+
+// fetchUser loads a user's profile and account flags, without the password
+func fetchUser(q queryer, id int) (models.User, error) {
+	var u models.User
+	var verifiedAt, disabledAt sql.NullTime
+	err := q.QueryRow(`
+		SELECT id, fname, lname, email, email_verified_at, is_admin, disabled_at, must_reset_password, created_at
+		FROM users WHERE id = ?
+	`, id).Scan(&u.ID, &u.FName, &u.LName, &u.Email, &verifiedAt, &u.IsAdmin, &disabledAt, &u.MustResetPassword, &u.CreatedAt)
+	u.EmailVerifiedAt = nullTimePtr(verifiedAt)
+	u.DisabledAt = nullTimePtr(disabledAt)
+	return u, err
+}
+
+// insertUser creates a user and records it in the audit log in the same
+// transaction. An actorID of 0 means the user registered themselves.
+func insertUser(actorID int, u models.UserCreate, hashedPassword string) (int64, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := tx.Insert(
+		"INSERT INTO users (fname, lname, email, pwd) VALUES (?, ?, ?, ?)", u.FName, u.LName, u.Email, hashedPassword,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if actorID == 0 {
+		actorID = int(id)
+	}
+	if err := audit.Record(tx, audit.Entry{
+		ActorID: actorID, Action: audit.Create, Entity: audit.User, EntityID: int(id),
+		After: models.User{FName: u.FName, LName: u.LName, Email: u.Email},
+	}); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// changeUser runs an update on one user by the signed-in user and records it
+// in the audit log in the same transaction, comparing before with the row as
+// it is afterwards, which it returns. With revoke set it also ends all the
+// user's sessions.
+func changeUser(r *http.Request, before models.User, revoke bool, query string, args ...interface{}) (models.User, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		return models.User{}, err
+	}
+	if revoke {
+		if _, err := tx.Exec(
+			"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", time.Now().UTC(), before.ID,
+		); err != nil {
+			return models.User{}, err
+		}
+	}
+	after, err := fetchUser(tx, before.ID)
+	if err != nil {
+		return after, err
+	}
+	auth, _ := currentAuth(r)
+	if err := audit.Record(tx, audit.Entry{
+		ActorID: auth.UserID, Action: audit.Update, Entity: audit.User, EntityID: before.ID, Before: before, After: after,
+	}); err != nil {
+		return after, err
+	}
+	return after, tx.Commit()
+}
+
+// nullTimePtr converts a nullable column to the pointer form used in models
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// dummyPasswordHash returns a hash of nobody's password under the current
+// Argon2 policy, checked against when a login's email has no account. It's
+// made on first use, after the policy is set.
+func dummyPasswordHash() (string, error) {
+	dummyHash.Lock()
+	defer dummyHash.Unlock()
+	if dummyHash.value == "" {
+		password, err := utils.GenerateSecureToken(32)
+		if err != nil {
+			return "", err
+		}
+		if dummyHash.value, err = utils.HashPassword(password); err != nil {
+			return "", err
+		}
+	}
+	return dummyHash.value, nil
+}
+
+var dummyHash struct {
+	sync.Mutex
+	value string
+}
+
+// rehashPassword replaces a user's hash with one using the current Argon2
+// policy. Failures only mean the upgrade waits for the next login. Matching
+// on the old hash avoids overwriting a password changed in the meantime.
+func rehashPassword(userID int, password, oldHash string) {
+	newHash, err := utils.HashPassword(password)
+	if err != nil {
+		log.Printf("Error rehashing password for user %d: %v", userID, err)
+		return
+	}
+
+	if _, err := config.DB.Exec("UPDATE users SET pwd = ? WHERE id = ? AND pwd = ?", newHash, userID, oldHash); err != nil {
+		log.Printf("DB error saving rehashed password for user %d: %v", userID, err)
+		return
+	}
+	log.Printf("🔐 Upgraded password hash for user %d", userID)
+}
+
 func validateUserCreate(u models.UserCreate) error {
 	if len(u.FName) == 0 || len(u.FName) > 30 {
 		return errors.New("first name must be 1-30 characters")
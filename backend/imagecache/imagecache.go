@@ -0,0 +1,151 @@
+// Package imagecache keeps recently served image renditions in memory, least
+// recently used first out, bounded by their total size rather than their
+// number. State lives in the process, which matches the single-server
+// deployment.
+package imagecache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Key identifies one rendition of an image, e.g. its thumbnail
+type Key struct {
+	ImageID   int
+	Rendition string
+}
+
+// Stats is what the cache holds and how well it is doing
+type Stats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	MaxBytes  int64 `json:"max_bytes"`
+}
+
+// Cache is a byte-bounded LRU cache of image data, safe for concurrent use
+type Cache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	order    *list.List // front is most recently used
+	entries  map[Key]*list.Element
+	// epoch moves on with every invalidation, so data read from the
+	// database before one isn't cached after it
+	epoch uint64
+
+	hits, misses, evictions int64
+}
+
+type entry struct {
+	key  Key
+	data []byte
+}
+
+// New holds up to maxBytes of image data; 0 disables caching
+func New(maxBytes int64) *Cache {
+	return &Cache{maxBytes: maxBytes, order: list.New(), entries: map[Key]*list.Element{}}
+}
+
+// Get returns a cached rendition. The bytes are shared, so don't modify them.
+func (c *Cache) Get(key Key) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(el)
+	return el.Value.(*entry).data, true
+}
+
+// Epoch is taken before reading a rendition from the database and handed to
+// Add with it
+func (c *Cache) Epoch() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
+}
+
+// Add caches a rendition read at epoch, evicting the least recently used
+// ones to make room. It's dropped if anything was invalidated since, or if it
+// wouldn't fit at all.
+func (c *Cache) Add(key Key, data []byte, epoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := int64(len(data))
+	if epoch != c.epoch || size > c.maxBytes {
+		return
+	}
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.order.PushFront(&entry{key: key, data: data})
+	c.bytes += size
+	c.shrink()
+}
+
+// RemoveImage drops every rendition of an image
+func (c *Cache) RemoveImage(imageID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch++
+	for key, el := range c.entries {
+		if key.ImageID == imageID {
+			c.remove(el)
+		}
+	}
+}
+
+// Clear drops everything, for changes that touch more images than are
+// worth looking up
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch++
+	c.order.Init()
+	c.entries = map[Key]*list.Element{}
+	c.bytes = 0
+}
+
+// Resize changes the size limit, evicting whatever no longer fits
+func (c *Cache) Resize(maxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxBytes = maxBytes
+	c.shrink()
+}
+
+// Stats reports hits, misses, evictions and current use
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{
+		Hits: c.hits, Misses: c.misses, Evictions: c.evictions,
+		Entries: len(c.entries), Bytes: c.bytes, MaxBytes: c.maxBytes,
+	}
+}
+
+// shrink evicts from the back until the cache is within its limit
+func (c *Cache) shrink() {
+	for c.bytes > c.maxBytes {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.order.Remove(el).(*entry)
+	delete(c.entries, e.key)
+	c.bytes -= int64(len(e.data))
+}
@@ -0,0 +1,79 @@
+// Package mailer sends the API's transactional email (password resets,
+// email verification) through SMTP, or to files/the log during development.
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// format renders msg as an RFC 5322 message
+func (msg Message) format(from string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// LogMailer writes messages to the server log instead of sending them. The
+// tokens in their links are redacted, as anyone who can read the log could
+// otherwise reset passwords with them.
+type LogMailer struct{}
+
+// linkToken matches the token in reset, verification and invitation links
+var linkToken = regexp.MustCompile(`token=[^&\s]+`)
+
+func (LogMailer) Send(msg Message) error {
+	log.Printf("📧 Mail to %s: %s\n%s", msg.To, msg.Subject, linkToken.ReplaceAllString(msg.Body, "token=[redacted]"))
+	return nil
+}
+
+// FileMailer writes each message as an .eml file in Dir, so development and
+// test setups can read links out of "sent" mail
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000000"), sanitize(msg.To))
+	return os.WriteFile(filepath.Join(m.Dir, name), msg.format(m.From), 0o644)
+}
+
+// sanitize keeps an address usable as part of a file name
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
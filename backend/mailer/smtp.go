@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPMailer sends through an SMTP relay. net/smtp upgrades to STARTTLS when
+// the server offers it and refuses to send credentials over plain text.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) Send(msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") {
+		return errors.New("invalid recipient address")
+	}
+
+	// From may include a display name; the envelope needs the bare address
+	sender, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, sender.Address, []string{msg.To}, msg.format(m.From))
+}
@@ -8,8 +8,11 @@ import (
 	"time"
 
 	"go-art-api/config"
+	"go-art-api/handlers"
 	"go-art-api/routes"
 	"go-art-api/static"
+	"go-art-api/trash"
+	"go-art-api/utils"
 
 	"github.com/gorilla/mux"
 )
@@ -18,10 +21,47 @@ import (
 var staticFiles embed.FS
 
 func main() {
+	// Subcommands (e.g. `go-art-api migrate up`) run and exit without serving
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "admin":
+			runAdmin(os.Args[2:])
+			return
+		default:
+			log.Fatalf("❌ Unknown command %q", os.Args[1])
+		}
+	}
+
 	// Initialize database
 	config.InitDB()
 	defer config.CloseDB()
 
+	// Argon2 policy, and a bound on concurrent hashes (each holds its memory cost)
+	utils.SetArgon2Params(config.Argon2Params())
+	utils.SetHashConcurrency(config.HashConcurrency())
+
+	// Fail now on a bad MAILER rather than when the first email goes out
+	config.Mailer()
+
+	// Recently served images are kept in memory, up to IMAGE_CACHE_MB
+	handlers.SetImageCacheSize(config.ImageCacheBytes())
+
+	// Permanently delete whatever has sat in the trash past its retention
+	trash.Start(config.DB, config.TrashRetention())
+
+	// Images stored before duplicate detection, colour search and placeholders
+	// get their perceptual hashes, palettes and BlurHashes
+	go handlers.BackfillImages()
+
 	// Setup router
 	r := mux.NewRouter()
 
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"go-art-api/config"
+	"go-art-api/migrations"
+)
+
+const migrateUsage = `usage: go-art-api migrate <command>
+
+commands:
+  status        list migrations and whether they are applied
+  up            apply all pending migrations
+  down [n]      revert the last n migrations (default 1)
+  to <version>  migrate up or down to the given version (0 reverts everything)`
+
+// runMigrate handles the `migrate` subcommand
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Println(migrateUsage)
+		os.Exit(2)
+	}
+
+	config.OpenDB()
+	defer config.CloseDB()
+
+	switch args[0] {
+	case "status":
+		printMigrationStatus()
+
+	case "up":
+		applied, err := migrations.Up(config.DB.DB, config.DB.Dialect)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		log.Printf("✅ Applied %d migration(s)", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps = parseMigrateArg(args[1])
+		}
+		reverted, err := migrations.Down(config.DB.DB, config.DB.Dialect, steps)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		log.Printf("✅ Reverted %d migration(s)", reverted)
+
+	case "to":
+		if len(args) < 2 {
+			fmt.Println(migrateUsage)
+			os.Exit(2)
+		}
+		changed, err := migrations.To(config.DB.DB, config.DB.Dialect, parseMigrateArg(args[1]))
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		log.Printf("✅ Ran %d migration(s)", changed)
+
+	default:
+		fmt.Println(migrateUsage)
+		os.Exit(2)
+	}
+}
+
+// printMigrationStatus prints one line per known migration
+func printMigrationStatus() {
+	status, err := migrations.Status(config.DB.DB, config.DB.Dialect)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	current, err := migrations.CurrentVersion(config.DB.DB)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	fmt.Printf("Current version: %d\n\n", current)
+	for _, s := range status {
+		state := "pending"
+		if s.Applied {
+			state = "applied " + s.AppliedAt
+		}
+		fmt.Printf("  %04d  %-30s %s\n", s.Version, s.Name, state)
+	}
+
+	if err := migrations.CheckCompatible(config.DB.DB, config.DB.Dialect); err != nil {
+		fmt.Printf("\n⚠️  %v\n", err)
+	}
+}
+
+// parseMigrateArg parses a non-negative integer argument or exits
+func parseMigrateArg(arg string) int {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 {
+		fmt.Printf("invalid number: %q\n\n%s\n", arg, migrateUsage)
+		os.Exit(2)
+	}
+	return n
+}
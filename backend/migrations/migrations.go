@@ -0,0 +1,376 @@
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-art-api/dialect"
+)
+
+// Migration files live in one directory per dialect (mysql/, postgres/, sqlite/) and
+// are named NNNN_description.up.sql and NNNN_description.down.sql. Versions
+// must be unique and increasing, and each dialect keeps the same numbering.
+//
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// ErrSchemaTooNew is returned when the database has migrations applied that
+// this binary doesn't know about (i.e. it was migrated by a newer release).
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a single numbered schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// StatusEntry describes whether a known migration has been applied
+type StatusEntry struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt string
+}
+
+// Load reads and orders the embedded migrations for a dialect
+func Load(d dialect.Dialect) ([]Migration, error) {
+	dir := d.Name()
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file name: %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		body, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names: %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Latest returns the highest migration version known to this binary
+func Latest(d dialect.Dialect) (int, error) {
+	migrations, err := Load(d)
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// CurrentVersion returns the highest applied migration version (0 if none)
+func CurrentVersion(db *sql.DB) (int, error) {
+	if err := ensureTable(db); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// CheckCompatible returns ErrSchemaTooNew if the database is ahead of this binary
+func CheckCompatible(db *sql.DB, d dialect.Dialect) error {
+	current, err := CurrentVersion(db)
+	if err != nil {
+		return err
+	}
+	latest, err := Latest(d)
+	if err != nil {
+		return err
+	}
+	if current > latest {
+		return fmt.Errorf("%w: database is at version %d, binary knows up to %d", ErrSchemaTooNew, current, latest)
+	}
+	return nil
+}
+
+// Status lists every known migration and whether it has been applied
+func Status(db *sql.DB, d dialect.Dialect) ([]StatusEntry, error) {
+	migrations, err := Load(d)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]StatusEntry, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		status = append(status, StatusEntry{
+			Version:   m.Version,
+			Name:      m.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return status, nil
+}
+
+// Up applies every pending migration and returns how many were applied
+func Up(db *sql.DB, d dialect.Dialect) (int, error) {
+	latest, err := Latest(d)
+	if err != nil {
+		return 0, err
+	}
+	return migrateTo(db, d, latest)
+}
+
+// Down reverts the given number of applied migrations, newest first
+func Down(db *sql.DB, d dialect.Dialect, steps int) (int, error) {
+	if err := CheckCompatible(db, d); err != nil {
+		return 0, err
+	}
+
+	migrations, err := Load(d)
+	if err != nil {
+		return 0, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for i := len(migrations) - 1; i >= 0 && reverted < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if err := revert(db, d, m); err != nil {
+			return reverted, err
+		}
+		reverted++
+	}
+	return reverted, nil
+}
+
+// To migrates up or down until the given version is the newest applied one
+func To(db *sql.DB, d dialect.Dialect, version int) (int, error) {
+	return migrateTo(db, d, version)
+}
+
+// migrateTo applies missing migrations <= target and reverts applied ones > target
+func migrateTo(db *sql.DB, d dialect.Dialect, target int) (int, error) {
+	if err := CheckCompatible(db, d); err != nil {
+		return 0, err
+	}
+
+	migrations, err := Load(d)
+	if err != nil {
+		return 0, err
+	}
+	known := false
+	for _, m := range migrations {
+		if m.Version == target {
+			known = true
+		}
+	}
+	if !known && target != 0 {
+		return 0, fmt.Errorf("unknown migration version %d", target)
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; ok && m.Version > target {
+			if err := revert(db, d, m); err != nil {
+				return changed, err
+			}
+			changed++
+		}
+	}
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; !ok && m.Version <= target {
+			if err := apply(db, d, m); err != nil {
+				return changed, err
+			}
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// apply runs a migration's up statements and records it
+func apply(db *sql.DB, d dialect.Dialect, m Migration) error {
+	log.Printf("⬆️  Applying migration %04d_%s", m.Version, m.Name)
+	return run(db, d, m, m.Up, "up",
+		"INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name)
+}
+
+// revert runs a migration's down statements and removes its record
+func revert(db *sql.DB, d dialect.Dialect, m Migration) error {
+	if m.Down == "" {
+		return fmt.Errorf("migration %04d_%s has no down file", m.Version, m.Name)
+	}
+	log.Printf("⬇️  Reverting migration %04d_%s", m.Version, m.Name)
+	return run(db, d, m, m.Down, "down",
+		"DELETE FROM schema_migrations WHERE version = ?", m.Version)
+}
+
+// run executes a migration script and its bookkeeping statement. Dialects with
+// transactional DDL get both in one transaction; MySQL commits DDL implicitly,
+// so there is no point wrapping it.
+func run(db *sql.DB, d dialect.Dialect, m Migration, script, direction, record string, args ...interface{}) error {
+	var q execer = db
+	var tx *sql.Tx
+	if d.TransactionalDDL() {
+		var err error
+		if tx, err = db.Begin(); err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		q = tx
+	}
+
+	for _, stmt := range splitStatements(script) {
+		if _, err := q.Exec(stmt); err != nil {
+			return fmt.Errorf("migration %04d_%s %s: %w\nStatement: %s", m.Version, m.Name, direction, err, stmt)
+		}
+	}
+	if _, err := q.Exec(d.Rebind(record), args...); err != nil {
+		return err
+	}
+
+	if tx != nil {
+		return tx.Commit()
+	}
+	return nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// splitStatements splits a script on semicolons that end a line, dropping
+// comment-only chunks. Good enough for our own migration files.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); hasCode(stmt) {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.Split(script, "\n") {
+		code := line
+		if idx := strings.Index(code, "--"); idx >= 0 {
+			code = code[:idx]
+		}
+		code = strings.TrimSpace(code)
+
+		if strings.HasSuffix(code, ";") {
+			current.WriteString(strings.TrimSuffix(code, ";"))
+			flush()
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+	}
+	flush()
+
+	return statements
+}
+
+// hasCode reports whether a chunk contains anything besides comments and whitespace
+func hasCode(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureTable creates the schema_migrations bookkeeping table
+func ensureTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+// appliedVersions returns applied versions mapped to when they were applied
+func appliedVersions(db *sql.DB) (map[int]string, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
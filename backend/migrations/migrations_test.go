@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"empty", "", nil},
+		{"only comments", "-- nothing to do\n\n-- really\n", nil},
+		{"one", "CREATE TABLE a (id INT);", []string{"CREATE TABLE a (id INT)"}},
+		{"no trailing semicolon", "DROP TABLE a", []string{"DROP TABLE a"}},
+		{
+			"several",
+			"CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			[]string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"},
+		},
+		{
+			"multi-line",
+			"CREATE TABLE a (\n    id INT,\n    name TEXT\n);",
+			[]string{"CREATE TABLE a (\n    id INT,\n    name TEXT\n)"},
+		},
+		{
+			"comment after semicolon",
+			"ALTER TABLE a ADD b INT; -- for the listing\nDROP TABLE c;",
+			[]string{"ALTER TABLE a ADD b INT", "DROP TABLE c"},
+		},
+		{
+			"leading comment kept with its statement",
+			"-- Artists\nCREATE TABLE artists (id INT);",
+			[]string{"-- Artists\nCREATE TABLE artists (id INT)"},
+		},
+		{
+			"semicolon inside a comment",
+			"-- one; two\nSELECT 1;",
+			[]string{"-- one; two\nSELECT 1"},
+		},
+		{
+			"trailing comment dropped",
+			"SELECT 1;\n-- the end\n",
+			[]string{"SELECT 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
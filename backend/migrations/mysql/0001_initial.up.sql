@@ -0,0 +1,95 @@
+-- Baseline schema. Uses IF NOT EXISTS so databases created before migrations
+-- existed (by schema.sql or the old createTablesIfNotExist) can adopt it.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    fname VARCHAR(30) NOT NULL,
+    lname VARCHAR(60) NOT NULL,
+    email VARCHAR(60) NOT NULL UNIQUE,
+    pwd CHAR(128) NOT NULL, -- Argon2id encoded hash
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_users_email (email)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS artists (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(60) NOT NULL,
+    codename VARCHAR(60), -- optional alias for privacy
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS user_artists (
+    user_id INT NOT NULL,
+    artist_id INT NOT NULL,
+    PRIMARY KEY(user_id, artist_id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(artist_id) REFERENCES artists(id) ON DELETE CASCADE,
+    INDEX idx_user_artists_artist_id (artist_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS artworks (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    artist_id INT NOT NULL,
+    grade VARCHAR(20),
+    school VARCHAR(30),
+    title VARCHAR(100),
+    description VARCHAR(500),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(artist_id) REFERENCES artists(id) ON DELETE CASCADE,
+    INDEX idx_artworks_artist_id (artist_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS mediums (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(60) NOT NULL UNIQUE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS artworks_mediums (
+    artwork_id INT NOT NULL,
+    medium_id INT NOT NULL,
+    PRIMARY KEY(artwork_id, medium_id),
+    FOREIGN KEY(artwork_id) REFERENCES artworks(id) ON DELETE CASCADE,
+    FOREIGN KEY(medium_id) REFERENCES mediums(id) ON DELETE CASCADE,
+    INDEX idx_artworks_mediums_medium_id (medium_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS images (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    artwork_id INT NOT NULL,
+    url VARCHAR(255),
+    original_mime VARCHAR(50) NOT NULL, -- e.g., 'image/png', 'image/gif'
+    thumb BLOB,
+    image MEDIUMBLOB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(artwork_id) REFERENCES artworks(id) ON DELETE CASCADE,
+    UNIQUE INDEX idx_images_artwork_id (artwork_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Collates together all the artist/artwork/medium data + a thumbnail and a link.
+CREATE OR REPLACE VIEW all_artwork_data AS
+SELECT
+    a.id AS artwork_id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    COALESCE(ar.codename, ar.name) AS artist_name,
+    i.url,
+    i.thumb, -- BLOB thumbnail
+    GROUP_CONCAT(m.name ORDER BY m.name SEPARATOR ', ') AS mediums
+FROM artworks a
+JOIN artists ar ON a.artist_id = ar.id
+LEFT JOIN images i ON a.id = i.artwork_id
+LEFT JOIN artworks_mediums am ON a.id = am.artwork_id
+LEFT JOIN mediums m ON am.medium_id = m.id
+GROUP BY
+    a.id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    ar.codename,
+    ar.name,
+    i.url,
+    i.thumb
+ORDER BY a.id;
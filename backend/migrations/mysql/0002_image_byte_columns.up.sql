@@ -0,0 +1,6 @@
+-- Byte accounting per rendition, used for family storage quotas.
+ALTER TABLE images
+    ADD COLUMN thumb_bytes INT NOT NULL DEFAULT 0,
+    ADD COLUMN image_bytes INT NOT NULL DEFAULT 0;
+
+UPDATE images SET thumb_bytes = COALESCE(LENGTH(thumb), 0), image_bytes = LENGTH(image);
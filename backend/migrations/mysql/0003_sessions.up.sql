@@ -0,0 +1,18 @@
+-- Login sessions. Each holds a rotating refresh token, stored as a SHA-256
+-- hash; previous_hash catches reuse of an already-rotated token.
+CREATE TABLE sessions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    refresh_hash CHAR(64) NOT NULL,
+    previous_hash CHAR(64),
+    user_agent VARCHAR(255),
+    ip VARCHAR(45),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE INDEX idx_sessions_refresh_hash (refresh_hash),
+    INDEX idx_sessions_previous_hash (previous_hash),
+    INDEX idx_sessions_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
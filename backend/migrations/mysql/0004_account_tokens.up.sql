@@ -0,0 +1,16 @@
+-- Single-use tokens for password reset and email verification, stored as
+-- SHA-256 hashes, plus when each user's email address was verified.
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP NULL;
+
+CREATE TABLE account_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    purpose VARCHAR(20) NOT NULL, -- 'password_reset' or 'verify_email'
+    token_hash CHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE INDEX idx_account_tokens_token_hash (token_hash),
+    INDEX idx_account_tokens_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
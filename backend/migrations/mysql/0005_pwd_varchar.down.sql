@@ -0,0 +1 @@
+ALTER TABLE users MODIFY pwd CHAR(128) NOT NULL;
@@ -0,0 +1,3 @@
+-- Encoded Argon2id hashes vary in length with their parameters, so store
+-- them as VARCHAR with room for stronger settings.
+ALTER TABLE users MODIFY pwd VARCHAR(255) NOT NULL;
@@ -0,0 +1,4 @@
+-- Each user's role for an artist: owner (manages members), editor (uploads
+-- and edits artwork) or viewer (browses). Existing links had full access,
+-- so they become owners.
+ALTER TABLE user_artists ADD COLUMN role VARCHAR(10) NOT NULL DEFAULT 'owner';
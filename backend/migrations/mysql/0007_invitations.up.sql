@@ -0,0 +1,27 @@
+-- Email invitations to one or more artists. The token is stored as a SHA-256
+-- hash; accepting links the accepting user with each artist's role.
+CREATE TABLE invitations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    email VARCHAR(60) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    invited_by INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP NULL,
+    accepted_by INT NULL,
+    cancelled_at TIMESTAMP NULL,
+    FOREIGN KEY(invited_by) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(accepted_by) REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE INDEX idx_invitations_token_hash (token_hash),
+    INDEX idx_invitations_email (email)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE invitation_artists (
+    invitation_id INT NOT NULL,
+    artist_id INT NOT NULL,
+    role VARCHAR(10) NOT NULL,
+    PRIMARY KEY(invitation_id, artist_id),
+    FOREIGN KEY(invitation_id) REFERENCES invitations(id) ON DELETE CASCADE,
+    FOREIGN KEY(artist_id) REFERENCES artists(id) ON DELETE CASCADE,
+    INDEX idx_invitation_artists_artist_id (artist_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
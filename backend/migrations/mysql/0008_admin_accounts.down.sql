@@ -0,0 +1,4 @@
+ALTER TABLE users
+    DROP COLUMN is_admin,
+    DROP COLUMN disabled_at,
+    DROP COLUMN must_reset_password;
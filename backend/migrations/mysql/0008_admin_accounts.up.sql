@@ -0,0 +1,5 @@
+-- Site administrators, disabled accounts, and admin-forced password resets.
+ALTER TABLE users
+    ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN disabled_at TIMESTAMP NULL,
+    ADD COLUMN must_reset_password BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,15 @@
+-- Who changed what. No foreign keys: entries must outlive the users, artists
+-- and artworks they describe. changes holds a JSON before/after diff.
+CREATE TABLE audit_log (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    actor_id INT NULL,
+    action VARCHAR(10) NOT NULL,
+    entity VARCHAR(20) NOT NULL,
+    entity_id INT NOT NULL,
+    artist_id INT NULL,
+    changes TEXT NULL,
+    INDEX idx_audit_log_artist_id (artist_id, id),
+    INDEX idx_audit_log_entity (entity, entity_id),
+    INDEX idx_audit_log_actor_id (actor_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,35 @@
+-- Soft delete: deleting an artist, artwork or image moves it to the trash.
+-- Trashed rows are hidden everywhere, including all_artwork_data, and are
+-- purged for good after the retention period.
+ALTER TABLE artists ADD COLUMN deleted_at TIMESTAMP NULL, ADD INDEX idx_artists_deleted_at (deleted_at);
+ALTER TABLE artworks ADD COLUMN deleted_at TIMESTAMP NULL, ADD INDEX idx_artworks_deleted_at (deleted_at);
+ALTER TABLE images ADD COLUMN deleted_at TIMESTAMP NULL, ADD INDEX idx_images_deleted_at (deleted_at);
+
+CREATE OR REPLACE VIEW all_artwork_data AS
+SELECT
+    a.id AS artwork_id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    COALESCE(ar.codename, ar.name) AS artist_name,
+    i.url,
+    i.thumb, -- BLOB thumbnail
+    GROUP_CONCAT(m.name ORDER BY m.name SEPARATOR ', ') AS mediums
+FROM artworks a
+JOIN artists ar ON a.artist_id = ar.id
+LEFT JOIN images i ON a.id = i.artwork_id AND i.deleted_at IS NULL
+LEFT JOIN artworks_mediums am ON a.id = am.artwork_id
+LEFT JOIN mediums m ON am.medium_id = m.id
+WHERE a.deleted_at IS NULL AND ar.deleted_at IS NULL
+GROUP BY
+    a.id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    ar.codename,
+    ar.name,
+    i.url,
+    i.thumb
+ORDER BY a.id;
@@ -0,0 +1,22 @@
+-- Edit history: every change to an artwork first saves how it looked before.
+-- Image columns are only filled when that change replaced the image; a
+-- revision without them had the same image as the next one that has them
+-- (or, failing that, the current image).
+CREATE TABLE artwork_revisions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    artwork_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    actor_id INT NULL,
+    grade VARCHAR(20),
+    school VARCHAR(30),
+    title VARCHAR(100),
+    description VARCHAR(500),
+    original_mime VARCHAR(50) NULL,
+    thumb BLOB NULL,
+    image MEDIUMBLOB NULL,
+    thumb_bytes INT NOT NULL DEFAULT 0,
+    image_bytes INT NOT NULL DEFAULT 0,
+    FOREIGN KEY(artwork_id) REFERENCES artworks(id) ON DELETE CASCADE,
+    FOREIGN KEY(actor_id) REFERENCES users(id) ON DELETE SET NULL,
+    INDEX idx_artwork_revisions_artwork_id (artwork_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,2 @@
+ALTER TABLE artwork_revisions DROP COLUMN edits;
+ALTER TABLE images DROP COLUMN edits, DROP COLUMN original_bytes, DROP COLUMN original;
@@ -0,0 +1,8 @@
+-- Non-destructive editing: the uploaded file is kept as it came, and edits
+-- (rotate, flip, perspective, crop) are stored as JSON and applied to it to
+-- regenerate the renditions. Revisions keep the edits that went with their image.
+ALTER TABLE images
+    ADD COLUMN original MEDIUMBLOB NULL,
+    ADD COLUMN original_bytes INT NOT NULL DEFAULT 0,
+    ADD COLUMN edits TEXT NULL;
+ALTER TABLE artwork_revisions ADD COLUMN edits TEXT NULL;
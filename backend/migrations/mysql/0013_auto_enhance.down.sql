@@ -0,0 +1,6 @@
+ALTER TABLE images
+    DROP COLUMN enhanced_image_bytes,
+    DROP COLUMN enhanced_thumb_bytes,
+    DROP COLUMN enhanced_image,
+    DROP COLUMN enhanced_thumb;
+ALTER TABLE artworks DROP COLUMN auto_enhance;
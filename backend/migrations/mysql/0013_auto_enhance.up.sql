@@ -0,0 +1,9 @@
+-- Auto-enhance: a second pair of renditions with the paper found, straightened,
+-- white-balanced and given a little more contrast, and a per-artwork toggle
+-- choosing which pair is served.
+ALTER TABLE artworks ADD COLUMN auto_enhance BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE images
+    ADD COLUMN enhanced_thumb BLOB NULL,
+    ADD COLUMN enhanced_image MEDIUMBLOB NULL,
+    ADD COLUMN enhanced_thumb_bytes INT NOT NULL DEFAULT 0,
+    ADD COLUMN enhanced_image_bytes INT NOT NULL DEFAULT 0;
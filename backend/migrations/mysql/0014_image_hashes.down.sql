@@ -0,0 +1 @@
+ALTER TABLE images DROP INDEX idx_images_phash, DROP COLUMN phash;
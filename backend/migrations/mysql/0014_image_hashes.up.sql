@@ -0,0 +1,4 @@
+-- Perceptual hashes (64-bit dHash, stored signed) for spotting the same drawing
+-- uploaded twice. Near matches are found by comparing bits in Go, so the index
+-- made here went unused (0020 drops it).
+ALTER TABLE images ADD COLUMN phash BIGINT NULL, ADD INDEX idx_images_phash (phash);
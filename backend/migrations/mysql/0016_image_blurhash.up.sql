@@ -0,0 +1,34 @@
+-- BlurHash placeholders (https://blurha.sh, from the thumbnail) so listings
+-- can show something straight away and fetch thumbnails lazily.
+ALTER TABLE images ADD COLUMN blurhash VARCHAR(64) NULL;
+
+CREATE OR REPLACE VIEW all_artwork_data AS
+SELECT
+    a.id AS artwork_id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    COALESCE(ar.codename, ar.name) AS artist_name,
+    i.url,
+    i.thumb, -- BLOB thumbnail
+    GROUP_CONCAT(m.name ORDER BY m.name SEPARATOR ', ') AS mediums,
+    i.blurhash -- placeholder for the lazily loaded thumbnail
+FROM artworks a
+JOIN artists ar ON a.artist_id = ar.id
+LEFT JOIN images i ON a.id = i.artwork_id AND i.deleted_at IS NULL
+LEFT JOIN artworks_mediums am ON a.id = am.artwork_id
+LEFT JOIN mediums m ON am.medium_id = m.id
+WHERE a.deleted_at IS NULL AND ar.deleted_at IS NULL
+GROUP BY
+    a.id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    ar.codename,
+    ar.name,
+    i.url,
+    i.thumb,
+    i.blurhash
+ORDER BY a.id;
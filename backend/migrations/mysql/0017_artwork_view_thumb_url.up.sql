@@ -0,0 +1,35 @@
+-- Listings get the image ID and thumbnail URL instead of the thumbnail
+-- itself, which also stops grouping by a BLOB.
+CREATE OR REPLACE VIEW all_artwork_data AS
+SELECT
+    a.id AS artwork_id,
+    a.artist_id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    COALESCE(ar.codename, ar.name) AS artist_name,
+    i.url,
+    i.id AS image_id,
+    CONCAT('/api/artworks/images/', i.id, '/thumb') AS thumb_url, -- fetched lazily
+    i.blurhash, -- placeholder until the thumbnail loads
+    GROUP_CONCAT(m.name ORDER BY m.name SEPARATOR ', ') AS mediums
+FROM artworks a
+JOIN artists ar ON a.artist_id = ar.id
+LEFT JOIN images i ON a.id = i.artwork_id AND i.deleted_at IS NULL
+LEFT JOIN artworks_mediums am ON a.id = am.artwork_id
+LEFT JOIN mediums m ON am.medium_id = m.id
+WHERE a.deleted_at IS NULL AND ar.deleted_at IS NULL
+GROUP BY
+    a.id,
+    a.artist_id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    ar.codename,
+    ar.name,
+    i.url,
+    i.id,
+    i.blurhash
+ORDER BY a.id;
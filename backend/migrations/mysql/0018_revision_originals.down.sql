@@ -0,0 +1 @@
+ALTER TABLE artwork_revisions DROP COLUMN original_bytes, DROP COLUMN original;
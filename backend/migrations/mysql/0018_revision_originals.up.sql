@@ -0,0 +1,5 @@
+-- Revisions keep the original that went with their image, so reverting to
+-- one restores what later edits start from as well as the renditions.
+ALTER TABLE artwork_revisions
+    ADD COLUMN original MEDIUMBLOB NULL,
+    ADD COLUMN original_bytes INT NOT NULL DEFAULT 0;
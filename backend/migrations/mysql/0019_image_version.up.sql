@@ -0,0 +1,38 @@
+-- Images get a version that goes up whenever what they serve changes, and
+-- thumbnail URLs carry it, so browsers can keep a thumbnail until it does.
+ALTER TABLE images ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE VIEW all_artwork_data AS
+SELECT
+    a.id AS artwork_id,
+    a.artist_id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    COALESCE(ar.codename, ar.name) AS artist_name,
+    i.url,
+    i.id AS image_id,
+    CONCAT('/api/artworks/images/', i.id, '/thumb?v=', i.version) AS thumb_url, -- fetched lazily, cached until the image changes
+    i.blurhash, -- placeholder until the thumbnail loads
+    GROUP_CONCAT(m.name ORDER BY m.name SEPARATOR ', ') AS mediums
+FROM artworks a
+JOIN artists ar ON a.artist_id = ar.id
+LEFT JOIN images i ON a.id = i.artwork_id AND i.deleted_at IS NULL
+LEFT JOIN artworks_mediums am ON a.id = am.artwork_id
+LEFT JOIN mediums m ON am.medium_id = m.id
+WHERE a.deleted_at IS NULL AND ar.deleted_at IS NULL
+GROUP BY
+    a.id,
+    a.artist_id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    ar.codename,
+    ar.name,
+    i.url,
+    i.id,
+    i.version,
+    i.blurhash
+ORDER BY a.id;
@@ -0,0 +1 @@
+ALTER TABLE images ADD INDEX idx_images_phash (phash);
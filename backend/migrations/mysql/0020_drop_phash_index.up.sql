@@ -0,0 +1,3 @@
+-- Duplicates are found by comparing hash bits in Go, never by looking up
+-- phash, so its index only slowed writes.
+ALTER TABLE images DROP INDEX idx_images_phash;
@@ -0,0 +1,3 @@
+ALTER TABLE images
+    DROP COLUMN thumb_bytes,
+    DROP COLUMN image_bytes;
@@ -0,0 +1,6 @@
+-- Byte accounting per rendition, used for family storage quotas.
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS thumb_bytes INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS image_bytes INT NOT NULL DEFAULT 0;
+
+UPDATE images SET thumb_bytes = COALESCE(OCTET_LENGTH(thumb), 0), image_bytes = OCTET_LENGTH(image);
@@ -0,0 +1,16 @@
+-- Login sessions. Each holds a rotating refresh token, stored as a SHA-256
+-- hash; previous_hash catches reuse of an already-rotated token.
+CREATE TABLE sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_hash CHAR(64) NOT NULL UNIQUE,
+    previous_hash CHAR(64),
+    user_agent VARCHAR(255),
+    ip VARCHAR(45),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL
+);
+CREATE INDEX idx_sessions_previous_hash ON sessions(previous_hash);
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
@@ -0,0 +1 @@
+ALTER TABLE users ALTER COLUMN pwd TYPE CHAR(128);
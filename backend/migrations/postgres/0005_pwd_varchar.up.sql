@@ -0,0 +1,4 @@
+-- Encoded Argon2id hashes vary in length with their parameters, so store
+-- them as VARCHAR with room for stronger settings. Casting from CHAR also
+-- drops the padding PostgreSQL adds to shorter hashes.
+ALTER TABLE users ALTER COLUMN pwd TYPE VARCHAR(255);
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS is_admin,
+    DROP COLUMN IF EXISTS disabled_at,
+    DROP COLUMN IF EXISTS must_reset_password;
@@ -0,0 +1,5 @@
+-- Site administrators, disabled accounts, and admin-forced password resets.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP NULL,
+    ADD COLUMN IF NOT EXISTS must_reset_password BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,15 @@
+-- Who changed what. No foreign keys: entries must outlive the users, artists
+-- and artworks they describe. changes holds a JSON before/after diff.
+CREATE TABLE audit_log (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    actor_id INT NULL,
+    action VARCHAR(10) NOT NULL,
+    entity VARCHAR(20) NOT NULL,
+    entity_id INT NOT NULL,
+    artist_id INT NULL,
+    changes TEXT NULL
+);
+CREATE INDEX idx_audit_log_artist_id ON audit_log(artist_id, id);
+CREATE INDEX idx_audit_log_entity ON audit_log(entity, entity_id);
+CREATE INDEX idx_audit_log_actor_id ON audit_log(actor_id);
@@ -0,0 +1,32 @@
+-- The view has to stop using deleted_at before the columns can go
+CREATE OR REPLACE VIEW all_artwork_data AS
+SELECT
+    a.id AS artwork_id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    COALESCE(ar.codename, ar.name) AS artist_name,
+    i.url,
+    i.thumb, -- BYTEA thumbnail
+    STRING_AGG(m.name, ', ' ORDER BY m.name) AS mediums
+FROM artworks a
+JOIN artists ar ON a.artist_id = ar.id
+LEFT JOIN images i ON a.id = i.artwork_id
+LEFT JOIN artworks_mediums am ON a.id = am.artwork_id
+LEFT JOIN mediums m ON am.medium_id = m.id
+GROUP BY
+    a.id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    ar.codename,
+    ar.name,
+    i.url,
+    i.thumb
+ORDER BY a.id;
+
+ALTER TABLE images DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE artworks DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE artists DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,20 @@
+-- Edit history: every change to an artwork first saves how it looked before.
+-- Image columns are only filled when that change replaced the image; a
+-- revision without them had the same image as the next one that has them
+-- (or, failing that, the current image).
+CREATE TABLE artwork_revisions (
+    id SERIAL PRIMARY KEY,
+    artwork_id INT NOT NULL REFERENCES artworks(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    actor_id INT NULL REFERENCES users(id) ON DELETE SET NULL,
+    grade VARCHAR(20),
+    school VARCHAR(30),
+    title VARCHAR(100),
+    description VARCHAR(500),
+    original_mime VARCHAR(50) NULL,
+    thumb BYTEA NULL,
+    image BYTEA NULL,
+    thumb_bytes INT NOT NULL DEFAULT 0,
+    image_bytes INT NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_artwork_revisions_artwork_id ON artwork_revisions(artwork_id, id);
@@ -0,0 +1,4 @@
+ALTER TABLE artwork_revisions DROP COLUMN IF EXISTS edits;
+ALTER TABLE images DROP COLUMN IF EXISTS edits;
+ALTER TABLE images DROP COLUMN IF EXISTS original_bytes;
+ALTER TABLE images DROP COLUMN IF EXISTS original;
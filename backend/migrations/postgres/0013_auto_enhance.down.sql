@@ -0,0 +1,5 @@
+ALTER TABLE images DROP COLUMN IF EXISTS enhanced_image_bytes;
+ALTER TABLE images DROP COLUMN IF EXISTS enhanced_thumb_bytes;
+ALTER TABLE images DROP COLUMN IF EXISTS enhanced_image;
+ALTER TABLE images DROP COLUMN IF EXISTS enhanced_thumb;
+ALTER TABLE artworks DROP COLUMN IF EXISTS auto_enhance;
@@ -0,0 +1,8 @@
+-- Auto-enhance: a second pair of renditions with the paper found, straightened,
+-- white-balanced and given a little more contrast, and a per-artwork toggle
+-- choosing which pair is served.
+ALTER TABLE artworks ADD COLUMN IF NOT EXISTS auto_enhance BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE images ADD COLUMN IF NOT EXISTS enhanced_thumb BYTEA NULL;
+ALTER TABLE images ADD COLUMN IF NOT EXISTS enhanced_image BYTEA NULL;
+ALTER TABLE images ADD COLUMN IF NOT EXISTS enhanced_thumb_bytes INT NOT NULL DEFAULT 0;
+ALTER TABLE images ADD COLUMN IF NOT EXISTS enhanced_image_bytes INT NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- Perceptual hashes (64-bit dHash, stored signed) for spotting the same drawing
+-- uploaded twice. Near matches are found by comparing bits in Go, so the index
+-- made here went unused (0020 drops it).
+ALTER TABLE images ADD COLUMN IF NOT EXISTS phash BIGINT NULL;
+CREATE INDEX IF NOT EXISTS idx_images_phash ON images(phash);
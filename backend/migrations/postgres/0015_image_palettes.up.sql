@@ -0,0 +1,3 @@
+-- Dominant colour palettes (JSON [{"hex", "share"}], from the thumbnail) for
+-- searching by colour.
+ALTER TABLE images ADD COLUMN IF NOT EXISTS palette TEXT NULL;
@@ -0,0 +1,33 @@
+-- The view uses the column, so goes back first. CREATE OR REPLACE can't
+-- drop a view column, hence the DROP.
+DROP VIEW IF EXISTS all_artwork_data;
+CREATE VIEW all_artwork_data AS
+SELECT
+    a.id AS artwork_id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    COALESCE(ar.codename, ar.name) AS artist_name,
+    i.url,
+    i.thumb, -- BYTEA thumbnail
+    STRING_AGG(m.name, ', ' ORDER BY m.name) AS mediums
+FROM artworks a
+JOIN artists ar ON a.artist_id = ar.id
+LEFT JOIN images i ON a.id = i.artwork_id AND i.deleted_at IS NULL
+LEFT JOIN artworks_mediums am ON a.id = am.artwork_id
+LEFT JOIN mediums m ON am.medium_id = m.id
+WHERE a.deleted_at IS NULL AND ar.deleted_at IS NULL
+GROUP BY
+    a.id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    ar.codename,
+    ar.name,
+    i.url,
+    i.thumb
+ORDER BY a.id;
+
+ALTER TABLE images DROP COLUMN IF EXISTS blurhash;
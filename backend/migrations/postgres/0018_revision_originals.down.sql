@@ -0,0 +1,2 @@
+ALTER TABLE artwork_revisions DROP COLUMN IF EXISTS original_bytes;
+ALTER TABLE artwork_revisions DROP COLUMN IF EXISTS original;
@@ -0,0 +1,8 @@
+DROP VIEW IF EXISTS all_artwork_data;
+DROP TABLE IF EXISTS images;
+DROP TABLE IF EXISTS artworks_mediums;
+DROP TABLE IF EXISTS mediums;
+DROP TABLE IF EXISTS artworks;
+DROP TABLE IF EXISTS user_artists;
+DROP TABLE IF EXISTS artists;
+DROP TABLE IF EXISTS users;
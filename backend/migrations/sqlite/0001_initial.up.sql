@@ -0,0 +1,88 @@
+-- Baseline schema, kept equivalent to mysql/0001_initial.up.sql.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    fname VARCHAR(30) NOT NULL,
+    lname VARCHAR(60) NOT NULL,
+    email VARCHAR(60) NOT NULL UNIQUE,
+    pwd CHAR(128) NOT NULL, -- Argon2id encoded hash
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS artists (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(60) NOT NULL,
+    codename VARCHAR(60), -- optional alias for privacy
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_artists (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    artist_id INTEGER NOT NULL REFERENCES artists(id) ON DELETE CASCADE,
+    PRIMARY KEY(user_id, artist_id)
+);
+CREATE INDEX IF NOT EXISTS idx_user_artists_artist_id ON user_artists(artist_id);
+
+CREATE TABLE IF NOT EXISTS artworks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    artist_id INTEGER NOT NULL REFERENCES artists(id) ON DELETE CASCADE,
+    grade VARCHAR(20),
+    school VARCHAR(30),
+    title VARCHAR(100),
+    description VARCHAR(500),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_artworks_artist_id ON artworks(artist_id);
+
+CREATE TABLE IF NOT EXISTS mediums (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(60) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS artworks_mediums (
+    artwork_id INTEGER NOT NULL REFERENCES artworks(id) ON DELETE CASCADE,
+    medium_id INTEGER NOT NULL REFERENCES mediums(id) ON DELETE CASCADE,
+    PRIMARY KEY(artwork_id, medium_id)
+);
+CREATE INDEX IF NOT EXISTS idx_artworks_mediums_medium_id ON artworks_mediums(medium_id);
+
+CREATE TABLE IF NOT EXISTS images (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    artwork_id INTEGER NOT NULL UNIQUE REFERENCES artworks(id) ON DELETE CASCADE,
+    url VARCHAR(255),
+    original_mime VARCHAR(50) NOT NULL, -- e.g., 'image/png', 'image/gif'
+    thumb BLOB,
+    image BLOB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Collates together all the artist/artwork/medium data + a thumbnail and a link.
+-- SQLite has no CREATE OR REPLACE VIEW.
+DROP VIEW IF EXISTS all_artwork_data;
+CREATE VIEW all_artwork_data AS
+SELECT
+    a.id AS artwork_id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    COALESCE(ar.codename, ar.name) AS artist_name,
+    i.url,
+    i.thumb, -- BLOB thumbnail
+    GROUP_CONCAT(m.name, ', ' ORDER BY m.name) AS mediums
+FROM artworks a
+JOIN artists ar ON a.artist_id = ar.id
+LEFT JOIN images i ON a.id = i.artwork_id
+LEFT JOIN artworks_mediums am ON a.id = am.artwork_id
+LEFT JOIN mediums m ON am.medium_id = m.id
+GROUP BY
+    a.id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    ar.codename,
+    ar.name,
+    i.url,
+    i.thumb
+ORDER BY a.id;
@@ -0,0 +1,2 @@
+ALTER TABLE images DROP COLUMN thumb_bytes;
+ALTER TABLE images DROP COLUMN image_bytes;
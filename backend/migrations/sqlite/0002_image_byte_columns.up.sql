@@ -0,0 +1,6 @@
+-- Byte accounting per rendition, used for family storage quotas.
+-- SQLite adds one column per ALTER TABLE.
+ALTER TABLE images ADD COLUMN thumb_bytes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE images ADD COLUMN image_bytes INTEGER NOT NULL DEFAULT 0;
+
+UPDATE images SET thumb_bytes = COALESCE(LENGTH(thumb), 0), image_bytes = LENGTH(image);
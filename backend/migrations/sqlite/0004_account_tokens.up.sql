@@ -0,0 +1,14 @@
+-- Single-use tokens for password reset and email verification, stored as
+-- SHA-256 hashes, plus when each user's email address was verified.
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP NULL;
+
+CREATE TABLE account_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(20) NOT NULL, -- 'password_reset' or 'verify_email'
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL
+);
+CREATE INDEX idx_account_tokens_user_id ON account_tokens(user_id);
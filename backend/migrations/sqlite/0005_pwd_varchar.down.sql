@@ -0,0 +1 @@
+-- Nothing to revert, see 0005_pwd_varchar.up.sql.
@@ -0,0 +1,3 @@
+-- MySQL and PostgreSQL widen users.pwd to VARCHAR(255) here. SQLite doesn't
+-- enforce or pad CHAR lengths, so there is nothing to change; the migration
+-- exists to keep version numbers aligned across dialects.
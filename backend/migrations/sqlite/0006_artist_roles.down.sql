@@ -0,0 +1 @@
+ALTER TABLE user_artists DROP COLUMN role;
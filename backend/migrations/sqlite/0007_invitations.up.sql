@@ -0,0 +1,22 @@
+-- Email invitations to one or more artists. The token is stored as a SHA-256
+-- hash; accepting links the accepting user with each artist's role.
+CREATE TABLE invitations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email VARCHAR(60) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    invited_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP NULL,
+    accepted_by INTEGER NULL REFERENCES users(id) ON DELETE SET NULL,
+    cancelled_at TIMESTAMP NULL
+);
+CREATE INDEX idx_invitations_email ON invitations(email);
+
+CREATE TABLE invitation_artists (
+    invitation_id INTEGER NOT NULL REFERENCES invitations(id) ON DELETE CASCADE,
+    artist_id INTEGER NOT NULL REFERENCES artists(id) ON DELETE CASCADE,
+    role VARCHAR(10) NOT NULL,
+    PRIMARY KEY(invitation_id, artist_id)
+);
+CREATE INDEX idx_invitation_artists_artist_id ON invitation_artists(artist_id);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN is_admin;
+ALTER TABLE users DROP COLUMN disabled_at;
+ALTER TABLE users DROP COLUMN must_reset_password;
@@ -0,0 +1,5 @@
+-- Site administrators, disabled accounts, and admin-forced password resets.
+-- SQLite adds one column per ALTER TABLE.
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP NULL;
+ALTER TABLE users ADD COLUMN must_reset_password BOOLEAN NOT NULL DEFAULT FALSE;
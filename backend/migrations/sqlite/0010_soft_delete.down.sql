@@ -0,0 +1,36 @@
+-- The view has to stop using deleted_at before the columns can go
+DROP VIEW IF EXISTS all_artwork_data;
+DROP INDEX IF EXISTS idx_images_deleted_at;
+DROP INDEX IF EXISTS idx_artworks_deleted_at;
+DROP INDEX IF EXISTS idx_artists_deleted_at;
+ALTER TABLE images DROP COLUMN deleted_at;
+ALTER TABLE artworks DROP COLUMN deleted_at;
+ALTER TABLE artists DROP COLUMN deleted_at;
+
+CREATE VIEW all_artwork_data AS
+SELECT
+    a.id AS artwork_id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    COALESCE(ar.codename, ar.name) AS artist_name,
+    i.url,
+    i.thumb, -- BLOB thumbnail
+    GROUP_CONCAT(m.name, ', ' ORDER BY m.name) AS mediums
+FROM artworks a
+JOIN artists ar ON a.artist_id = ar.id
+LEFT JOIN images i ON a.id = i.artwork_id
+LEFT JOIN artworks_mediums am ON a.id = am.artwork_id
+LEFT JOIN mediums m ON am.medium_id = m.id
+GROUP BY
+    a.id,
+    a.grade,
+    a.school,
+    a.title,
+    a.description,
+    ar.codename,
+    ar.name,
+    i.url,
+    i.thumb
+ORDER BY a.id;
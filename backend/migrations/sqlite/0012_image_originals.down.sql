@@ -0,0 +1,4 @@
+ALTER TABLE artwork_revisions DROP COLUMN edits;
+ALTER TABLE images DROP COLUMN edits;
+ALTER TABLE images DROP COLUMN original_bytes;
+ALTER TABLE images DROP COLUMN original;
@@ -0,0 +1,8 @@
+-- Non-destructive editing: the uploaded file is kept as it came, and edits
+-- (rotate, flip, perspective, crop) are stored as JSON and applied to it to
+-- regenerate the renditions. Revisions keep the edits that went with their image.
+-- SQLite adds one column per ALTER TABLE.
+ALTER TABLE images ADD COLUMN original BLOB NULL;
+ALTER TABLE images ADD COLUMN original_bytes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE images ADD COLUMN edits TEXT NULL;
+ALTER TABLE artwork_revisions ADD COLUMN edits TEXT NULL;
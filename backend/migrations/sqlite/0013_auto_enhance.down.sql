@@ -0,0 +1,5 @@
+ALTER TABLE images DROP COLUMN enhanced_image_bytes;
+ALTER TABLE images DROP COLUMN enhanced_thumb_bytes;
+ALTER TABLE images DROP COLUMN enhanced_image;
+ALTER TABLE images DROP COLUMN enhanced_thumb;
+ALTER TABLE artworks DROP COLUMN auto_enhance;
@@ -0,0 +1,9 @@
+-- Auto-enhance: a second pair of renditions with the paper found, straightened,
+-- white-balanced and given a little more contrast, and a per-artwork toggle
+-- choosing which pair is served.
+-- SQLite adds one column per ALTER TABLE.
+ALTER TABLE artworks ADD COLUMN auto_enhance BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE images ADD COLUMN enhanced_thumb BLOB NULL;
+ALTER TABLE images ADD COLUMN enhanced_image BLOB NULL;
+ALTER TABLE images ADD COLUMN enhanced_thumb_bytes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE images ADD COLUMN enhanced_image_bytes INTEGER NOT NULL DEFAULT 0;
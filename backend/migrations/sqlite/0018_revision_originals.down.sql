@@ -0,0 +1,2 @@
+ALTER TABLE artwork_revisions DROP COLUMN original_bytes;
+ALTER TABLE artwork_revisions DROP COLUMN original;
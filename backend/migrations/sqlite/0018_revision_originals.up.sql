@@ -0,0 +1,5 @@
+-- Revisions keep the original that went with their image, so reverting to
+-- one restores what later edits start from as well as the renditions.
+-- SQLite adds one column per ALTER TABLE.
+ALTER TABLE artwork_revisions ADD COLUMN original BLOB NULL;
+ALTER TABLE artwork_revisions ADD COLUMN original_bytes INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_images_phash ON images(phash);
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -13,6 +14,12 @@ type User struct {
 	LName     string    `json:"lname" db:"lname"`
 	Email     string    `json:"email" db:"email"`
 	CreatedAt time.Time `json:"created_at,omitempty" db:"created_at"`
+	// EmailVerifiedAt is nil until the user follows the verification link
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	IsAdmin         bool       `json:"is_admin,omitempty" db:"is_admin"`
+	DisabledAt      *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+	// MustResetPassword blocks login until the user resets their password
+	MustResetPassword bool `json:"must_reset_password,omitempty" db:"must_reset_password"`
 	// Pwd (password hash) is stored in the DB but not included in this public struct
 }
 
@@ -21,7 +28,7 @@ type UserCreate struct {
 	FName    string `json:"fname" validate:"required,min=1,max=30"`
 	LName    string `json:"lname" validate:"required,min=1,max=60"`
 	Email    string `json:"email" validate:"required,email,max=60"`
-	Password string `json:"password" validate:"required,min=8"` // will be hashed to CHAR(60)
+	Password string `json:"password" validate:"required,min=8"` // stored as an Argon2id encoded hash in pwd VARCHAR(255)
 }
 
 // UserLogin is used for authentication
@@ -30,6 +37,62 @@ type UserLogin struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// ProfileUpdate changes the signed-in user's own profile
+type ProfileUpdate struct {
+	FName string `json:"fname" validate:"required,min=1,max=30"`
+	LName string `json:"lname" validate:"required,min=1,max=60"`
+	Email string `json:"email" validate:"required,email,max=60"`
+	// CurrentPassword is required when Email changes
+	CurrentPassword string `json:"current_password,omitempty"`
+}
+
+// PasswordChange changes the signed-in user's password
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8"`
+}
+
+// TokenPair is returned on login and refresh
+type TokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int       `json:"expires_in"` // access token lifetime in seconds
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// RefreshRequest carries a refresh token for /auth/refresh and /auth/logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// PasswordResetRequest asks for a reset link to be emailed
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// PasswordResetConfirm sets a new password with the emailed token
+type PasswordResetConfirm struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// VerifyEmailConfirm carries the token from a verification email
+type VerifyEmailConfirm struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// Session is a signed-in device (Table: sessions)
+type Session struct {
+	ID         int       `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
 // --- Artist and Artwork Models ---
 
 // Artist represents an artist (Table: artists)
@@ -48,9 +111,24 @@ type Artwork struct {
 	School      string    `json:"school,omitempty" validate:"max=30" db:"school"`
 	Title       string    `json:"title,omitempty" validate:"max=100" db:"title"`
 	Description string    `json:"description,omitempty" validate:"max=500" db:"description"`
+	AutoEnhance bool      `json:"auto_enhance,omitempty" db:"auto_enhance"` // serve the auto-enhanced image
 	CreatedAt   time.Time `json:"created_at,omitempty" db:"created_at"`
 }
 
+// ArtworkDetail is an artwork with its current image, as lookups and search return it
+type ArtworkDetail struct {
+	Artwork
+	ImageID  int            `json:"image_id,omitempty"`
+	Palette  []PaletteColor `json:"palette,omitempty"`
+	BlurHash string         `json:"blurhash,omitempty"` // placeholder until /images/{image_id}/thumb loads
+}
+
+// PaletteColor is one of an image's dominant colours
+type PaletteColor struct {
+	Hex   string  `json:"hex"`   // #rrggbb
+	Share float64 `json:"share"` // fraction of the image closest to it
+}
+
 // Image represents the image data for an artwork (Table: images)
 type Image struct {
 	ID           int       `json:"id"`
@@ -59,9 +137,61 @@ type Image struct {
 	OriginalMime string    `json:"mime" validate:"max=50" db:"original_mime"`
 	Thumb        []byte    `json:"thumb,omitempty" db:"thumb"`           // BLOB, max 64KB
 	Image        []byte    `json:"image" validate:"required" db:"image"` // MEDIUMBLOB, full image
+	ThumbBytes   int       `json:"thumb_bytes" db:"thumb_bytes"`
+	ImageBytes   int       `json:"image_bytes" db:"image_bytes"`
 	CreatedAt    time.Time `json:"created_at,omitempty" db:"created_at"`
 }
 
+// ImageEdits is a non-destructive edit of an artwork's original image. The
+// steps run in this order: rotate, flip, perspective, crop. Points and the
+// crop are fractions (0-1) of the image's width and height at that step, so
+// they don't depend on the original's resolution. No edits means the original
+// as uploaded.
+type ImageEdits struct {
+	Rotate         int          `json:"rotate,omitempty"` // clockwise: 0, 90, 180 or 270
+	FlipHorizontal bool         `json:"flip_horizontal,omitempty"`
+	FlipVertical   bool         `json:"flip_vertical,omitempty"`
+	Perspective    []ImagePoint `json:"perspective,omitempty"` // the artwork's corners: top-left, top-right, bottom-right, bottom-left
+	Crop           *ImageCrop   `json:"crop,omitempty"`
+}
+
+// ImagePoint is a position in an image, as fractions of its width and height
+type ImagePoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// ImageCrop is a rectangle in an image, as fractions of its width and height
+type ImageCrop struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// ArtworkUpdate is the request body for changing an artwork's details
+type ArtworkUpdate struct {
+	Grade       string `json:"grade"`
+	School      string `json:"school"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// ArtworkRevision is how an artwork looked before one of its changes (Table: artwork_revisions)
+type ArtworkRevision struct {
+	ID          int       `json:"id"`
+	ArtworkID   int       `json:"artwork_id"`
+	CreatedAt   time.Time `json:"created_at"` // when the change that replaced this happened
+	ActorID     *int      `json:"actor_id"`   // who made that change
+	ActorName   string    `json:"actor_name,omitempty"`
+	Grade       string    `json:"grade,omitempty"`
+	School      string    `json:"school,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	HasImage    bool      `json:"has_image"` // the change also replaced the image, which is kept here
+	ImageBytes  int       `json:"image_bytes,omitempty"`
+}
+
 // Medium represents an art medium (Table: mediums)
 type Medium struct {
 	ID   int    `json:"id"`
@@ -72,8 +202,64 @@ type Medium struct {
 
 // UserArtist represents the many-to-many relationship between users and artists (Table: user_artists)
 type UserArtist struct {
-	UserID   int `json:"user_id" db:"user_id"`
-	ArtistID int `json:"artist_id" db:"artist_id"`
+	UserID   int    `json:"user_id" db:"user_id"`
+	ArtistID int    `json:"artist_id" db:"artist_id"`
+	Role     string `json:"role,omitempty" db:"role"` // owner, editor or viewer
+}
+
+// ArtistAccess is an artist as seen by a linked user, with their role
+type ArtistAccess struct {
+	Artist
+	Role string `json:"role"`
+}
+
+// ArtistMember is a user linked to an artist
+type ArtistMember struct {
+	UserID int    `json:"user_id"`
+	FName  string `json:"fname"`
+	LName  string `json:"lname"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+}
+
+// MemberCreate gives an existing user access to an artist
+type MemberCreate struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=owner editor viewer"`
+}
+
+// InvitationArtist is one artist an invitation grants access to
+type InvitationArtist struct {
+	ArtistID   int    `json:"artist_id"`
+	ArtistName string `json:"artist_name,omitempty"`
+	Role       string `json:"role" validate:"required,oneof=owner editor viewer"`
+}
+
+// InvitationCreate invites someone by email to one or more artists
+type InvitationCreate struct {
+	Email   string             `json:"email" validate:"required,email,max=60"`
+	Artists []InvitationArtist `json:"artists" validate:"required,min=1"`
+}
+
+// InvitationAccept carries the token from an invitation email
+type InvitationAccept struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// Invitation is a pending invitation (Table: invitations)
+type Invitation struct {
+	ID        int                `json:"id"`
+	Email     string             `json:"email"`
+	InvitedBy string             `json:"invited_by"` // inviter's name
+	Artists   []InvitationArtist `json:"artists"`
+	CreatedAt time.Time          `json:"created_at"`
+	ExpiresAt time.Time          `json:"expires_at"`
+	Expired   bool               `json:"expired"`
+}
+
+// RoleUpdate changes a member's role
+type RoleUpdate struct {
+	Role string `json:"role" validate:"required,oneof=owner editor viewer"`
 }
 
 // ArtworkMedium represents the many-to-many relationship between artworks and mediums (Table: artworks_mediums)
@@ -87,16 +273,93 @@ type ArtworkMedium struct {
 // ArtworkView represents the rich view of artwork data from the all_artwork_data VIEW
 type ArtworkView struct {
 	ArtworkID   int    `json:"artwork_id" db:"artwork_id"`
+	ArtistID    int    `json:"artist_id" db:"artist_id"`
 	Grade       string `json:"grade,omitempty" db:"grade"`
 	School      string `json:"school,omitempty" db:"school"`
 	Title       string `json:"title,omitempty" db:"title"`
 	Description string `json:"description,omitempty" db:"description"`
 	ArtistName  string `json:"artist_name" db:"artist_name"` // COALESCE(ar.codename, ar.name)
 	URL         string `json:"url,omitempty" db:"url"`
-	Thumb       []byte `json:"thumb,omitempty" db:"thumb"` // BLOB thumbnail
+	ImageID     int    `json:"image_id,omitempty" db:"image_id"`
+	ThumbURL    string `json:"thumb_url,omitempty" db:"thumb_url"` // fetched lazily, not embedded
+	BlurHash    string `json:"blurhash,omitempty" db:"blurhash"`   // placeholder until the thumbnail loads
 	Mediums     string `json:"mediums,omitempty" db:"mediums"`
 }
 
+// --- Trash Models ---
+
+// TrashItem is a deleted artist, artwork or image that can still be restored
+type TrashItem struct {
+	Type      string    `json:"type"` // artist, artwork or image
+	ID        int       `json:"id"`
+	ArtistID  int       `json:"artist_id"`
+	ArtworkID int       `json:"artwork_id,omitempty"`
+	Name      string    `json:"name,omitempty"` // artist name or artwork title
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"` // when it will be deleted for good
+}
+
+// --- Duplicate Models ---
+
+// DuplicateCluster is a group of artworks whose images look like the same drawing
+type DuplicateCluster struct {
+	Artworks    []DuplicateArtwork `json:"artworks"`
+	MaxDistance int                `json:"max_distance"` // most bits any two hashes in the group differ by (0-64)
+}
+
+// DuplicateArtwork is one artwork in a DuplicateCluster
+type DuplicateArtwork struct {
+	ArtworkID int    `json:"artwork_id"`
+	ArtistID  int    `json:"artist_id"`
+	ImageID   int    `json:"image_id"`
+	Title     string `json:"title,omitempty"`
+}
+
+// --- Audit Models ---
+
+// AuditEntry records one change (Table: audit_log)
+type AuditEntry struct {
+	ID        int             `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	ActorID   *int            `json:"actor_id"` // nil for system and anonymous actions
+	ActorName string          `json:"actor_name,omitempty"`
+	Action    string          `json:"action"` // create, update or delete
+	Entity    string          `json:"entity"`
+	EntityID  int             `json:"entity_id"`
+	ArtistID  *int            `json:"artist_id,omitempty"`
+	Changes   json.RawMessage `json:"changes,omitempty"` // {"field": {"from": ..., "to": ...}}
+}
+
+// --- Storage Models ---
+
+// StorageStats reports storage usage against the configured limits
+type StorageStats struct {
+	ImageCount    int            `json:"image_count"`
+	ThumbBytes    int64          `json:"thumb_bytes"`
+	ImageBytes    int64          `json:"image_bytes"`
+	OriginalBytes int64          `json:"original_bytes"` // uploads as they came, kept for editing
+	EnhancedBytes int64          `json:"enhanced_bytes"` // auto-enhanced renditions
+	RevisionBytes int64          `json:"revision_bytes"` // earlier images kept in edit history
+	TotalBytes    int64          `json:"total_bytes"`
+	DatabaseBytes int64          `json:"database_bytes"` // data + index size reported by the DB
+	LimitBytes    int64          `json:"limit_bytes"`
+	HeadroomBytes int64          `json:"headroom_bytes"`
+	UsedPercent   float64        `json:"used_percent"`
+	FamilyQuota   int64          `json:"family_quota_bytes"`
+	Family        *FamilyStorage `json:"family,omitempty"`
+}
+
+// FamilyStorage reports a single family's usage against its quota
+type FamilyStorage struct {
+	ArtistIDs     []int   `json:"artist_ids"`
+	ImageCount    int     `json:"image_count"`
+	RevisionBytes int64   `json:"revision_bytes"`
+	UsedBytes     int64   `json:"used_bytes"` // images plus revisions
+	QuotaBytes    int64   `json:"quota_bytes"`
+	HeadroomBytes int64   `json:"headroom_bytes"`
+	UsedPercent   float64 `json:"used_percent"`
+}
+
 // --- API Utility Models ---
 
 // APIResponse is a standard API response structure
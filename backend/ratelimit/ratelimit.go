@@ -0,0 +1,175 @@
+// Package ratelimit provides in-memory, per-key request throttling: token
+// buckets for request rates and a lockout tracker for repeated failures.
+// State lives in the process, which matches the single-server deployment.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle entries are dropped
+const sweepInterval = time.Minute
+
+// Limiter is a token bucket per key: up to burst requests at once, refilling
+// at burst per period
+type Limiter struct {
+	mu        sync.Mutex
+	burst     float64
+	perToken  time.Duration
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter allows burst requests per period for each key
+func NewLimiter(burst int, period time.Duration) *Limiter {
+	return &Limiter{
+		burst:    float64(burst),
+		perToken: period / time.Duration(burst),
+		buckets:  map[string]*bucket{},
+	}
+}
+
+// Allow takes a token for key. When none is left it returns false and how
+// long until the next one is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += float64(now.Sub(b.last)) / float64(l.perToken)
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(l.perToken))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep forgets buckets that have refilled completely
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	full := time.Duration(l.burst) * l.perToken
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Lockout counts consecutive failures per key. After threshold failures the
+// key is locked for base, doubling with each further failure up to max.
+// Failures are forgotten after resetAfter without another one.
+type Lockout struct {
+	mu         sync.Mutex
+	threshold  int
+	base       time.Duration
+	max        time.Duration
+	resetAfter time.Duration
+	entries    map[string]*lockEntry
+	lastSweep  time.Time
+}
+
+type lockEntry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// NewLockout creates a lockout tracker
+func NewLockout(threshold int, base, max, resetAfter time.Duration) *Lockout {
+	return &Lockout{
+		threshold:  threshold,
+		base:       base,
+		max:        max,
+		resetAfter: resetAfter,
+		entries:    map[string]*lockEntry{},
+	}
+}
+
+// Locked returns how much longer key is locked out, or 0
+func (l *Lockout) Locked(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+	if remaining := time.Until(e.lockedUntil); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Fail records a failure and returns the lockout it triggered, if any
+func (l *Lockout) Fail(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.lastFailure) > l.resetAfter {
+		e = &lockEntry{}
+		l.entries[key] = e
+	}
+	e.failures++
+	e.lastFailure = now
+
+	if e.failures < l.threshold {
+		return 0
+	}
+	lock := l.base
+	for i := l.threshold; i < e.failures && lock < l.max; i++ {
+		lock *= 2
+	}
+	if lock > l.max {
+		lock = l.max
+	}
+	e.lockedUntil = now.Add(lock)
+	return lock
+}
+
+// Reset clears key's failures, e.g. after a successful login
+func (l *Lockout) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+// sweep forgets keys whose failures have expired
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, e := range l.entries {
+		if now.Sub(e.lastFailure) > l.resetAfter && now.After(e.lockedUntil) {
+			delete(l.entries, key)
+		}
+	}
+}
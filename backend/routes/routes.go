@@ -37,31 +37,65 @@ func SetupRoutes(r *mux.Router) {
 
 // setupUserRoutes defines user-related routes
 func setupUserRoutes(api *mux.Router) {
+	// Managing other accounts is for site admins only
 	users := api.PathPrefix("/users").Subrouter()
+	users.Use(handlers.RequireAuth, handlers.RequireAdmin)
 
 	users.HandleFunc("", handlers.GetUsers).Methods("GET")
 	users.HandleFunc("", handlers.CreateUser).Methods("POST")
 	users.HandleFunc("/{id:[0-9]+}", handlers.GetUserByID).Methods("GET")
 	users.HandleFunc("/{id:[0-9]+}", handlers.UpdateUser).Methods("PUT")
 	users.HandleFunc("/{id:[0-9]+}", handlers.DeleteUser).Methods("DELETE")
+	users.HandleFunc("/{id:[0-9]+}/disable", handlers.DisableUser).Methods("POST")
+	users.HandleFunc("/{id:[0-9]+}/enable", handlers.EnableUser).Methods("POST")
+	users.HandleFunc("/{id:[0-9]+}/force-password-reset", handlers.ForcePasswordReset).Methods("POST")
+	users.HandleFunc("/{user_id:[0-9]+}/artists", handlers.GetUserArtists).Methods("GET")
+
+	// Everyone can manage their own account
+	me := api.PathPrefix("/me").Subrouter()
+	me.Use(handlers.RequireAuth)
+	me.HandleFunc("", handlers.GetMe).Methods("GET")
+	me.HandleFunc("", handlers.UpdateMe).Methods("PUT")
+	me.HandleFunc("/password", handlers.ChangePassword).Methods("PUT")
 
 	// Authentication routes
 	api.HandleFunc("/auth/register", handlers.RegisterUser).Methods("POST")
 	api.HandleFunc("/auth/login", handlers.LoginUser).Methods("POST")
+	api.HandleFunc("/auth/refresh", handlers.RefreshToken).Methods("POST")
+	api.HandleFunc("/auth/logout", handlers.Logout).Methods("POST")
+	api.HandleFunc("/auth/password-reset", handlers.RequestPasswordReset).Methods("POST")
+	api.HandleFunc("/auth/password-reset/confirm", handlers.ConfirmPasswordReset).Methods("POST")
+	api.HandleFunc("/auth/verify-email/confirm", handlers.ConfirmEmailVerification).Methods("POST")
+
+	// Session management for the signed-in user
+	sessions := api.PathPrefix("/auth").Subrouter()
+	sessions.Use(handlers.RequireAuth)
+	sessions.HandleFunc("/logout-all", handlers.LogoutAll).Methods("POST")
+	sessions.HandleFunc("/sessions", handlers.GetSessions).Methods("GET")
+	sessions.HandleFunc("/sessions/{id:[0-9]+}", handlers.RevokeSession).Methods("DELETE")
+	sessions.HandleFunc("/verify-email", handlers.ResendVerificationEmail).Methods("POST")
 }
 
 // setupArtistRoutes defines artist-related routes
 func setupArtistRoutes(api *mux.Router) {
 	artists := api.PathPrefix("/artists").Subrouter()
 
-	artists.HandleFunc("", handlers.GetArtists).Methods("GET")
-	artists.HandleFunc("", handlers.CreateArtist).Methods("POST")
+	artists.Handle("", authed(handlers.GetArtists)).Methods("GET")
+	artists.Handle("", authed(handlers.CreateArtist)).Methods("POST")
 	artists.HandleFunc("/{id:[0-9]+}", handlers.GetArtistByID).Methods("GET")
 	artists.HandleFunc("/{id:[0-9]+}", handlers.UpdateArtist).Methods("PUT")
-	artists.HandleFunc("/{id:[0-9]+}", handlers.DeleteArtist).Methods("DELETE")
+	artists.Handle("/{id:[0-9]+}", authed(handlers.DeleteArtist)).Methods("DELETE")
 
 	// Artist-specific routes
 	artists.HandleFunc("/{id:[0-9]+}/artworks", handlers.GetArtworksByArtist).Methods("GET")
+	artists.Handle("/{id:[0-9]+}/download", authed(handlers.DownloadArtistArchive)).Methods("GET")
+	artists.Handle("/{id:[0-9]+}/portfolio", authed(handlers.GeneratePortfolio)).Methods("GET")
+
+	// Who can see or change an artist's artwork (changes are owner-only)
+	artists.Handle("/{id:[0-9]+}/members", authed(handlers.GetArtistMembers)).Methods("GET")
+	artists.Handle("/{id:[0-9]+}/members", authed(handlers.AddArtistMember)).Methods("POST")
+	artists.Handle("/{id:[0-9]+}/members/{user_id:[0-9]+}", authed(handlers.UpdateArtistMember)).Methods("PUT")
+	artists.Handle("/{id:[0-9]+}/members/{user_id:[0-9]+}", authed(handlers.RemoveArtistMember)).Methods("DELETE")
 }
 
 // setupArtworkRoutes defines artwork-related routes
@@ -69,26 +103,40 @@ func setupArtworkRoutes(api *mux.Router) {
 	artworks := api.PathPrefix("/artworks").Subrouter()
 
 	// NEW ROUTE: Handles POST to /api/artworks for creation + upload
-	artworks.HandleFunc("", handlers.CreateArtworkAndUploadImage).Methods("POST")
+	artworks.Handle("", authed(handlers.CreateArtworkAndUploadImage)).Methods("POST")
 
 	artworks.HandleFunc("", handlers.GetArtworks).Methods("GET")
 	// artworks.HandleFunc("", handlers.CreateArtwork).Methods("POST")
-	artworks.HandleFunc("/{id:[0-9]+}", handlers.GetArtworkByID).Methods("GET")
-	artworks.HandleFunc("/{id:[0-9]+}", handlers.UpdateArtwork).Methods("PUT")
-	artworks.HandleFunc("/{id:[0-9]+}", handlers.DeleteArtwork).Methods("DELETE")
+	artworks.Handle("/{id:[0-9]+}", authed(handlers.GetArtworkByID)).Methods("GET")
+	artworks.Handle("/{id:[0-9]+}", authed(handlers.UpdateArtwork)).Methods("PUT")
+	artworks.Handle("/{id:[0-9]+}", authed(handlers.DeleteArtwork)).Methods("DELETE")
 
 	// Artwork-specific routes
-	artworks.HandleFunc("/view", handlers.GetArtworksView).Methods("GET")
+	artworks.Handle("/view", authed(handlers.GetArtworksView)).Methods("GET")
 	artworks.HandleFunc("/{id:[0-9]+}/mediums", handlers.GetArtworkMediums).Methods("GET")
 	artworks.HandleFunc("/{id:[0-9]+}/mediums", handlers.AddArtworkMedium).Methods("POST")
 	artworks.HandleFunc("/{id:[0-9]+}/mediums/{medium_id:[0-9]+}", handlers.RemoveArtworkMedium).Methods("DELETE")
 
 	// Image Upload
-	artworks.HandleFunc("/{id:[0-9]+}/image", handlers.UploadImage).Methods("POST")
-
-	// Image Retrieval
-	artworks.HandleFunc("/images/{id:[0-9]+}", handlers.GetImage).Methods("GET")
-	artworks.HandleFunc("/images/{id:[0-9]+}/thumb", handlers.GetThumbnail).Methods("GET")
+	artworks.Handle("/{id:[0-9]+}/image", authed(handlers.UploadImage)).Methods("POST")
+	artworks.Handle("/{id:[0-9]+}/image", authed(handlers.DeleteImage)).Methods("DELETE")
+
+	// Non-destructive editing: rotate, flip, perspective and crop, always from the original
+	artworks.Handle("/{id:[0-9]+}/image/edit", authed(handlers.GetImageEdits)).Methods("GET")
+	artworks.Handle("/{id:[0-9]+}/image/edit", authed(handlers.EditArtworkImage)).Methods("POST")
+	artworks.Handle("/{id:[0-9]+}/image/original", authed(handlers.GetOriginalImage)).Methods("GET")
+	artworks.Handle("/{id:[0-9]+}/enhance", authed(handlers.SetAutoEnhance)).Methods("PUT")
+
+	// Edit history: earlier details and images, with diff and revert
+	artworks.Handle("/{id:[0-9]+}/revisions", authed(handlers.GetArtworkRevisions)).Methods("GET")
+	artworks.Handle("/{id:[0-9]+}/revisions/{rev:[0-9]+}/diff", authed(handlers.GetArtworkRevisionDiff)).Methods("GET")
+	artworks.Handle("/{id:[0-9]+}/revisions/{rev:[0-9]+}/image", authed(handlers.GetRevisionImage)).Methods("GET")
+	artworks.Handle("/{id:[0-9]+}/revisions/{rev:[0-9]+}/thumb", authed(handlers.GetRevisionThumbnail)).Methods("GET")
+	artworks.Handle("/{id:[0-9]+}/revisions/{rev:[0-9]+}/revert", authed(handlers.RevertArtwork)).Methods("POST")
+
+	// Image Retrieval, for members or at a signed URL (so <img> tags can load them)
+	artworks.Handle("/images/{id:[0-9]+}", handlers.RequireAuthOrSignature(http.HandlerFunc(handlers.GetImage))).Methods("GET")
+	artworks.Handle("/images/{id:[0-9]+}/thumb", handlers.RequireAuthOrSignature(http.HandlerFunc(handlers.GetThumbnail))).Methods("GET")
 }
 
 // setupMediumRoutes defines medium-related routes
@@ -106,15 +154,43 @@ func setupMediumRoutes(api *mux.Router) {
 func setupSpecialRoutes(api *mux.Router) {
 	// Search routes
 	api.HandleFunc("/search/artists", handlers.SearchArtists).Methods("GET")
-	api.HandleFunc("/search/artworks", handlers.SearchArtworks).Methods("GET")
+	api.Handle("/search/artworks", authed(handlers.SearchArtworks)).Methods("GET")
+
+	// Printable portfolio of a filtered set of artworks
+	api.Handle("/portfolio", authed(handlers.GeneratePortfolio)).Methods("GET")
 
 	// Statistics routes
 	api.HandleFunc("/stats/overview", handlers.GetOverviewStats).Methods("GET")
+	api.Handle("/stats/storage", authed(handlers.GetStorageStats)).Methods("GET")
+	api.Handle("/stats/image-cache", handlers.RequireAuth(handlers.RequireAdmin(http.HandlerFunc(handlers.GetImageCacheStats)))).Methods("GET")
+
+	// Who changed what, scoped to the caller's family (everything for admins)
+	api.Handle("/audit", authed(handlers.GetAuditLog)).Methods("GET")
+
+	// Deleted artists, artworks and images, until they're restored or purged
+	api.Handle("/trash", authed(handlers.GetTrash)).Methods("GET")
+	api.Handle("/trash/{type:artists|artworks|images}/{id:[0-9]+}/restore", authed(handlers.RestoreTrashItem)).Methods("POST")
+	api.Handle("/trash/{type:artists|artworks|images}/{id:[0-9]+}", authed(handlers.DeleteTrashItem)).Methods("DELETE")
+
+	// Resized copies of images, at URLs the server signed
+	api.Handle("/img/sign", authed(handlers.SignImageURL)).Methods("POST")
+	api.HandleFunc("/img/{id:[0-9]+}", handlers.GetResizedImage).Methods("GET")
+
+	// Artworks whose images look like the same drawing uploaded twice
+	api.Handle("/duplicates", authed(handlers.GetDuplicates)).Methods("GET")
+
+	// Family invitations (linking is by email invite, see also /artists/{id}/members)
+	api.Handle("/invitations", authed(handlers.GetInvitations)).Methods("GET")
+	api.Handle("/invitations", authed(handlers.CreateInvitation)).Methods("POST")
+	api.Handle("/invitations/received", authed(handlers.GetReceivedInvitations)).Methods("GET")
+	api.Handle("/invitations/accept", authed(handlers.AcceptInvitation)).Methods("POST")
+	api.Handle("/invitations/{id:[0-9]+}/resend", authed(handlers.ResendInvitation)).Methods("POST")
+	api.Handle("/invitations/{id:[0-9]+}", authed(handlers.CancelInvitation)).Methods("DELETE")
+}
 
-	// User-Artist relationship routes
-	api.HandleFunc("/users/{user_id:[0-9]+}/artists", handlers.GetUserArtists).Methods("GET")
-	api.HandleFunc("/users/{user_id:[0-9]+}/artists/{artist_id:[0-9]+}", handlers.AddUserArtist).Methods("POST")
-	api.HandleFunc("/users/{user_id:[0-9]+}/artists/{artist_id:[0-9]+}", handlers.RemoveUserArtist).Methods("DELETE")
+// authed wraps a handler so it only runs for a signed-in user
+func authed(h http.HandlerFunc) http.Handler {
+	return handlers.RequireAuth(h)
 }
 
 // corsMiddleware adds CORS headers
@@ -0,0 +1,118 @@
+// Package trash permanently deletes artists, artworks and images that have
+// been in the trash for longer than the retention period.
+package trash
+
+import (
+	"log"
+	"time"
+
+	"go-art-api/audit"
+	"go-art-api/config"
+)
+
+// purgeInterval is how often the background purge runs
+const purgeInterval = time.Hour
+
+// table describes one kind of trashable row. trashedQuery returns the id,
+// owning artist and deleted_at of each row in the trash.
+type table struct {
+	name         string
+	entity       string
+	trashedQuery string
+}
+
+// Children first, so a purge that removes both an artwork and its artist
+// records each of them rather than leaving one to the cascade
+var tables = []table{
+	{"images", audit.Image, `
+		SELECT i.id, a.artist_id, i.deleted_at FROM images i
+		JOIN artworks a ON a.id = i.artwork_id
+		WHERE i.deleted_at IS NOT NULL`},
+	{"artworks", audit.Artwork, "SELECT id, artist_id, deleted_at FROM artworks WHERE deleted_at IS NOT NULL"},
+	{"artists", audit.Artist, "SELECT id, id, deleted_at FROM artists WHERE deleted_at IS NOT NULL"},
+}
+
+// Start purges once now and then every hour, in the background
+func Start(db *config.Database, retention time.Duration) {
+	go func() {
+		for {
+			if n, err := Purge(db, time.Now().UTC().Add(-retention)); err != nil {
+				log.Printf("❌ Trash purge failed: %v", err)
+			} else if n > 0 {
+				log.Printf("🗑️  Purged %d item(s) trashed more than %s ago", n, retention)
+			}
+			time.Sleep(purgeInterval)
+		}
+	}()
+}
+
+// Purge permanently deletes everything trashed before cutoff and returns how
+// many rows went. Anything hanging off a purged row goes with it (ON DELETE
+// CASCADE).
+func Purge(db *config.Database, cutoff time.Time) (int, error) {
+	purged := 0
+	for _, t := range tables {
+		n, err := purgeTable(db, t, cutoff)
+		purged += n
+		if err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+func purgeTable(db *config.Database, t table, cutoff time.Time) (int, error) {
+	type row struct {
+		id, artistID int
+		deletedAt    time.Time
+	}
+
+	// Compared in Go rather than SQL, which keeps timestamps dialect-neutral
+	rows, err := db.Query(t.trashedQuery)
+	if err != nil {
+		return 0, err
+	}
+	var expired []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.artistID, &r.deletedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if r.deletedAt.Before(cutoff) {
+			expired = append(expired, r)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, r := range expired {
+		tx, err := db.Begin()
+		if err != nil {
+			return purged, err
+		}
+		// Re-checked here in case it was restored since the scan
+		result, err := tx.Exec("DELETE FROM "+t.name+" WHERE id = ? AND deleted_at IS NOT NULL", r.id)
+		n := int64(0)
+		if err == nil {
+			if n, _ = result.RowsAffected(); n > 0 {
+				err = audit.Record(tx, audit.Entry{
+					Action: audit.Delete, Entity: t.entity, EntityID: r.id, ArtistID: r.artistID,
+					Before: map[string]time.Time{"deleted_at": r.deletedAt},
+				})
+			}
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			tx.Rollback()
+			return purged, err
+		}
+		purged += int(n)
+	}
+	return purged, nil
+}
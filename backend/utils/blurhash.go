@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"errors"
+	"image"
+	"io"
+	"math"
+	"strings"
+)
+
+// BlurHash components across and down. 4x3 suits landscape and portrait
+// artwork alike and encodes to 28 characters.
+const (
+	BlurHashX = 4
+	BlurHashY = 3
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// ImageBlurHash decodes an image (the thumbnail is plenty) and returns its
+// BlurHash
+func ImageBlurHash(r io.Reader) (string, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return "", err
+	}
+	return BlurHash(img, BlurHashX, BlurHashY)
+}
+
+// BlurHash encodes an image as a short string that clients decode into a
+// blurred placeholder while the real thumbnail loads (https://blurha.sh).
+// The image is described by x by y cosine components of its colours.
+func BlurHash(img image.Image, x, y int) (string, error) {
+	if x < 1 || x > 9 || y < 1 || y > 9 {
+		return "", errors.New("blurhash components must be 1-9")
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return "", errors.New("image is empty")
+	}
+
+	// Linear light per pixel, and the cosines each component needs
+	linear := make([][3]float64, w*h)
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			r, g, bl, _ := img.At(b.Min.X+px, b.Min.Y+py).RGBA()
+			linear[py*w+px] = [3]float64{srgbToLinear(r >> 8), srgbToLinear(g >> 8), srgbToLinear(bl >> 8)}
+		}
+	}
+	cosX := cosineTable(x, w)
+	cosY := cosineTable(y, h)
+
+	factors := make([][3]float64, 0, x*y)
+	for j := 0; j < y; j++ {
+		for i := 0; i < x; i++ {
+			norm := 2.0
+			if i == 0 && j == 0 {
+				norm = 1
+			}
+			var f [3]float64
+			for py := 0; py < h; py++ {
+				for px := 0; px < w; px++ {
+					basis := cosX[i][px] * cosY[j][py]
+					p := linear[py*w+px]
+					f[0], f[1], f[2] = f[0]+basis*p[0], f[1]+basis*p[1], f[2]+basis*p[2]
+				}
+			}
+			scale := norm / float64(w*h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(base83((x-1)+(y-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantisedMax+1) / 166
+		hash.WriteString(base83(quantisedMax, 1))
+	} else {
+		hash.WriteString(base83(0, 1))
+	}
+
+	hash.WriteString(base83(linearToSrgb(dc[0])<<16+linearToSrgb(dc[1])<<8+linearToSrgb(dc[2]), 4))
+	for _, f := range ac {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		hash.WriteString(base83(quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2))
+	}
+	return hash.String(), nil
+}
+
+// cosineTable holds cos(pi * component * pixel / size) for every component
+// and pixel along one axis
+func cosineTable(components, size int) [][]float64 {
+	table := make([][]float64, components)
+	for c := range table {
+		table[c] = make([]float64, size)
+		for p := range table[c] {
+			table[c][p] = math.Cos(math.Pi * float64(c) * float64(p) / float64(size))
+		}
+	}
+	return table
+}
+
+func base83(value, length int) string {
+	out := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		out[i] = base83Chars[value%83]
+		value /= 83
+	}
+	return string(out)
+}
+
+func srgbToLinear(v uint32) float64 {
+	f := float64(v) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+func linearToSrgb(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"errors"
+	"image"
+	"io"
+	"math"
+
+	"go-art-api/models"
+
+	"golang.org/x/image/draw"
+)
+
+// MaxEditSize bounds the working copy an edit is applied to. Originals are
+// scaled down to this many pixels on a side first; the renditions made from
+// the result are far smaller, even after a tight crop.
+const MaxEditSize = 2000
+
+// EditImage decodes an original, applies edits to it and generates the
+// thumbnail and full-size JPEGs and perceptual hash of the result, like
+// ProcessImage
+func EditImage(original io.Reader, edits models.ImageEdits) (thumbData []byte, imageData []byte, hash uint64, err error) {
+	edited, err := editedImage(original, edits)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	thumbData, imageData, err = renditions(edited)
+	return thumbData, imageData, PerceptualHash(edited), err
+}
+
+// editedImage decodes an original into a working copy no larger than
+// MaxEditSize and applies edits to it
+func editedImage(original io.Reader, edits models.ImageEdits) (image.Image, error) {
+	if err := ValidateEdits(edits); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(original)
+	if err != nil {
+		return nil, err
+	}
+
+	// Scale into a working copy we can write to
+	w, h := getScaledDimensions(uint(img.Bounds().Dx()), uint(img.Bounds().Dy()), MaxEditSize)
+	work := image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+	draw.BiLinear.Scale(work, work.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	work = rotate(work, edits.Rotate)
+	if edits.FlipHorizontal || edits.FlipVertical {
+		work = flip(work, edits.FlipHorizontal, edits.FlipVertical)
+	}
+	if len(edits.Perspective) == 4 {
+		if work, err = warpPerspective(work, edits.Perspective); err != nil {
+			return nil, err
+		}
+	}
+	if c := edits.Crop; c != nil {
+		b := work.Bounds()
+		x0, x1 := int(math.Round(c.X*float64(b.Dx()))), int(math.Round((c.X+c.Width)*float64(b.Dx())))
+		y0, y1 := int(math.Round(c.Y*float64(b.Dy()))), int(math.Round((c.Y+c.Height)*float64(b.Dy())))
+		rect := image.Rect(x0, y0, x1, y1).Intersect(b)
+		if rect.Dx() < 1 || rect.Dy() < 1 {
+			return nil, errors.New("crop is too small")
+		}
+		return work.SubImage(rect), nil
+	}
+	return work, nil
+}
+
+// ValidateEdits checks that edits can be applied to any image
+func ValidateEdits(e models.ImageEdits) error {
+	switch e.Rotate {
+	case 0, 90, 180, 270:
+	default:
+		return errors.New("rotate must be 0, 90, 180 or 270")
+	}
+
+	if n := len(e.Perspective); n != 0 && n != 4 {
+		return errors.New("perspective needs four corners")
+	}
+	for _, p := range e.Perspective {
+		if !isFraction(p.X) || !isFraction(p.Y) {
+			return errors.New("perspective corners must be within the image (0-1)")
+		}
+	}
+	// Going round top-left, top-right, bottom-right, bottom-left, every turn
+	// has to be clockwise (positive, with y pointing down) for a convex shape
+	for i := range e.Perspective {
+		a, b, c := e.Perspective[i], e.Perspective[(i+1)%4], e.Perspective[(i+2)%4]
+		if (b.X-a.X)*(c.Y-b.Y)-(b.Y-a.Y)*(c.X-b.X) <= 0 {
+			return errors.New("perspective corners must go top-left, top-right, bottom-right, bottom-left around a convex shape")
+		}
+	}
+
+	if c := e.Crop; c != nil {
+		if !isFraction(c.X) || !isFraction(c.Y) || c.Width <= 0 || c.Height <= 0 ||
+			c.X+c.Width > 1+1e-9 || c.Y+c.Height > 1+1e-9 {
+			return errors.New("crop must be a non-empty rectangle within the image (0-1)")
+		}
+	}
+	return nil
+}
+
+func isFraction(f float64) bool {
+	return f >= 0 && f <= 1
+}
+
+// rotate turns an image clockwise by 0, 90, 180 or 270 degrees
+func rotate(src *image.RGBA, degrees int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	switch degrees {
+	case 90:
+		return remap(src, h, w, func(x, y int) (int, int) { return y, h - 1 - x })
+	case 180:
+		return remap(src, w, h, func(x, y int) (int, int) { return w - 1 - x, h - 1 - y })
+	case 270:
+		return remap(src, h, w, func(x, y int) (int, int) { return w - 1 - y, x })
+	}
+	return src
+}
+
+// flip mirrors an image left-right and/or top-bottom
+func flip(src *image.RGBA, horizontal, vertical bool) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	return remap(src, w, h, func(x, y int) (int, int) {
+		if horizontal {
+			x = w - 1 - x
+		}
+		if vertical {
+			y = h - 1 - y
+		}
+		return x, y
+	})
+}
+
+// remap builds a w x h image whose pixel (x, y) is src's pixel at(x, y).
+// src must start at the origin.
+func remap(src *image.RGBA, w, h int, at func(x, y int) (int, int)) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx, sy := at(x, y)
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
+
+// warpPerspective maps the quadrilateral with the given corners (fractions of
+// src, clockwise from top-left) onto an upright rectangle. The rectangle is as
+// large as the longer of each pair of opposite sides.
+func warpPerspective(src *image.RGBA, corners []models.ImagePoint) (*image.RGBA, error) {
+	sw, sh := float64(src.Bounds().Dx()), float64(src.Bounds().Dy())
+	var x, y [4]float64
+	for i, p := range corners {
+		x[i], y[i] = p.X*sw, p.Y*sh
+	}
+
+	w := int(math.Round(math.Max(math.Hypot(x[1]-x[0], y[1]-y[0]), math.Hypot(x[2]-x[3], y[2]-y[3]))))
+	h := int(math.Round(math.Max(math.Hypot(x[3]-x[0], y[3]-y[0]), math.Hypot(x[2]-x[1], y[2]-y[1]))))
+	if w < 1 || h < 1 {
+		return nil, errors.New("perspective corners are too close together")
+	}
+
+	// Projective map from the unit square onto the corners (Heckbert,
+	// "Fundamentals of Texture Mapping", 1989)
+	dx1, dx2, dx3 := x[1]-x[2], x[3]-x[2], x[0]-x[1]+x[2]-x[3]
+	dy1, dy2, dy3 := y[1]-y[2], y[3]-y[2], y[0]-y[1]+y[2]-y[3]
+	var g, hh float64
+	if dx3 != 0 || dy3 != 0 {
+		den := dx1*dy2 - dx2*dy1
+		if den == 0 {
+			return nil, errors.New("perspective corners don't form a four-sided shape")
+		}
+		g = (dx3*dy2 - dx2*dy3) / den
+		hh = (dx1*dy3 - dx3*dy1) / den
+	}
+	a, b, c := x[1]-x[0]+g*x[1], x[3]-x[0]+hh*x[3], x[0]
+	d, e, f := y[1]-y[0]+g*y[1], y[3]-y[0]+hh*y[3], y[0]
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for j := 0; j < h; j++ {
+		v := (float64(j) + 0.5) / float64(h)
+		for i := 0; i < w; i++ {
+			u := (float64(i) + 0.5) / float64(w)
+			den := g*u + hh*v + 1
+			sampleBilinear(src, (a*u+b*v+c)/den-0.5, (d*u+e*v+f)/den-0.5, dst.Pix[dst.PixOffset(i, j):])
+		}
+	}
+	return dst, nil
+}
+
+// sampleBilinear writes the colour of src at a fractional pixel position,
+// clamped to its edges, into the first four bytes of out
+func sampleBilinear(src *image.RGBA, x, y float64, out []uint8) {
+	maxX, maxY := float64(src.Bounds().Dx()-1), float64(src.Bounds().Dy()-1)
+	x, y = math.Max(0, math.Min(x, maxX)), math.Max(0, math.Min(y, maxY))
+	x0, y0 := int(x), int(y)
+	x1, y1 := x0, y0
+	if float64(x0) < maxX {
+		x1++
+	}
+	if float64(y0) < maxY {
+		y1++
+	}
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	p00, p10 := src.PixOffset(x0, y0), src.PixOffset(x1, y0)
+	p01, p11 := src.PixOffset(x0, y1), src.PixOffset(x1, y1)
+	for k := 0; k < 4; k++ {
+		top := float64(src.Pix[p00+k])*(1-fx) + float64(src.Pix[p10+k])*fx
+		bottom := float64(src.Pix[p01+k])*(1-fx) + float64(src.Pix[p11+k])*fx
+		out[k] = uint8(top*(1-fy) + bottom*fy + 0.5)
+	}
+}
@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"image"
+	"io"
+	"math"
+
+	"go-art-api/models"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	enhanceAnalysisSize = 256  // paper is looked for in a copy this many pixels on a side
+	minPaperArea        = 0.2  // smaller bright patches aren't taken for the paper
+	maxPaperArea        = 0.97 // a sheet covering more than this already fills the photo
+	maxWhiteGain        = 3.0  // how far a dim or tinted sheet may be brightened
+	contrastBoost       = 1.15 // stretch around mid-grey once the paper is white
+)
+
+// EnhanceImage is EditImage plus auto-enhance. After the edits it looks for a
+// sheet of paper and straightens it to fill the image, balances the colour so
+// the paper comes out white, and adds a little contrast. paper is the corners
+// of the sheet it straightened, as fractions of the edited image, or nil if
+// it found none to straighten.
+func EnhanceImage(original io.Reader, edits models.ImageEdits) (thumbData, imageData []byte, paper []models.ImagePoint, err error) {
+	edited, err := editedImage(original, edits)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	enhanced, paper := autoEnhance(toRGBA(edited))
+	thumbData, imageData, err = renditions(enhanced)
+	return thumbData, imageData, paper, err
+}
+
+// autoEnhance works on src in place, unless the paper is straightened into a
+// new image
+func autoEnhance(src *image.RGBA) (*image.RGBA, []models.ImagePoint) {
+	w, h := getScaledDimensions(uint(src.Bounds().Dx()), uint(src.Bounds().Dy()), enhanceAnalysisSize)
+	small := image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	// Paper is the largest patch that is light in every channel, which
+	// leaves out both a darker table and coloured marks on the sheet
+	region := largestRegion(paperMask(small), int(w), int(h))
+	isPaper := len(region) > 0 && float64(len(region)) >= minPaperArea*float64(w*h)
+
+	var white [3]uint8
+	var paper []models.ImagePoint
+	if isPaper {
+		white = medianColour(small, region)
+		corners := regionCorners(region, int(w), int(h))
+		if ValidateEdits(models.ImageEdits{Perspective: corners}) == nil && quadArea(corners) <= maxPaperArea {
+			if straightened, err := warpPerspective(src, corners); err == nil {
+				src, paper = straightened, corners
+			}
+		}
+	} else {
+		// No sheet to go by, so the brightest few percent stand in for white
+		white = percentileColour(small, 0.95)
+	}
+
+	var lut [3][256]uint8
+	for c := 0; c < 3; c++ {
+		gain := math.Max(1, math.Min(maxWhiteGain, 255/math.Max(1, float64(white[c]))))
+		for v := 0; v < 256; v++ {
+			balanced := math.Min(255, float64(v)*gain)
+			lut[c][v] = uint8(math.Max(0, math.Min(255, 128+(balanced-128)*contrastBoost)) + 0.5)
+		}
+	}
+	for i := 0; i < len(src.Pix); i += 4 {
+		src.Pix[i] = lut[0][src.Pix[i]]
+		src.Pix[i+1] = lut[1][src.Pix[i+1]]
+		src.Pix[i+2] = lut[2][src.Pix[i+2]]
+	}
+	return src, paper
+}
+
+// paperMask marks the pixels whose darkest channel is above an Otsu threshold
+func paperMask(img *image.RGBA) []bool {
+	var hist [256]int
+	light := make([]uint8, len(img.Pix)/4)
+	for i := range light {
+		p := img.Pix[i*4 : i*4+3]
+		light[i] = minUint8(p[0], minUint8(p[1], p[2]))
+		hist[light[i]]++
+	}
+	threshold := otsuThreshold(hist, len(light))
+
+	mask := make([]bool, len(light))
+	for i, v := range light {
+		mask[i] = int(v) > threshold
+	}
+	return mask
+}
+
+// otsuThreshold picks the level that best splits a histogram in two (Otsu,
+// "A Threshold Selection Method from Gray-Level Histograms", 1979)
+func otsuThreshold(hist [256]int, total int) int {
+	var sum float64
+	for v, n := range hist {
+		sum += float64(v * n)
+	}
+	var sumBelow, below float64
+	best, bestVariance := 0, -1.0
+	for v, n := range hist {
+		below += float64(n)
+		above := float64(total) - below
+		if below == 0 {
+			continue
+		}
+		if above == 0 {
+			break
+		}
+		sumBelow += float64(v * n)
+		meanBelow, meanAbove := sumBelow/below, (sum-sumBelow)/above
+		if variance := below * above * (meanBelow - meanAbove) * (meanBelow - meanAbove); variance > bestVariance {
+			best, bestVariance = v, variance
+		}
+	}
+	return best
+}
+
+// largestRegion returns the pixel indexes of the biggest 4-connected patch of
+// set pixels in a w x h mask
+func largestRegion(mask []bool, w, h int) []int {
+	seen := make([]bool, len(mask))
+	var largest, region, queue []int
+	for start := range mask {
+		if !mask[start] || seen[start] {
+			continue
+		}
+		region, queue = region[:0], append(queue[:0], start)
+		seen[start] = true
+		for len(queue) > 0 {
+			i := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			region = append(region, i)
+			x, y := i%w, i/w
+			for _, n := range [4][3]int{{x - 1, y, i - 1}, {x + 1, y, i + 1}, {x, y - 1, i - w}, {x, y + 1, i + w}} {
+				if n[0] >= 0 && n[0] < w && n[1] >= 0 && n[1] < h && mask[n[2]] && !seen[n[2]] {
+					seen[n[2]] = true
+					queue = append(queue, n[2])
+				}
+			}
+		}
+		if len(region) > len(largest) {
+			largest = append(largest[:0], region...)
+		}
+	}
+	return largest
+}
+
+// regionCorners approximates a region by the quadrilateral through its
+// extreme points along both diagonals, clockwise from top-left, as fractions
+// of the w x h image
+func regionCorners(region []int, w, h int) []models.ImagePoint {
+	tl, tr, br, bl := region[0], region[0], region[0], region[0]
+	for _, i := range region {
+		x, y := i%w, i/w
+		switch {
+		case x+y < tl%w+tl/w:
+			tl = i
+		case x+y > br%w+br/w:
+			br = i
+		}
+		switch {
+		case x-y > tr%w-tr/w:
+			tr = i
+		case x-y < bl%w-bl/w:
+			bl = i
+		}
+	}
+	// Outer pixel edges rather than centres, so a sheet filling the
+	// photo reaches all the way to 0 and 1
+	point := func(i, dx, dy int) models.ImagePoint {
+		return models.ImagePoint{X: float64(i%w+dx) / float64(w), Y: float64(i/w+dy) / float64(h)}
+	}
+	return []models.ImagePoint{point(tl, 0, 0), point(tr, 1, 0), point(br, 1, 1), point(bl, 0, 1)}
+}
+
+// quadArea is the shoelace area of a quadrilateral in fractions of the image
+func quadArea(q []models.ImagePoint) float64 {
+	var twice float64
+	for i := range q {
+		a, b := q[i], q[(i+1)%len(q)]
+		twice += a.X*b.Y - b.X*a.Y
+	}
+	return math.Abs(twice) / 2
+}
+
+// medianColour is the per-channel median of the given pixels
+func medianColour(img *image.RGBA, pixels []int) [3]uint8 {
+	var hist [3][256]int
+	for _, i := range pixels {
+		for c := 0; c < 3; c++ {
+			hist[c][img.Pix[i*4+c]]++
+		}
+	}
+	return histogramPercentile(hist, len(pixels), 0.5)
+}
+
+// percentileColour is the per-channel value that fraction p of the image is
+// no brighter than
+func percentileColour(img *image.RGBA, p float64) [3]uint8 {
+	var hist [3][256]int
+	for i := 0; i < len(img.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			hist[c][img.Pix[i+c]]++
+		}
+	}
+	return histogramPercentile(hist, len(img.Pix)/4, p)
+}
+
+func histogramPercentile(hist [3][256]int, total int, p float64) [3]uint8 {
+	var out [3]uint8
+	for c := 0; c < 3; c++ {
+		count := 0
+		for v, n := range hist[c] {
+			count += n
+			if float64(count) >= p*float64(total) {
+				out[c] = uint8(v)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// toRGBA returns img as an RGBA image starting at the origin, copying it only
+// if it has to
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+func minUint8(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+	return b
+}
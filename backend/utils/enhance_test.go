@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"go-art-api/models"
+)
+
+// Very thin images scale to less than a pixel across in the analysis copy,
+// which must neither panic nor come out empty
+func TestEnhanceThinImages(t *testing.T) {
+	for _, size := range []image.Point{{600, 2}, {1500, 1}, {1, 1500}} {
+		src := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+		for y := 0; y < size.Y; y++ {
+			for x := 0; x < size.X; x++ {
+				src.Set(x, y, color.RGBA{R: 240, G: 235, B: 220, A: 255})
+			}
+		}
+		var in bytes.Buffer
+		if err := png.Encode(&in, src); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, _, err := EnhanceImage(bytes.NewReader(in.Bytes()), models.ImageEdits{}); err != nil {
+			t.Errorf("EnhanceImage(%v): %v", size, err)
+		}
+		o := ResizeOptions{Width: 64, Enhance: true}
+		if _, err := ResizeImage(bytes.NewReader(in.Bytes()), models.ImageEdits{}, o); err != nil {
+			t.Errorf("ResizeImage(%v): %v", size, err)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"image/jpeg"
 	"io"
 	"log"
+	"math"
 
 	// Register image formats without direct usage
 	_ "image/gif"
@@ -23,15 +24,22 @@ const (
 	MaxImageBlob = 200 * 1024 // 200 KB, Was 500KB
 )
 
-// ProcessImage generates a single JPEG BLOB for both thumbnail and full image.
-func ProcessImage(file io.Reader) (thumbData []byte, imageData []byte, err error) {
+// ProcessImage generates a single JPEG BLOB for both thumbnail and full image,
+// plus the image's PerceptualHash for spotting duplicates.
+func ProcessImage(file io.Reader) (thumbData []byte, imageData []byte, hash uint64, err error) {
 	// 1. Decode the image (supports JPEG, PNG, GIF via standard library)
 	img, format, err := image.Decode(file)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, 0, err
 	}
 	log.Printf("Successfully decoded uploaded image (Format: %s)", format)
 
+	thumbData, imageData, err = renditions(img)
+	return thumbData, imageData, PerceptualHash(img), err
+}
+
+// renditions scales a decoded image down to the thumbnail and full-size JPEGs
+func renditions(img image.Image) (thumbData []byte, imageData []byte, err error) {
 	// --- 2. Generate THUMBNAIL (Max 200x200, Max 64KB, JPEG) ---
 
 	// Calculate target size (Casting int to uint for the function)
@@ -76,11 +84,12 @@ func getScaledDimensions(width, height, maxDim uint) (uint, uint) {
 		return width, height
 	}
 
+	// A very thin image keeps at least a pixel across
 	ratio := float64(width) / float64(height)
 	if width > height {
-		return maxDim, uint(float64(maxDim) / ratio)
+		return maxDim, uint(math.Max(1, float64(maxDim)/ratio))
 	}
-	return uint(float64(maxDim) * ratio), maxDim
+	return uint(math.Max(1, float64(maxDim)*ratio)), maxDim
 }
 
 // encodeAndCompressJPEG repeatedly encodes the image with decreasing quality until size limit is met
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-art-api/models"
+)
+
+// PaletteSize is how many dominant colours are kept per image
+const PaletteSize = 5
+
+// ImagePalette decodes an image (the thumbnail is plenty) and returns its
+// dominant colours
+func ImagePalette(r io.Reader) ([]models.PaletteColor, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return ExtractPalette(img, PaletteSize), nil
+}
+
+// ExtractPalette finds up to n dominant colours by median cut: the pixels are
+// split at the median of their widest channel until there are n groups, whose
+// average colours are then refined by k-means. Each colour is reported with
+// its share of the image, most common first.
+func ExtractPalette(img image.Image, n int) []models.PaletteColor {
+	b := img.Bounds()
+	pixels := make([][3]uint8, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			pixels = append(pixels, [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8)})
+		}
+	}
+	if len(pixels) == 0 {
+		return nil
+	}
+
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < n {
+		// Split whichever box is widest, weighted by how many pixels it
+		// holds, so a few stray specks don't get a colour of their own
+		best, bestScore, bestChannel := -1, 0, 0
+		for i, box := range boxes {
+			channel, spread := widestChannel(box)
+			if score := spread * len(box); len(box) > 1 && score > bestScore {
+				best, bestScore, bestChannel = i, score, channel
+			}
+		}
+		if best < 0 {
+			break
+		}
+		box := boxes[best]
+		sort.Slice(box, func(i, j int) bool { return box[i][bestChannel] < box[j][bestChannel] })
+		mid := len(box) / 2
+		boxes[best] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	// Median cut halves boxes, so their sizes say little about how much of
+	// the image each colour covers. A few k-means passes seeded with the box
+	// averages settle every pixel on its nearest colour.
+	centres := make([][3]float64, len(boxes))
+	for i, box := range boxes {
+		centres[i] = meanColour(box)
+	}
+	counts := make([]int, len(centres))
+	for pass := 0; pass < 3; pass++ {
+		sums := make([][3]float64, len(centres))
+		for i := range counts {
+			counts[i] = 0
+		}
+		for _, p := range pixels {
+			nearest, nearestDist := 0, math.Inf(1)
+			for i, c := range centres {
+				dr, dg, db := float64(p[0])-c[0], float64(p[1])-c[1], float64(p[2])-c[2]
+				if d := dr*dr + dg*dg + db*db; d < nearestDist {
+					nearest, nearestDist = i, d
+				}
+			}
+			counts[nearest]++
+			for ch := 0; ch < 3; ch++ {
+				sums[nearest][ch] += float64(p[ch])
+			}
+		}
+		for i := range centres {
+			if counts[i] > 0 {
+				for ch := 0; ch < 3; ch++ {
+					centres[i][ch] = sums[i][ch] / float64(counts[i])
+				}
+			}
+		}
+	}
+
+	palette := make([]models.PaletteColor, 0, len(centres))
+	for i, c := range centres {
+		if counts[i] == 0 {
+			continue
+		}
+		palette = append(palette, models.PaletteColor{
+			Hex:   fmt.Sprintf("#%02x%02x%02x", uint8(c[0]+0.5), uint8(c[1]+0.5), uint8(c[2]+0.5)),
+			Share: math.Round(float64(counts[i])/float64(len(pixels))*1000) / 1000,
+		})
+	}
+	sort.SliceStable(palette, func(i, j int) bool { return palette[i].Share > palette[j].Share })
+	return palette
+}
+
+// meanColour is the average of a box of pixels
+func meanColour(box [][3]uint8) [3]float64 {
+	var sum [3]float64
+	for _, p := range box {
+		for ch := 0; ch < 3; ch++ {
+			sum[ch] += float64(p[ch])
+		}
+	}
+	for ch := range sum {
+		sum[ch] /= float64(len(box))
+	}
+	return sum
+}
+
+// widestChannel returns the RGB channel with the largest range in a box, and
+// that range
+func widestChannel(box [][3]uint8) (channel, spread int) {
+	for c := 0; c < 3; c++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, p := range box {
+			if p[c] < lo {
+				lo = p[c]
+			}
+			if p[c] > hi {
+				hi = p[c]
+			}
+		}
+		if int(hi)-int(lo) > spread {
+			channel, spread = c, int(hi)-int(lo)
+		}
+	}
+	return channel, spread
+}
+
+// ParseHexColor reads "#rrggbb" or "rrggbb"
+func ParseHexColor(s string) ([3]uint8, error) {
+	s = strings.TrimPrefix(s, "#")
+	v, err := strconv.ParseUint(s, 16, 32)
+	if len(s) != 6 || err != nil {
+		return [3]uint8{}, errors.New("colour must be a hex code like #3366cc")
+	}
+	return [3]uint8{uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// ColorDistance is how different two colours look: the CIE76 ΔE between
+// them in L*a*b*. Around 2 is barely noticeable; 25 or so is a clearly
+// different shade of the same colour.
+func ColorDistance(a, b [3]uint8) float64 {
+	l1, a1, b1 := toLab(a)
+	l2, a2, b2 := toLab(b)
+	return math.Sqrt((l1-l2)*(l1-l2) + (a1-a2)*(a1-a2) + (b1-b2)*(b1-b2))
+}
+
+// toLab converts sRGB to CIE L*a*b* with a D65 white point
+func toLab(c [3]uint8) (l, a, b float64) {
+	var lin [3]float64
+	for i, v := range c {
+		f := float64(v) / 255
+		if f <= 0.04045 {
+			lin[i] = f / 12.92
+		} else {
+			lin[i] = math.Pow((f+0.055)/1.055, 2.4)
+		}
+	}
+	x := (0.4124*lin[0] + 0.3576*lin[1] + 0.1805*lin[2]) / 0.95047
+	y := 0.2126*lin[0] + 0.7152*lin[1] + 0.0722*lin[2]
+	z := (0.0193*lin[0] + 0.1192*lin[1] + 0.9505*lin[2]) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
@@ -6,12 +6,16 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"runtime"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/argon2"
 )
 
-// Argon2 parameters - these are recommended values
+// Argon2 parameters - these are recommended values. New hashes use the
+// current values; existing hashes keep the ones encoded in them until
+// NeedsRehash flags them for an upgrade.
 var (
 	// Time parameter (iterations)
 	argon2Time uint32 = 1
@@ -25,11 +29,58 @@ var (
 	hashLength uint32 = 32
 )
 
+// SetArgon2Params changes the policy for new hashes. Call it before serving
+// requests.
+func SetArgon2Params(memoryKB, iterations uint32, threads uint8) {
+	argon2Memory = memoryKB
+	argon2Time = iterations
+	argon2Threads = threads
+}
+
+// Each Argon2 call holds argon2Memory of RAM, so only a bounded number run at
+// once; the rest queue briefly and then fail with ErrHashBusy.
+var (
+	hashSlots       = make(chan struct{}, runtime.NumCPU())
+	hashWaitTimeout = 5 * time.Second
+)
+
+// ErrHashBusy is returned when every hashing slot stayed taken for too long
+var ErrHashBusy = errors.New("password hashing is busy, try again shortly")
+
+// SetHashConcurrency sets how many Argon2 hashes may run at once. Call it
+// before serving requests.
+func SetHashConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	hashSlots = make(chan struct{}, n)
+}
+
+// acquireHashSlot waits for a free hashing slot; call the returned func to release it
+func acquireHashSlot() (func(), error) {
+	slots := hashSlots
+	timer := time.NewTimer(hashWaitTimeout)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-timer.C:
+		return nil, ErrHashBusy
+	}
+}
+
 // HashPassword creates an Argon2id hash of the password
 func HashPassword(password string) (string, error) {
+	release, err := acquireHashSlot()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
 	// Generate a random salt
 	salt := make([]byte, saltLength)
-	_, err := rand.Read(salt)
+	_, err = rand.Read(salt)
 	if err != nil {
 		return "", err
 	}
@@ -56,6 +107,12 @@ func VerifyPassword(password, encodedHash string) (bool, error) {
 		return false, err
 	}
 
+	release, err := acquireHashSlot()
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
 	// Generate hash from the provided password using the same parameters
 	testHash := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(hash)))
 
@@ -63,6 +120,21 @@ func VerifyPassword(password, encodedHash string) (bool, error) {
 	return subtle.ConstantTimeCompare(hash, testHash) == 1, nil
 }
 
+// NeedsRehash reports whether a stored hash was made with different
+// parameters than the current policy (or can't be parsed at all), so it
+// should be replaced the next time the plaintext password is known.
+func NeedsRehash(encodedHash string) bool {
+	salt, hash, params, err := decodeHash(encodedHash)
+	if err != nil {
+		return true
+	}
+	return params.memory != argon2Memory ||
+		params.time != argon2Time ||
+		params.threads != argon2Threads ||
+		uint32(len(salt)) != saltLength ||
+		uint32(len(hash)) != hashLength
+}
+
 // argon2Params holds the parameters for Argon2
 type argon2Params struct {
 	memory  uint32
@@ -72,7 +144,8 @@ type argon2Params struct {
 
 // decodeHash decodes an Argon2id hash string
 func decodeHash(encodedHash string) (salt, hash []byte, params *argon2Params, err error) {
-	parts := strings.Split(encodedHash, "$")
+	// Hashes stored in a CHAR column come back space-padded on some databases
+	parts := strings.Split(strings.TrimSpace(encodedHash), "$")
 	if len(parts) != 6 {
 		return nil, nil, nil, errors.New("invalid hash format")
 	}
@@ -0,0 +1,33 @@
+package utils
+
+import "testing"
+
+func TestNeedsRehash(t *testing.T) {
+	memory, iterations, threads := argon2Memory, argon2Time, argon2Threads
+	t.Cleanup(func() { SetArgon2Params(memory, iterations, threads) })
+
+	SetArgon2Params(8*1024, 1, 1)
+	weak, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetArgon2Params(memory, iterations, threads)
+	current, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Old hashes keep working under their own parameters until replaced
+	if ok, err := VerifyPassword("correct horse", weak); err != nil || !ok {
+		t.Errorf("VerifyPassword with the old parameters = %v, %v", ok, err)
+	}
+	if !NeedsRehash(weak) {
+		t.Error("a hash with weaker parameters doesn't need a rehash")
+	}
+	if NeedsRehash(current) {
+		t.Error("a hash with the current parameters needs a rehash")
+	}
+	if !NeedsRehash("not a hash") {
+		t.Error("an unreadable hash doesn't need a rehash")
+	}
+}
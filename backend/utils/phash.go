@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"image"
+	"io"
+	"math/bits"
+
+	"golang.org/x/image/draw"
+)
+
+// PerceptualHash is a 64-bit difference hash (dHash) of an image: the image
+// is shrunk to 9x8 greys and each bit says whether a cell is brighter than
+// its right-hand neighbour. Rescaling, recompression and small changes in
+// exposure barely move it, so two photos of the same drawing hash a few bits
+// apart (see HashDistance).
+func PerceptualHash(img image.Image) uint64 {
+	// Averaging 16x16 blocks of a small copy is steadier than scaling
+	// straight down to 9x8
+	const cols, rows, block = 9, 8, 16
+	small := image.NewRGBA(image.Rect(0, 0, cols*block, rows*block))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var grey [rows][cols]int
+	for y := 0; y < rows*block; y++ {
+		for x := 0; x < cols*block; x++ {
+			p := small.Pix[small.PixOffset(x, y):]
+			// ITU-R 601 luma, in integers
+			grey[y/block][x/block] += 299*int(p[0]) + 587*int(p[1]) + 114*int(p[2])
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols-1; x++ {
+			hash <<= 1
+			if grey[y][x] > grey[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HashImage decodes an image and returns its PerceptualHash
+func HashImage(r io.Reader) (uint64, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return 0, err
+	}
+	return PerceptualHash(img), nil
+}
+
+// HashDistance is how many of the 64 bits two perceptual hashes differ in
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+
+	"go-art-api/models"
+
+	"golang.org/x/image/draw"
+)
+
+// How ResizeImage fits an image into a box given both a width and a height
+const (
+	FitContain = "contain" // the whole image, within the box
+	FitCover   = "cover"   // fill the box, cropping the overflow from the middle
+)
+
+// Formats ResizeImage can produce
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// ResizeSizes are the widths and heights ResizeImage makes, so signed URLs
+// can't ask for every size in between
+var ResizeSizes = []int{64, 128, 256, 320, 480, 640, 800, 1024, 1280, 1600, MaxEditSize}
+
+// resizeQuality is the JPEG quality of resized images. Unlike the stored
+// renditions they aren't squeezed under a size limit.
+const resizeQuality = 85
+
+// ResizeOptions describes a resized copy of an image. A zero Width or Height
+// follows the other and the aspect ratio.
+type ResizeOptions struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  string
+	Enhance bool // auto-enhance after the edits, as served while the toggle is on
+}
+
+// ValidateResize checks resize options, filling in the default fit and format
+func ValidateResize(o *ResizeOptions) error {
+	if o.Width == 0 && o.Height == 0 {
+		return errors.New("w or h is required")
+	}
+	if !resizeSize(o.Width) || !resizeSize(o.Height) {
+		return fmt.Errorf("w and h must be one of %v", ResizeSizes)
+	}
+	switch o.Fit {
+	case "":
+		o.Fit = FitContain
+	case FitContain, FitCover:
+	default:
+		return errors.New("fit must be cover or contain")
+	}
+	switch o.Format {
+	case "", "jpg":
+		o.Format = FormatJPEG
+	case FormatJPEG, FormatPNG:
+	default:
+		return errors.New("fmt must be jpeg or png")
+	}
+	return nil
+}
+
+// resizeSize reports whether n is one of ResizeSizes, or 0 for none
+func resizeSize(n int) bool {
+	if n == 0 {
+		return true
+	}
+	for _, size := range ResizeSizes {
+		if n == size {
+			return true
+		}
+	}
+	return false
+}
+
+// ResizeImage decodes an original, applies edits (and auto-enhance) as for
+// the stored renditions, and scales the result to the requested size. It
+// never scales up: a box larger than the image gets the image as it is, or
+// for cover the largest crop of the box's shape.
+func ResizeImage(original io.Reader, edits models.ImageEdits, o ResizeOptions) ([]byte, error) {
+	if err := ValidateResize(&o); err != nil {
+		return nil, err
+	}
+	img, err := editedImage(original, edits)
+	if err != nil {
+		return nil, err
+	}
+	if o.Enhance {
+		img, _ = autoEnhance(toRGBA(img))
+	}
+
+	src := img.Bounds()
+	sw, sh := float64(src.Dx()), float64(src.Dy())
+	var w, h float64
+	switch {
+	case o.Width == 0:
+		h = math.Min(float64(o.Height), sh)
+		w = sw * h / sh
+	case o.Height == 0:
+		w = math.Min(float64(o.Width), sw)
+		h = sh * w / sw
+	case o.Fit == FitCover:
+		// The middle of the image in the box's shape, then scaled to the box.
+		// A box much wider or taller than the image still keeps a pixel of it.
+		bw, bh := float64(o.Width), float64(o.Height)
+		scale := math.Max(bw/sw, bh/sh)
+		cw, ch := math.Max(1, bw/scale), math.Max(1, bh/scale)
+		x0, y0 := src.Min.X+int((sw-cw)/2), src.Min.Y+int((sh-ch)/2)
+		src = image.Rect(x0, y0, x0+int(math.Round(cw)), y0+int(math.Round(ch)))
+		scale = math.Min(1, scale)
+		w, h = float64(src.Dx())*scale, float64(src.Dy())*scale
+	default:
+		scale := math.Min(1, math.Min(float64(o.Width)/sw, float64(o.Height)/sh))
+		w, h = sw*scale, sh*scale
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, int(math.Max(1, math.Round(w))), int(math.Max(1, math.Round(h)))))
+	draw.CatmullRom.Scale(out, out.Bounds(), img, src, draw.Src, nil)
+
+	buf := new(bytes.Buffer)
+	if o.Format == FormatPNG {
+		err = png.Encode(buf, out)
+	} else {
+		err = jpeg.Encode(buf, out, &jpeg.Options{Quality: resizeQuality})
+	}
+	return buf.Bytes(), err
+}
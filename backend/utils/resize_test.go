@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"go-art-api/models"
+)
+
+func TestValidateResize(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		o    ResizeOptions
+		ok   bool
+	}{
+		{"width only", ResizeOptions{Width: 640}, true},
+		{"box", ResizeOptions{Width: 640, Height: 480, Fit: FitCover}, true},
+		{"largest", ResizeOptions{Height: MaxEditSize}, true},
+		{"no size", ResizeOptions{}, false},
+		{"between sizes", ResizeOptions{Width: 641}, false},
+		{"negative", ResizeOptions{Width: -64}, false},
+		{"too big", ResizeOptions{Width: 4096}, false},
+		{"bad fit", ResizeOptions{Width: 64, Fit: "stretch"}, false},
+		{"bad format", ResizeOptions{Width: 64, Format: "gif"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidateResize(&tc.o); (err == nil) != tc.ok {
+				t.Errorf("ValidateResize(%+v) = %v, want ok %v", tc.o, err, tc.ok)
+			}
+		})
+	}
+}
+
+// A cover box far wider than a tall, narrow image crops it to a sliver, not
+// to nothing
+func TestResizeImageCoverExtremeBox(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	src := image.NewRGBA(image.Rect(0, 0, 3, 600))
+	for y := 0; y < 600; y++ {
+		for x := 0; x < 3; x++ {
+			src.Set(x, y, red)
+		}
+	}
+	var in bytes.Buffer
+	if err := png.Encode(&in, src); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ResizeImage(&in, models.ImageEdits{}, ResizeOptions{Width: MaxEditSize, Height: 64, Fit: FitCover, Format: FormatPNG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := out.Bounds(); b.Dx() < 1 || b.Dy() < 1 {
+		t.Fatalf("resized to %v", b)
+	}
+	if r, _, _, a := out.At(0, 0).RGBA(); r>>8 < 200 || a>>8 < 200 {
+		t.Errorf("resized pixel is %v, want the image's red", out.At(0, 0))
+	}
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AccessClaims are the claims carried by an access token
+type AccessClaims struct {
+	SessionID int `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the subject as a user ID
+func (c *AccessClaims) UserID() (int, error) {
+	return strconv.Atoi(c.Subject)
+}
+
+// IssueAccessToken creates a signed HS256 access token for a user's session
+func IssueAccessToken(secret []byte, userID, sessionID int, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := AccessClaims{
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			Issuer:    "go-art-api",
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	return token, expiresAt, err
+}
+
+// ParseAccessToken verifies an access token's signature and expiry
+func ParseAccessToken(secret []byte, tokenString string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer("go-art-api"))
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || claims.SessionID == 0 {
+		return nil, errors.New("token is missing subject or session")
+	}
+	return claims, nil
+}
+
+// HashToken returns the hex SHA-256 of a random token. Refresh and reset
+// tokens are high-entropy, so a fast hash is enough to store them safely.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
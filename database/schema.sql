@@ -1,3 +1,9 @@
+-- ------------------------
+-- Reference snapshot of the MySQL schema. The source of truth is the numbered
+-- migrations in backend/migrations/mysql (and backend/migrations/postgres), applied on startup or with
+-- `go-art-api migrate up`. Keep this file in sync when adding a migration.
+-- ------------------------
+
 -- ------------------------
 -- Table: users
 -- ------------------------
@@ -6,7 +12,11 @@ CREATE TABLE users (
     fname VARCHAR(30) NOT NULL,
     lname VARCHAR(60) NOT NULL,
     email VARCHAR(60) NOT NULL UNIQUE,
-    pwd CHAR(60) NOT NULL, -- store Argon2 or bcrypt hash
+    pwd VARCHAR(255) NOT NULL, -- Argon2id encoded hash, length depends on its parameters
+    email_verified_at TIMESTAMP NULL,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    disabled_at TIMESTAMP NULL,
+    must_reset_password BOOLEAN NOT NULL DEFAULT FALSE,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -17,7 +27,8 @@ CREATE TABLE artists (
     id INT AUTO_INCREMENT PRIMARY KEY,
     name VARCHAR(60) NOT NULL, -- in the api, make sure to limit this field to the user 'admin' associated with it. 
     codename VARCHAR(60), -- optional to store alias for artist name, i.e. political artist, grafitti artist, student, for privacy
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL             -- set while in the trash
 );
 
 -- ------------------------
@@ -26,6 +37,7 @@ CREATE TABLE artists (
 CREATE TABLE user_artists (
     user_id INT NOT NULL,
     artist_id INT NOT NULL,
+    role VARCHAR(10) NOT NULL DEFAULT 'owner', -- owner, editor or viewer
     PRIMARY KEY(user_id, artist_id),
     FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
     FOREIGN KEY(artist_id) REFERENCES artists(id) ON DELETE CASCADE
@@ -42,6 +54,8 @@ CREATE TABLE artworks (
     title VARCHAR(100),                    -- optional
     description VARCHAR(500),             -- optional
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL,            -- set while in the trash
+    auto_enhance BOOLEAN NOT NULL DEFAULT FALSE, -- serve the enhanced renditions
     FOREIGN KEY(artist_id) REFERENCES artists(id) ON DELETE CASCADE
 );
 
@@ -52,9 +66,24 @@ CREATE TABLE images (
     id INT AUTO_INCREMENT PRIMARY KEY,
     artwork_id INT NOT NULL UNIQUE,       -- 1:1 with artwork
     url VARCHAR(255),                     -- optional link
+    original_mime VARCHAR(50) NOT NULL,   -- e.g., 'image/png', 'image/gif'
     thumb BLOB,                           -- thumbnail image <64KB
     image MEDIUMBLOB NOT NULL,            -- full image
+    thumb_bytes INT NOT NULL DEFAULT 0,   -- stored size of thumb, for quota accounting
+    image_bytes INT NOT NULL DEFAULT 0,   -- stored size of image, for quota accounting
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL,            -- set while in the trash
+    original MEDIUMBLOB NULL,             -- the upload as it came, edits start from it
+    original_bytes INT NOT NULL DEFAULT 0,
+    edits TEXT NULL,                      -- JSON rotate/flip/perspective/crop that made thumb and image
+    enhanced_thumb BLOB NULL,             -- auto-enhanced renditions, made while artworks.auto_enhance is on
+    enhanced_image MEDIUMBLOB NULL,
+    enhanced_thumb_bytes INT NOT NULL DEFAULT 0,
+    enhanced_image_bytes INT NOT NULL DEFAULT 0,
+    phash BIGINT NULL,                    -- perceptual hash (dHash) for duplicate detection
+    palette TEXT NULL,                    -- JSON dominant colours [{"hex", "share"}] for colour search
+    blurhash VARCHAR(64) NULL,            -- BlurHash placeholder shown while the thumbnail loads
+    version INT NOT NULL DEFAULT 1,       -- goes up whenever what's served changes; in thumb_url as ?v=
     FOREIGN KEY(artwork_id) REFERENCES artworks(id) ON DELETE CASCADE
 );
 
@@ -80,28 +109,33 @@ CREATE TABLE artworks_mediums (
 DROP VIEW IF EXISTS all_artwork_data;
 
 -- ------------------------
--- Collates together all the artist/artwork/medium data + a thumbnail and a link.
+-- Collates together all the artist/artwork/medium data + a thumbnail URL and a link.
 -- Uses CREATE OR REPLACE VIEW to handle existence and updates in a single statement.
 -- ------------------------
 
 CREATE OR REPLACE VIEW all_artwork_data AS
 SELECT 
     a.id AS artwork_id,
+    a.artist_id,
     a.grade,
     a.school,
     a.title,
     a.description,
     COALESCE(ar.codename, ar.name) AS artist_name, -- COALESCE(ar.codename, ar.name) as artist_name is the expression.
     i.url,
-    i.thumb, -- BLOB thumbnail
+    i.id AS image_id,
+    CONCAT('/api/artworks/images/', i.id, '/thumb?v=', i.version) AS thumb_url, -- fetched lazily, never the BLOB itself
+    i.blurhash, -- placeholder until the thumbnail loads
     GROUP_CONCAT(m.name ORDER BY m.name SEPARATOR ', ') AS mediums
 FROM artworks a
 JOIN artists ar ON a.artist_id = ar.id
-LEFT JOIN images i ON a.id = i.artwork_id
+LEFT JOIN images i ON a.id = i.artwork_id AND i.deleted_at IS NULL
 LEFT JOIN artworks_mediums am ON a.id = am.artwork_id
 LEFT JOIN mediums m ON am.medium_id = m.id
+WHERE a.deleted_at IS NULL AND ar.deleted_at IS NULL -- nothing in the trash
 GROUP BY 
     a.id, 
+    a.artist_id,
     a.grade, 
     a.school, 
     a.title,         -- 🚨 CRITICAL FIX: Added a.title
@@ -109,20 +143,160 @@ GROUP BY
     ar.codename,     -- Included components of the COALESCE expression
     ar.name,         -- Included components of the COALESCE expression
     i.url, 
-    i.thumb
+    i.id,
+    i.version,
+    i.blurhash
 ORDER BY a.id; -- Optional, but good practice for view stability
 
 
+-- For fast user -> email lookups
+CREATE INDEX idx_users_email ON users(email);
+
 -- For fast artist -> artworks lookups
 CREATE INDEX idx_artworks_artist_id ON artworks(artist_id);
 
--- For images lookups by artwork
-CREATE UNIQUE INDEX idx_images_artwork_id ON images(artwork_id);
+-- For images lookups by artwork (artwork_id is already UNIQUE)
 
 -- For join table lookups
-CREATE INDEX idx_user_artists_user_id ON user_artists(user_id);
 CREATE INDEX idx_user_artists_artist_id ON user_artists(artist_id);
 
-CREATE INDEX idx_artworks_mediums_artwork_id ON artworks_mediums(artwork_id);
 CREATE INDEX idx_artworks_mediums_medium_id ON artworks_mediums(medium_id);
 
+-- For the trash listing and purge
+CREATE INDEX idx_artists_deleted_at ON artists(deleted_at);
+CREATE INDEX idx_artworks_deleted_at ON artworks(deleted_at);
+CREATE INDEX idx_images_deleted_at ON images(deleted_at);
+
+
+-- ------------------------
+-- Table: sessions
+-- One row per signed-in device. The refresh token rotates on every use and is
+-- stored as a SHA-256 hash; previous_hash catches replay of a rotated token.
+-- ------------------------
+CREATE TABLE sessions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    refresh_hash CHAR(64) NOT NULL UNIQUE,
+    previous_hash CHAR(64),
+    user_agent VARCHAR(255),
+    ip VARCHAR(45),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_sessions_previous_hash ON sessions(previous_hash);
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+
+
+-- ------------------------
+-- Table: account_tokens
+-- Single-use password reset and email verification tokens, stored hashed.
+-- ------------------------
+CREATE TABLE account_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    purpose VARCHAR(20) NOT NULL, -- 'password_reset' or 'verify_email'
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_account_tokens_user_id ON account_tokens(user_id);
+
+
+-- ------------------------
+-- Table: invitations (+ invitation_artists)
+-- Email invitations to one or more artists, each with a role. Token stored hashed.
+-- ------------------------
+CREATE TABLE invitations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    email VARCHAR(60) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    invited_by INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP NULL,
+    accepted_by INT NULL,
+    cancelled_at TIMESTAMP NULL,
+    FOREIGN KEY(invited_by) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(accepted_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_invitations_email ON invitations(email);
+
+CREATE TABLE invitation_artists (
+    invitation_id INT NOT NULL,
+    artist_id INT NOT NULL,
+    role VARCHAR(10) NOT NULL,
+    PRIMARY KEY(invitation_id, artist_id),
+    FOREIGN KEY(invitation_id) REFERENCES invitations(id) ON DELETE CASCADE,
+    FOREIGN KEY(artist_id) REFERENCES artists(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_invitation_artists_artist_id ON invitation_artists(artist_id);
+
+
+-- ------------------------
+-- Table: audit_log
+-- Who created, changed or deleted what. No foreign keys so entries outlive
+-- their subjects; changes is a JSON {"field": {"from": ..., "to": ...}} diff.
+-- ------------------------
+CREATE TABLE audit_log (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    actor_id INT NULL, -- NULL for system and anonymous actions
+    action VARCHAR(10) NOT NULL, -- 'create', 'update' or 'delete'
+    entity VARCHAR(20) NOT NULL, -- 'user', 'artist', 'artwork', 'image', 'member', 'invitation', ...
+    entity_id INT NOT NULL,
+    artist_id INT NULL,
+    changes TEXT NULL
+);
+
+CREATE INDEX idx_audit_log_artist_id ON audit_log(artist_id, id);
+CREATE INDEX idx_audit_log_entity ON audit_log(entity, entity_id);
+CREATE INDEX idx_audit_log_actor_id ON audit_log(actor_id);
+
+
+-- ------------------------
+-- Table: artwork_revisions
+-- How an artwork looked before each change. The image columns are only set
+-- when that change replaced the image; otherwise it is the same as the next
+-- revision that has one (or the current image).
+-- ------------------------
+CREATE TABLE artwork_revisions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    artwork_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    actor_id INT NULL,                    -- who made the change
+    grade VARCHAR(20),
+    school VARCHAR(30),
+    title VARCHAR(100),
+    description VARCHAR(500),
+    original_mime VARCHAR(50) NULL,
+    thumb BLOB NULL,
+    image MEDIUMBLOB NULL,
+    thumb_bytes INT NOT NULL DEFAULT 0,   -- counts towards the family quota
+    image_bytes INT NOT NULL DEFAULT 0,
+    edits TEXT NULL,                      -- the edits that went with the image
+    original MEDIUMBLOB NULL,             -- and the original they applied to
+    original_bytes INT NOT NULL DEFAULT 0,
+    FOREIGN KEY(artwork_id) REFERENCES artworks(id) ON DELETE CASCADE,
+    FOREIGN KEY(actor_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_artwork_revisions_artwork_id ON artwork_revisions(artwork_id, id);
+
+
+-- ------------------------
+-- Migration bookkeeping (managed by backend/migrations)
+-- ------------------------
+CREATE TABLE schema_migrations (
+    version INT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
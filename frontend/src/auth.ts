@@ -0,0 +1,12 @@
+// The access token from the last login, sent as a bearer token to the API
+const ACCESS_TOKEN_KEY = 'access_token';
+
+export const getAccessToken = (): string | null => localStorage.getItem(ACCESS_TOKEN_KEY);
+
+export const setAccessToken = (token: string) => localStorage.setItem(ACCESS_TOKEN_KEY, token);
+
+// Headers for authenticated requests, empty when nobody is logged in
+export const authHeaders = (): Record<string, string> => {
+  const token = getAccessToken();
+  return token ? { Authorization: `Bearer ${token}` } : {};
+};
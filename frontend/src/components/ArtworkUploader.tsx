@@ -1,5 +1,6 @@
 import React, { useState, type ChangeEvent, type FormEvent } from 'react';
 import axios from 'axios';
+import { authHeaders, getAccessToken } from '../auth';
 
 // --- CONSTANTS ---
 const UPLOAD_URL = '/api/artworks';
@@ -16,6 +17,7 @@ const ArtworkUploader: React.FC = () => {
   const [message, setMessage] = useState<string>('');
   const [status, setStatus] = useState<'idle' | 'uploading' | 'success' | 'error'>('idle');
   const [imageId, setImageId] = useState<number | null>(null);
+  const [thumbUrl, setThumbUrl] = useState<string>('');
 
   // Handle form field changes
   const handleFieldChange = (e: ChangeEvent<HTMLInputElement>) => {
@@ -47,6 +49,11 @@ const ArtworkUploader: React.FC = () => {
       setMessage('Please provide a Title and select an Image.');
       return;
     }
+    if (!getAccessToken()) {
+      setStatus('error');
+      setMessage('Please log in before uploading.');
+      return;
+    }
 
     setStatus('uploading');
     setMessage('Uploading, creating artwork, and processing image...');
@@ -68,6 +75,7 @@ const ArtworkUploader: React.FC = () => {
 
         headers: {
           'Content-Type': 'multipart/form-data', // Essential for FormData
+          ...authHeaders(), // uploads are for members of the artist
         },
         onUploadProgress: (p) => {
           // Note: Progress bar only tracks file upload, not server processing time.
@@ -78,6 +86,7 @@ const ArtworkUploader: React.FC = () => {
         },
       });
       setImageId(response.data.data.image_id); // <--- Add this line
+      setThumbUrl(response.data.data.thumb_url); // signed, as <img> can't send the auth header
       setStatus('success');
       // Assuming the success message structure is correct
       setMessage(`SUCCESS! Artwork ID: ${response.data.data.artwork_id}, Image ID: ${response.data.data.image_id}`);
@@ -91,7 +100,7 @@ const ArtworkUploader: React.FC = () => {
     } catch (error) {
       setStatus('error');
       if (axios.isAxiosError(error) && error.response) {
-        setMessage(`HTTP Error (${error.response.status}): ${error.response.data.Error || error.message}`);
+        setMessage(`HTTP Error (${error.response.status}): ${error.response.data.error || error.message}`);
       } else {
         setMessage('Network Error. Is the Go server running?');
       }
@@ -226,7 +235,7 @@ const ArtworkUploader: React.FC = () => {
         <div style={{ marginTop: '15px' }}>
           {/* The src must point to your retrieval route */}
           <img
-            src={`/api/artworks/images/${imageId}/thumb`}
+            src={thumbUrl}
             alt={`Uploaded Image ID ${imageId}`}
             style={{ maxWidth: '200px', height: 'auto', border: '1px solid #ccc' }}
           />
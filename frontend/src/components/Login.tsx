@@ -1,4 +1,5 @@
 import { type ChangeEvent, type FormEvent, useState } from "react";
+import { setAccessToken } from "../auth";
 
 export default function Login() {
   const [formData, setFormData] = useState({
@@ -6,17 +7,31 @@ export default function Login() {
     email: "",
     password: "",
   });
+  const [message, setMessage] = useState("");
 
   const handleChange = (event: ChangeEvent<HTMLInputElement>) => {
     const { name, value } = event.target;
     setFormData(prev => ({ ...prev, [name]: value }));
   };
 
-  const handleSubmit = (event: FormEvent<HTMLFormElement>) => {
+  const handleSubmit = async (event: FormEvent<HTMLFormElement>) => {
     event.preventDefault();
-    alert(
-      `Username: ${formData.userName}\nEmail: ${formData.email}\nPassword: ${formData.password}`
-    );
+    try {
+      const response = await fetch("/api/auth/login", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ email: formData.email, password: formData.password }),
+      });
+      const body = await response.json();
+      if (!response.ok) {
+        throw new Error(body.error || `HTTP error! Status: ${response.status}`);
+      }
+      // Kept for the uploader and other authenticated requests
+      setAccessToken(body.data.access_token);
+      setMessage("Logged in");
+    } catch (error: any) {
+      setMessage("Error: " + error.message);
+    }
   };
 
   const fields: { label: string; name: keyof typeof formData; type: string }[] = [
@@ -42,6 +57,7 @@ export default function Login() {
       ))}
       <button type="submit">Submit</button>
     </form>
+    {message && <p>{message}</p>}
     </>
   );
 }